@@ -17,19 +17,19 @@ import (
 	agenterrors "github.com/Axway/agent-sdk/pkg/util/errors"
 	hc "github.com/Axway/agent-sdk/pkg/util/healthcheck"
 
-	"github.com/Axway/agents-mulesoft/pkg/common"
 	"github.com/Axway/agents-mulesoft/pkg/config"
+	"github.com/Axway/agents-mulesoft/pkg/raml"
 )
 
 const (
 	HealthCheckEndpoint      = "mulesoft"
 	monitoringURITemplate    = "%s/monitoring/archive/api/v1/organizations/%s/environments/%s/apis/%s/summary/%4d/%02d/%02d"
 	metricSummaryURITemplate = "%s/monitoring/archive/api/v1/organizations/%s/environments/%s/apis/%s/summary/%d/%02d/%02d/%s"
-	queryTemplate            = `SELECT sum("request_size.count") as request_count, max("response_time.max") as response_max, min("response_time.min") as response_min
+	queryTemplate            = `SELECT sum("response_time.count") as request_count, max("response_time.max") as response_max, min("response_time.min") as response_min, sum("response_time.sum") as response_sum, sum("response_time.sos") as response_sos, sum("request_size.sum") as request_bytes, sum("response_size.sum") as response_bytes
 FROM "rp_general"."api_summary_metric" 
 WHERE ("api_id" = '%s' AND "api_version_id" = '%s') AND 
 time >= %dms and time <= %dms
-GROUP BY "client_id", "status_code"`
+GROUP BY "client_id", "status_code", "method", "request_disposition"`
 )
 
 // Page describes the page query parameter
@@ -38,6 +38,9 @@ type Page struct {
 	PageSize int
 }
 
+// listPageSize is the page size used to list all the contracts of an API or all the applications of an organization
+const listPageSize = 100
+
 // Client interface to gateway
 type Client interface {
 	CreateClientApplication(apiID string, app *AppRequestBody) (*Application, error)
@@ -46,24 +49,31 @@ type Client interface {
 	GetAccessToken() (string, *User, time.Duration, error)
 	GetAPI(apiID string) (*API, error)
 	GetClientApplication(appID string) (*Application, error)
+	GetContract(apiID, contractID string) (*Contract, error)
+	ListContracts(apiID string) ([]Contract, error)
+	ListClientApplications() ([]Application, error)
 	GetEnvironmentByName(name string) (*Environment, error)
 	GetExchangeAsset(groupID, assetID, assetVersion string) (*ExchangeAsset, error)
 	GetExchangeAssetIcon(icon string) (string, string, error)
 	GetExchangeFileContent(link, packaging, mainFile string, useOriginalRaml bool) ([]byte, bool, error)
 	GetPolicies(apiID string) ([]Policy, error)
 	GetSLATiers(apiID string, tierName string) (*Tiers, error)
-	CreateSLATier(apiID string) (int, error)
+	CreateSLATier(apiID string, tier *SLATier) (int, error)
+	UpdateSLATier(apiID string, tier *SLATier) error
 	ListAssets(page *Page) ([]Asset, error)
 	OnConfigChange(mulesoftConfig *config.MulesoftConfig)
 	DeleteContract(apiID, contractID string) error
 	RevokeContract(apiID, contractID string) error
+	RestoreContract(apiID, contractID string) error
+	ListApplicationContracts(appID string) ([]Contract, error)
 	ResetAppSecret(appID string) (*Application, error)
 }
 
 type AnalyticsClient interface {
 	GetMonitoringBootstrap() (*MonitoringBootInfo, error)
 	GetMonitoringMetrics(dataSourceName string, dataSourceID int, apiID, apiVersionID string, startDate, endTime time.Time) ([]APIMonitoringMetric, error)
-	GetMonitoringArchive(apiID string, startDate time.Time) ([]APIMonitoringMetric, error)
+	ListMonitoringArchiveFiles(apiID string, day time.Time) ([]DataFile, error)
+	GetMonitoringArchiveFile(apiID string, day time.Time, fileID string) ([]APIMonitoringMetric, error)
 	OnConfigChange(mulesoftConfig *config.MulesoftConfig)
 	GetClientApplication(appID string) (*Application, error)
 	GetAPI(apiID string) (*API, error)
@@ -362,7 +372,8 @@ func (c *AnypointClient) GetExchangeAssetIcon(icon string) (string, string, erro
 }
 
 // GetExchangeFileContent download the file from the ExternalLink reference. If the file is a zip file
-// and there is a MainFile set then the content of the MainFile is returned.
+// and there is a MainFile set then the content of the MainFile is returned. A RAML MainFile is returned with its
+// includes and libraries resolved into a single document.
 func (c *AnypointClient) GetExchangeFileContent(link, packaging, mainFile string, useOriginalRaml bool) ([]byte, bool, error) {
 	wasConverted := false
 	fileContent, _, err := c.invokeGet(link)
@@ -374,9 +385,9 @@ func (c *AnypointClient) GetExchangeFileContent(link, packaging, mainFile string
 		return nil, wasConverted, err
 	}
 
+	files := map[string][]byte{}
 	for _, f := range zipReader.File {
-		// In case of RAML spec, this gets automatically converted and is renamed to api.json
-		if f.Name != mainFile && f.Name != "api.json" {
+		if f.FileInfo().IsDir() {
 			continue
 		}
 		content, err := f.Open()
@@ -384,17 +395,37 @@ func (c *AnypointClient) GetExchangeFileContent(link, packaging, mainFile string
 			return nil, wasConverted, err
 		}
 
-		fileContent, err = io.ReadAll(content)
+		files[f.Name], err = io.ReadAll(content)
 		content.Close()
 		if err != nil {
 			return nil, wasConverted, err
 		}
-		if !useOriginalRaml && f.Name == "api.json" {
-			return fileContent, true, err
+	}
+
+	// In case of RAML spec, this gets automatically converted and is renamed to api.json
+	if converted, ok := files["api.json"]; ok && !useOriginalRaml {
+		return converted, true, nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(mainFile), ".raml") {
+		if content, ok := files[mainFile]; ok {
+			bundled, err := raml.Bundle(files, mainFile)
+			if err != nil {
+				// the main file is published as it is, as before the bundling of the archives
+				logrus.WithError(err).WithField("mainFile", mainFile).Warn("failed to bundle the RAML archive, using the main file")
+				return content, wasConverted, nil
+			}
+			return bundled, wasConverted, nil
 		}
-		break
 	}
-	return fileContent, wasConverted, err
+
+	if content, ok := files[mainFile]; ok {
+		return content, wasConverted, nil
+	}
+	if content, ok := files["api.json"]; ok {
+		return content, wasConverted, nil
+	}
+	return fileContent, wasConverted, nil
 }
 
 func (c *AnypointClient) GetMonitoringBootstrap() (*MonitoringBootInfo, error) {
@@ -449,11 +480,17 @@ func (c *AnypointClient) GetMonitoringMetrics(dataSourceName string, dataSourceI
 				Time: endTime,
 				Events: []APISummaryMetricEvent{
 					{
-						ClientID:         ms.Tags.ClientID,
-						StatusCode:       ms.Tags.StatusCode,
-						RequestSizeCount: int(ms.Count),
-						ResponseSizeMax:  int(ms.ResponseMax),
-						ResponseSizeMin:  int(ms.ResponseMin),
+						ClientID:           ms.Tags.ClientID,
+						StatusCode:         ms.Tags.StatusCode,
+						Method:             ms.Tags.Method,
+						RequestDisposition: ms.Tags.RequestDisposition,
+						ResponseTimeMax:    int(ms.ResponseMax),
+						ResponseTimeMin:    int(ms.ResponseMin),
+						ResponseTimeCount:  int(ms.Count),
+						ResponseTimeSum:    int(ms.ResponseSum),
+						ResponseTimeSos:    int(ms.ResponseSos),
+						RequestSizeSum:     int(ms.RequestBytes),
+						ResponseSizeSum:    int(ms.ResponseBytes),
 					},
 				},
 			}
@@ -463,17 +500,15 @@ func (c *AnypointClient) GetMonitoringMetrics(dataSourceName string, dataSourceI
 	return metrics, err
 }
 
-// GetMonitoringArchive returns archived monitoring data Mulesoft:
+// ListMonitoringArchiveFiles returns the data files of the monitoring archive of an API for the UTC day of a time:
 // https://anypoint.mulesoft.com/exchange/portals/anypoint-platform/f1e97bc6-315a-4490-82a7-23abe036327a.anypoint-platform/anypoint-monitoring-archive-api/minor/1.0/pages/home/
-func (c *AnypointClient) GetMonitoringArchive(apiID string, startDate time.Time) ([]APIMonitoringMetric, error) {
+func (c *AnypointClient) ListMonitoringArchiveFiles(apiID string, day time.Time) ([]DataFile, error) {
 	headers := map[string]string{
 		"Authorization": c.getAuthString(c.auth.GetToken()),
 	}
-	year := startDate.Year()
-	month := int(startDate.Month())
-	day := startDate.Day()
+	day = day.UTC()
 
-	url := fmt.Sprintf(monitoringURITemplate, c.monitoringBaseURL, c.auth.GetOrgID(), c.environment.ID, apiID, year, month, day)
+	url := fmt.Sprintf(monitoringURITemplate, c.monitoringBaseURL, c.auth.GetOrgID(), c.environment.ID, apiID, day.Year(), int(day.Month()), day.Day())
 	dataFiles := &DataFileResources{}
 	request := coreapi.Request{
 		Method:  coreapi.GET,
@@ -482,33 +517,25 @@ func (c *AnypointClient) GetMonitoringArchive(apiID string, startDate time.Time)
 	}
 
 	err := c.invokeJSON(request, &dataFiles)
-	if err != nil && !strings.Contains(err.Error(), "404") {
-		return nil, err
-	}
-
-	metrics := make([]APIMonitoringMetric, 0)
-	for _, dataFile := range dataFiles.Resources {
-		apiMetric, err := c.getMonitoringArchiveFile(apiID, year, month, day, dataFile.ID)
-		if err != nil {
-			logrus.WithField("apiID", apiID).
-				WithField("fileName", dataFile.ID).
-				WithError(err).
-				Warn("failed to read monitoring archive")
-		}
-		if len(apiMetric) > 0 {
-			metrics = append(metrics, apiMetric...)
+	if err != nil {
+		// there is no archive for a day without traffic
+		if strings.Contains(err.Error(), "404") {
+			return []DataFile{}, nil
 		}
+		return nil, err
 	}
 
-	return metrics, err
+	return dataFiles.Resources, nil
 }
 
-func (c *AnypointClient) getMonitoringArchiveFile(apiID string, year, month, day int, fileName string) ([]APIMonitoringMetric, error) {
+// GetMonitoringArchiveFile returns the metrics of a data file of the monitoring archive of an API
+func (c *AnypointClient) GetMonitoringArchiveFile(apiID string, day time.Time, fileID string) ([]APIMonitoringMetric, error) {
 	headers := map[string]string{
 		"Authorization": c.getAuthString(c.auth.GetToken()),
 	}
+	day = day.UTC()
 
-	url := fmt.Sprintf(metricSummaryURITemplate, c.monitoringBaseURL, c.auth.GetOrgID(), c.environment.ID, apiID, year, month, day, fileName)
+	url := fmt.Sprintf(metricSummaryURITemplate, c.monitoringBaseURL, c.auth.GetOrgID(), c.environment.ID, apiID, day.Year(), int(day.Month()), day.Day(), fileID)
 	request := coreapi.Request{
 		Method:  coreapi.GET,
 		URL:     url,
@@ -533,7 +560,7 @@ func (c *AnypointClient) parseMetricSummaries(metricDataStream []byte) ([]APIMon
 		if err != nil {
 			// io.EOF is expected at end of stream.
 			if err != io.EOF {
-				return metrics, nil
+				return nil, fmt.Errorf("failed to decode the monitoring data file: %s", err)
 			}
 			break
 		}
@@ -567,21 +594,8 @@ func (c *AnypointClient) GetSLATiers(apiID string, tierName string) (*Tiers, err
 	return &slatiers, err
 }
 
-func (c *AnypointClient) CreateSLATier(apiID string) (int, error) {
+func (c *AnypointClient) CreateSLATier(apiID string, tier *SLATier) (int, error) {
 	var resp SLATier
-	tier := SLATier{
-		Name:        common.AxwayAgentSLATierName,
-		AutoApprove: true,
-		Description: ToPointer[string](common.AxwayAgentSLATierDescription),
-		Limits: []Limits{
-			Limits{
-				MaximumRequests:          10,
-				TimePeriodInMilliseconds: 1000,
-				Visible:                  true,
-			},
-		},
-		Status: common.SLAActive,
-	}
 	url := fmt.Sprintf("%s/apimanager/api/v1/organizations/%s/environments/%s/apis/%s/tiers",
 		c.baseURL, c.auth.GetOrgID(), c.environment.ID, apiID)
 
@@ -597,10 +611,36 @@ func (c *AnypointClient) CreateSLATier(apiID string) (int, error) {
 	return *resp.ID, nil
 }
 
+// UpdateSLATier replaces the limits and the approval mode of an existing SLA tier
+func (c *AnypointClient) UpdateSLATier(apiID string, tier *SLATier) error {
+	var resp SLATier
+	url := fmt.Sprintf("%s/apimanager/api/v1/organizations/%s/environments/%s/apis/%s/tiers/%d",
+		c.baseURL, c.auth.GetOrgID(), c.environment.ID, apiID, *tier.ID)
+
+	body, err := json.Marshal(tier)
+	if err != nil {
+		return agenterrors.Wrap(ErrMarshallingBody, err.Error())
+	}
+
+	request := coreapi.Request{
+		Method: coreapi.PUT,
+		URL:    url,
+		Headers: map[string]string{
+			"Authorization": c.getAuthString(c.auth.GetToken()),
+			"Content-Type":  "application/json",
+			"Accept":        "application/json",
+		},
+		Body: body,
+	}
+	return c.invokeJSON(request, &resp)
+}
+
 func (c *AnypointClient) CreateClientApplication(apiID string, app *AppRequestBody) (*Application, error) {
 	var application Application
-	query := map[string]string{
-		"apiInstanceId": apiID,
+	// an app created for a managed application is not bound to an api instance yet
+	query := map[string]string{}
+	if apiID != "" {
+		query["apiInstanceId"] = apiID
 	}
 
 	url := fmt.Sprintf("%s/exchange/api/v2/organizations/%s/applications", c.baseURL, c.auth.GetOrgID())
@@ -697,6 +737,86 @@ func (c *AnypointClient) RevokeContract(apiID, contractID string) error {
 	return nil
 }
 
+// RestoreContract restores a revoked contract
+func (c *AnypointClient) RestoreContract(apiID, contractID string) error {
+	res := map[string]interface{}{}
+
+	url := fmt.Sprintf(
+		"%s/apimanager/xapi/v1/organizations/%s/environments/%s/apis/%s/contracts/%s/restore",
+		c.baseURL, c.auth.GetOrgID(), c.environment.ID, apiID, contractID,
+	)
+
+	return c.invokeJSONPost(url, nil, nil, &res)
+}
+
+// ListApplicationContracts lists all the contracts of a client application
+func (c *AnypointClient) ListApplicationContracts(appID string) ([]Contract, error) {
+	url := fmt.Sprintf("%s/exchange/api/v2/organizations/%s/applications/%s/contracts", c.baseURL, c.auth.GetOrgID(), appID)
+
+	// the contracts of an application are returned without a total
+	return listAll(func(page *Page) ([]Contract, int, error) {
+		contracts := []Contract{}
+		err := c.invokeJSONGet(url, page, &contracts, nil)
+		return contracts, -1, err
+	}, func(contract Contract) int {
+		return contract.ID
+	})
+}
+
+// ListContracts lists all the contracts of an API
+func (c *AnypointClient) ListContracts(apiID string) ([]Contract, error) {
+	url := fmt.Sprintf(
+		"%s/apimanager/api/v1/organizations/%s/environments/%s/apis/%s/contracts",
+		c.baseURL, c.auth.GetOrgID(), c.environment.ID, apiID,
+	)
+
+	return listAll(func(page *Page) ([]Contract, int, error) {
+		var res Contracts
+		err := c.invokeJSONGet(url, page, &res, nil)
+		return res.Contracts, res.Total, err
+	}, func(contract Contract) int {
+		return contract.ID
+	})
+}
+
+// ListClientApplications lists all the client applications of the organization
+func (c *AnypointClient) ListClientApplications() ([]Application, error) {
+	url := fmt.Sprintf("%s/exchange/api/v2/organizations/%s/applications", c.baseURL, c.auth.GetOrgID())
+
+	return listAll(func(page *Page) ([]Application, int, error) {
+		var res []Application
+		err := c.invokeJSONGet(url, page, &res, nil)
+		return res, -1, err
+	}, func(app Application) int {
+		return app.ID
+	})
+}
+
+// listAll reads the pages of a list until the total is read, or until a page is empty or only has items already read,
+// which happens when an endpoint ignores the paging. A negative total is unknown.
+func listAll[T any](list func(page *Page) ([]T, int, error), id func(T) int) ([]T, error) {
+	items := []T{}
+	read := map[int]bool{}
+	for offset := 0; ; offset += listPageSize {
+		page, total, err := list(&Page{Offset: offset, PageSize: listPageSize})
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, item := range page {
+			if read[id(item)] {
+				continue
+			}
+			read[id(item)] = true
+			items = append(items, item)
+			added++
+		}
+		if added == 0 || len(page) < listPageSize || (total >= 0 && len(items) >= total) {
+			return items, nil
+		}
+	}
+}
+
 func (c *AnypointClient) GetContract(apiID, contractID string) (*Contract, error) {
 	var cnt Contract
 
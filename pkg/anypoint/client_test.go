@@ -1,6 +1,9 @@
 package anypoint
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"io"
 	"os"
 	"testing"
@@ -83,6 +86,10 @@ func TestClient(t *testing.T) {
 			Code: 200,
 			Body: readTestDataFile(t, "./testdata/summary-datafiles.json"),
 		},
+		"/monitoring/archive/api/v1/organizations/444/environments/111/apis/222/summary/2024/01/02": {
+			Code: 404,
+			Body: []byte(`{}`),
+		},
 		"/monitoring/archive/api/v1/organizations/444/environments/111/apis/222/summary/2024/01/01/444-111-222.log": {
 			Code: 200,
 			Body: readTestDataFile(t, "./testdata/monitoring-archive.txt"),
@@ -154,10 +161,19 @@ func TestClient(t *testing.T) {
 
 	startTime, _ := time.Parse(time.RFC3339, "2024-01-01T14:30:20-07:00")
 
-	events, err := client.GetMonitoringArchive("222", startTime)
+	files, err := client.ListMonitoringArchiveFiles("222", startTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+
+	events, err := client.GetMonitoringArchiveFile("222", startTime, files[0].ID)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(events))
 
+	// the day of the archive is the UTC day, there is no archive for a day without traffic
+	files, err = client.ListMonitoringArchiveFiles("222", startTime.Add(10*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+
 	bootInfo, err := client.GetMonitoringBootstrap()
 	assert.Nil(t, err)
 	assert.NotNil(t, bootInfo)
@@ -165,8 +181,159 @@ func TestClient(t *testing.T) {
 	events, err = client.GetMonitoringMetrics(bootInfo.Settings.DataSource.InfluxDB.Database, bootInfo.Settings.DataSource.InfluxDB.ID, "222", "222", startTime, startTime)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(events))
+	event := events[0].Events[0]
+	assert.Equal(t, 3243, event.ResponseTimeMax)
+	assert.Equal(t, 74, event.ResponseTimeMin)
+	assert.Equal(t, 4, event.ResponseTimeCount)
+	assert.Equal(t, "POST", event.Method)
+	assert.Equal(t, "processed", event.RequestDisposition)
+	assert.Equal(t, 3500, event.ResponseTimeSum)
+	assert.Equal(t, 10547126, event.ResponseTimeSos)
+	assert.Equal(t, 120, event.RequestSizeSum)
+	assert.Equal(t, 2048, event.ResponseSizeSum)
 
 	go client.auth.Stop()
 	done := <-ma.ch
 	assert.True(t, done)
 }
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestGetExchangeFileContent(t *testing.T) {
+	ramlArchive := zipArchive(t, map[string]string{
+		"api.raml":        "#%RAML 1.0\ntitle: petstore\nuses:\n  lib: lib/pets.raml\n/pets:\n  get:\n    responses:\n      200:\n        body:\n          application/json:\n            type: lib.Pet\n",
+		"lib/pets.raml":   "#%RAML 1.0 Library\ntypes:\n  Pet: !include pet.raml\n",
+		"lib/pet.raml":    "#%RAML 1.0 DataType\ntype: object\n",
+		"api.json":        `{"openapi":"3.0.1"}`,
+		"exchange.json":   `{}`,
+		"docs/readme.txt": "petstore",
+	})
+	brokenArchive := zipArchive(t, map[string]string{
+		"api.raml": "#%RAML 1.0\ntitle: petstore\ntypes:\n  Pet: !include missing.raml\n",
+	})
+	oasArchive := zipArchive(t, map[string]string{
+		"petstore.yaml": "openapi: 3.0.1\n",
+	})
+	client := &AnypointClient{
+		apiClient: &MockClientBase{
+			Reqs: map[string]*api.Response{
+				"/raml.zip":   {Code: 200, Body: ramlArchive},
+				"/broken.zip": {Code: 200, Body: brokenArchive},
+				"/oas.zip":    {Code: 200, Body: oasArchive},
+				"/oas.json":   {Code: 200, Body: []byte(`{"openapi":"3.0.1"}`)},
+			},
+		},
+	}
+
+	content, converted, err := client.GetExchangeFileContent("/raml.zip", "zip", "api.raml", false)
+	assert.Nil(t, err)
+	assert.True(t, converted)
+	assert.Equal(t, `{"openapi":"3.0.1"}`, string(content))
+
+	content, converted, err = client.GetExchangeFileContent("/raml.zip", "zip", "api.raml", true)
+	assert.Nil(t, err)
+	assert.False(t, converted)
+	assert.Contains(t, string(content), "#%RAML 1.0\n")
+	assert.Contains(t, string(content), "type: lib_Pet")
+	assert.NotContains(t, string(content), "!include")
+	assert.NotContains(t, string(content), "uses:")
+
+	// the main file is used when the archive cannot be bundled
+	content, converted, err = client.GetExchangeFileContent("/broken.zip", "zip", "api.raml", true)
+	assert.Nil(t, err)
+	assert.False(t, converted)
+	assert.Equal(t, "#%RAML 1.0\ntitle: petstore\ntypes:\n  Pet: !include missing.raml\n", string(content))
+
+	content, converted, err = client.GetExchangeFileContent("/oas.zip", "zip", "petstore.yaml", false)
+	assert.Nil(t, err)
+	assert.False(t, converted)
+	assert.Equal(t, "openapi: 3.0.1\n", string(content))
+
+	content, converted, err = client.GetExchangeFileContent("/oas.json", "json", "", false)
+	assert.Nil(t, err)
+	assert.False(t, converted)
+	assert.Equal(t, `{"openapi":"3.0.1"}`, string(content))
+}
+
+func TestListPages(t *testing.T) {
+	page := func(size int) ([]Application, []Contract) {
+		apps := []Application{}
+		contracts := []Contract{}
+		for i := 1; i <= size; i++ {
+			apps = append(apps, Application{ID: i})
+			contracts = append(contracts, Contract{ID: i})
+		}
+		return apps, contracts
+	}
+	apps, contracts := page(listPageSize)
+	appsBody, err := json.Marshal(apps)
+	assert.Nil(t, err)
+	contractsBody, err := json.Marshal(Contracts{Total: listPageSize, Contracts: contracts})
+	assert.Nil(t, err)
+	appContractsBody, err := json.Marshal(contracts)
+	assert.Nil(t, err)
+
+	// the mock ignores the paging and returns the same full page for every offset
+	client := &AnypointClient{
+		apiClient: &MockClientBase{
+			Reqs: map[string]*api.Response{
+				"/exchange/api/v2/organizations/444/applications":                          {Code: 200, Body: appsBody},
+				"/apimanager/api/v1/organizations/444/environments/111/apis/222/contracts": {Code: 200, Body: contractsBody},
+				"/exchange/api/v2/organizations/444/applications/333/contracts":            {Code: 200, Body: appContractsBody},
+			},
+		},
+		auth:        &MockAuth{},
+		environment: &Environment{ID: "111"},
+	}
+
+	listedApps, err := client.ListClientApplications()
+	assert.Nil(t, err)
+	assert.Equal(t, listPageSize, len(listedApps))
+
+	listedContracts, err := client.ListContracts("222")
+	assert.Nil(t, err)
+	assert.Equal(t, listPageSize, len(listedContracts))
+
+	listedContracts, err = client.ListApplicationContracts("333")
+	assert.Nil(t, err)
+	assert.Equal(t, listPageSize, len(listedContracts))
+}
+
+func TestParseMetricSummaries(t *testing.T) {
+	client := &AnypointClient{}
+
+	metrics, err := client.parseMetricSummaries([]byte(`{"time":1704144620000,"events":[]}` + "\n" + `{"time":1704144680000,"events":[]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(metrics))
+
+	// a data file that cannot be decoded is not reported partially
+	metrics, err = client.parseMetricSummaries([]byte(`{"time":1704144620000,"events":[]}` + "\n" + `{"time":`))
+	assert.NotNil(t, err)
+	assert.Nil(t, metrics)
+}
+
+func TestIsNotFound(t *testing.T) {
+	mcb := &MockClientBase{Reqs: map[string]*api.Response{
+		"missing.com": {Code: 404, Body: []byte(`{"message":"contract not found"}`)},
+		"failing.com": {Code: 500, Body: []byte(`{"message":"error 404"}`)},
+	}}
+	client := &AnypointClient{apiClient: mcb}
+
+	_, _, err := client.invoke(api.Request{Method: "GET", URL: "missing.com"})
+	assert.True(t, IsNotFound(err))
+	_, _, err = client.invoke(api.Request{Method: "GET", URL: "failing.com"})
+	assert.NotNil(t, err)
+	assert.False(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+}
@@ -2,6 +2,8 @@ package anypoint
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/tidwall/gjson"
 
@@ -32,3 +34,8 @@ func NewErrorResponse(body string, code int) *ErrorResponse {
 func (er *ErrorResponse) String() string {
 	return fmt.Sprintf("%d - %s", er.Code, er.Message)
 }
+
+// IsNotFound returns true for the error of a request to a resource that does not exist in Mulesoft
+func IsNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), NewErrorResponse("", http.StatusNotFound).String())
+}
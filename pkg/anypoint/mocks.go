@@ -92,13 +92,19 @@ func (m *MockAnypointClient) GetExchangeAssetIcon(_ string) (string, string, err
 	return icon, contentType, args.Error(2)
 }
 
-func (m *MockAnypointClient) GetExchangeFileContent(_, _, _ string, shouldConvert bool) ([]byte, bool, error) {
+func (m *MockAnypointClient) GetExchangeFileContent(_, _, _ string, _ bool) ([]byte, bool, error) {
 	args := m.Called()
 	result := args.Get(0)
-	return result.([]byte), shouldConvert, args.Error(2)
+	return result.([]byte), args.Bool(1), args.Error(2)
 }
 
-func (m *MockAnypointClient) GetMonitoringArchive(apiID string, startDate time.Time) ([]APIMonitoringMetric, error) {
+func (m *MockAnypointClient) ListMonitoringArchiveFiles(apiID string, day time.Time) ([]DataFile, error) {
+	args := m.Called()
+	result := args.Get(0)
+	return result.([]DataFile), args.Error(1)
+}
+
+func (m *MockAnypointClient) GetMonitoringArchiveFile(apiID string, day time.Time, fileID string) ([]APIMonitoringMetric, error) {
 	args := m.Called()
 	result := args.Get(0)
 	return result.([]APIMonitoringMetric), args.Error(1)
@@ -115,20 +121,38 @@ func (m *MockAnypointClient) CreateContract(appID string, contract *Contract) (*
 	return contract, args.Error(1)
 }
 
+func (m *MockAnypointClient) GetContract(apiID, contractID string) (*Contract, error) {
+	args := m.Called()
+	result := args.Get(0)
+	return result.(*Contract), args.Error(1)
+}
+
+func (m *MockAnypointClient) ListContracts(apiID string) ([]Contract, error) {
+	args := m.Called()
+	result := args.Get(0)
+	return result.([]Contract), args.Error(1)
+}
+
+func (m *MockAnypointClient) ListClientApplications() ([]Application, error) {
+	args := m.Called()
+	result := args.Get(0)
+	return result.([]Application), args.Error(1)
+}
+
 func (m *MockAnypointClient) GetSLATiers(apiID, tierName string) (*Tiers, error) {
-	return &Tiers{
-		Total: 1,
-		Tiers: []SLATier{
-			{
-				ID:   ToPointer[int](14214),
-				Name: tierName,
-			},
-		},
-	}, nil
+	args := m.Called()
+	result := args.Get(0)
+	return result.(*Tiers), args.Error(1)
 }
 
-func (m *MockAnypointClient) CreateSLATier(apiID string) (int, error) {
-	return 1, nil
+func (m *MockAnypointClient) CreateSLATier(apiID string, tier *SLATier) (int, error) {
+	args := m.Called(tier)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAnypointClient) UpdateSLATier(apiID string, tier *SLATier) error {
+	args := m.Called(tier)
+	return args.Error(0)
 }
 
 func (m *MockAnypointClient) DeleteClientApplication(appID string) error {
@@ -150,6 +174,17 @@ func (m *MockAnypointClient) RevokeContract(apiID, contractID string) error {
 	return args.Error(0)
 }
 
+func (m *MockAnypointClient) RestoreContract(apiID, contractID string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockAnypointClient) ListApplicationContracts(appID string) ([]Contract, error) {
+	args := m.Called()
+	result := args.Get(0)
+	return result.([]Contract), args.Error(1)
+}
+
 func (m *MockAnypointClient) ResetAppSecret(appID string) (*Application, error) {
 	return nil, nil
 }
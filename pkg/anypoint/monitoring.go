@@ -2,6 +2,7 @@ package anypoint
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 )
 
@@ -11,6 +12,9 @@ type APIMonitoringMetric struct {
 	Events []APISummaryMetricEvent
 }
 
+// DispositionBlocked - the disposition of a request rejected by a policy
+const DispositionBlocked = "blocked"
+
 type DataFile struct {
 	ID   string    `json:"id"`
 	Time time.Time `json:"time"`
@@ -46,6 +50,51 @@ type APISummaryMetricEvent struct {
 	RequestDisposition string `json:"request_disposition"`
 }
 
+// MetricSummary - the canonical model of the metrics of the requests of an API for a client and status code, the same
+// for the monitoring archive and the InfluxDB metrics. The response times are in milliseconds, the sizes in bytes.
+type MetricSummary struct {
+	ClientID           string
+	StatusCode         string
+	Method             string
+	RequestDisposition string
+	RequestCount       int64
+	ResponseTimeMax    int64
+	ResponseTimeMin    int64
+	ResponseTimeAvg    float64
+	ResponseTimeStdDev float64
+	RequestBytes       int64
+	ResponseBytes      int64
+}
+
+// Summary returns the canonical metrics of an event. A request without a body is not counted in the request size, the
+// requests are counted from the response times, or from the sizes when there are no response times.
+func (e APISummaryMetricEvent) Summary() MetricSummary {
+	summary := MetricSummary{
+		ClientID:           e.ClientID,
+		StatusCode:         e.StatusCode,
+		Method:             e.Method,
+		RequestDisposition: e.RequestDisposition,
+		RequestCount:       int64(e.ResponseTimeCount),
+		ResponseTimeMax:    int64(e.ResponseTimeMax),
+		ResponseTimeMin:    int64(e.ResponseTimeMin),
+		RequestBytes:       int64(e.RequestSizeSum),
+		ResponseBytes:      int64(e.ResponseSizeSum),
+	}
+	if summary.RequestCount == 0 {
+		summary.RequestCount = int64(max(e.RequestSizeCount, e.ResponseSizeCount))
+	}
+
+	if e.ResponseTimeCount > 0 {
+		count := float64(e.ResponseTimeCount)
+		summary.ResponseTimeAvg = float64(e.ResponseTimeSum) / count
+		// rounding may make the variance of equal response times slightly negative
+		if variance := float64(e.ResponseTimeSos)/count - summary.ResponseTimeAvg*summary.ResponseTimeAvg; variance > 0 {
+			summary.ResponseTimeStdDev = math.Sqrt(variance)
+		}
+	}
+	return summary
+}
+
 type MetricData struct {
 	Format   string                 `json:"format"`
 	Time     int64                  `json:"time"`
@@ -83,19 +132,25 @@ type MetricResult struct {
 }
 
 type MetricTag struct {
-	ClientID   string `json:"client_id"`
-	StatusCode string `json:"status_code"`
+	ClientID           string `json:"client_id"`
+	StatusCode         string `json:"status_code"`
+	Method             string `json:"method"`
+	RequestDisposition string `json:"request_disposition"`
 }
 
 type MetricSeries struct {
-	Name        string      `json:"name"`
-	Tags        *MetricTag  `json:"tags"`
-	Columns     []string    `json:"columns"`
-	Values      [][]float64 `json:"values"`
-	Time        time.Time   `json:"-"`
-	Count       int64       `json:"-"`
-	ResponseMax int64       `json:"-"`
-	ResponseMin int64       `json:"-"`
+	Name          string      `json:"name"`
+	Tags          *MetricTag  `json:"tags"`
+	Columns       []string    `json:"columns"`
+	Values        [][]float64 `json:"values"`
+	Time          time.Time   `json:"-"`
+	Count         int64       `json:"-"`
+	ResponseMax   int64       `json:"-"`
+	ResponseMin   int64       `json:"-"`
+	ResponseSum   int64       `json:"-"`
+	ResponseSos   int64       `json:"-"`
+	RequestBytes  int64       `json:"-"`
+	ResponseBytes int64       `json:"-"`
 }
 
 func (ms *MetricSeries) UnmarshalJSON(data []byte) error {
@@ -114,6 +169,10 @@ func (ms *MetricSeries) UnmarshalJSON(data []byte) error {
 	ms.Count = ms.getValue("request_count")
 	ms.ResponseMax = ms.getValue("response_max")
 	ms.ResponseMin = ms.getValue("response_min")
+	ms.ResponseSum = ms.getValue("response_sum")
+	ms.ResponseSos = ms.getValue("response_sos")
+	ms.RequestBytes = ms.getValue("request_bytes")
+	ms.ResponseBytes = ms.getValue("response_bytes")
 
 	return nil
 }
@@ -0,0 +1,111 @@
+package anypoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    APISummaryMetricEvent
+		expected MetricSummary
+	}{
+		{
+			name: "should count the requests from the response times",
+			event: APISummaryMetricEvent{
+				ClientID:           "c1",
+				StatusCode:         "200",
+				Method:             "GET",
+				RequestDisposition: "processed",
+				ResponseTimeCount:  3,
+				ResponseTimeSum:    60,
+				ResponseTimeSos:    1400,
+				ResponseTimeMax:    30,
+				ResponseTimeMin:    10,
+				RequestSizeCount:   1,
+				RequestSizeSum:     50,
+				ResponseSizeCount:  3,
+				ResponseSizeSum:    300,
+			},
+			expected: MetricSummary{
+				ClientID:           "c1",
+				StatusCode:         "200",
+				Method:             "GET",
+				RequestDisposition: "processed",
+				RequestCount:       3,
+				ResponseTimeMax:    30,
+				ResponseTimeMin:    10,
+				ResponseTimeAvg:    20,
+				ResponseTimeStdDev: 8.16496580927726,
+				RequestBytes:       50,
+				ResponseBytes:      300,
+			},
+		},
+		{
+			name:     "should count the requests from the sizes without response times",
+			event:    APISummaryMetricEvent{StatusCode: "200", RequestSizeCount: 1, ResponseSizeCount: 2},
+			expected: MetricSummary{StatusCode: "200", RequestCount: 2},
+		},
+		{
+			name:     "should not have a deviation for equal response times",
+			event:    APISummaryMetricEvent{StatusCode: "200", ResponseTimeCount: 2, ResponseTimeSum: 8, ResponseTimeSos: 32},
+			expected: MetricSummary{StatusCode: "200", RequestCount: 2, ResponseTimeAvg: 4},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.event.Summary())
+		})
+	}
+}
+
+// TestSummarySources reads the same traffic from the monitoring archive and from InfluxDB, both sources have the same
+// canonical metrics
+func TestSummarySources(t *testing.T) {
+	client := &AnypointClient{
+		apiClient: &MockClientBase{
+			Reqs: map[string]*api.Response{
+				"/monitoring/archive/api/v1/organizations/444/environments/111/apis/222/summary/2024/10/22/compare.log": {
+					Code: 200,
+					Body: readTestDataFile(t, "./testdata/summary-compare.txt"),
+				},
+				"/monitoring/api/visualizer/api/datasources/proxy/1234/query": {
+					Code: 200,
+					Body: readTestDataFile(t, "./testdata/query-compare.json"),
+				},
+			},
+		},
+		auth:        MockAuth{},
+		environment: &Environment{ID: "111"},
+	}
+	day := time.UnixMilli(1729589392000)
+
+	archive, err := client.GetMonitoringArchiveFile("222", day, "compare.log")
+	assert.Nil(t, err)
+	influx, err := client.GetMonitoringMetrics("db", 1234, "222", "222", day, day)
+	assert.Nil(t, err)
+
+	archiveSummaries := []MetricSummary{}
+	for _, metric := range archive {
+		for _, event := range metric.Events {
+			archiveSummaries = append(archiveSummaries, event.Summary())
+		}
+	}
+	influxSummaries := []MetricSummary{}
+	for _, metric := range influx {
+		for _, event := range metric.Events {
+			influxSummaries = append(influxSummaries, event.Summary())
+		}
+	}
+
+	assert.Len(t, archiveSummaries, 2)
+	assert.Equal(t, archiveSummaries, influxSummaries)
+	// the requests without a body are counted
+	assert.Equal(t, int64(3), archiveSummaries[0].RequestCount)
+	assert.Equal(t, int64(2), archiveSummaries[1].RequestCount)
+}
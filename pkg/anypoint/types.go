@@ -185,12 +185,13 @@ type ExchangeFile struct {
 }
 
 type Application struct {
-	APIEndpoints bool   `json:"apiEndpoints,omitempty"`
-	ClientID     string `json:"clientId"`
-	ClientSecret string `json:"clientSecret"`
-	Description  string `json:"description"`
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
+	APIEndpoints bool     `json:"apiEndpoints,omitempty"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Description  string   `json:"description"`
+	GrantTypes   []string `json:"grantTypes,omitempty"`
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
 }
 
 type AppRequestBody struct {
@@ -201,6 +202,7 @@ type AppRequestBody struct {
 type Contract struct {
 	AcceptedTerms   bool   `json:"acceptedTerms"`
 	ApiID           string `json:"apiId"`
+	ApplicationID   int    `json:"applicationId,omitempty"`
 	AssetID         string `json:"assetId"`
 	EnvironmentID   string `json:"environmentId"`
 	GroupID         string `json:"groupId"`
@@ -212,6 +214,11 @@ type Contract struct {
 	VersionGroup    string `json:"versionGroup"`
 }
 
+type Contracts struct {
+	Total     int        `json:"total"`
+	Contracts []Contract `json:"contracts"`
+}
+
 type Tiers struct {
 	Total int       `json:"total"`
 	Tiers []SLATier `json:"tiers"`
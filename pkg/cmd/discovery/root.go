@@ -2,12 +2,16 @@ package discovery
 
 import (
 	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
 	"github.com/Axway/agent-sdk/pkg/migrate"
 	"github.com/Axway/agent-sdk/pkg/util"
 	"github.com/Axway/agent-sdk/pkg/util/log"
 	"github.com/Axway/agents-mulesoft/pkg/common"
 
 	"github.com/Axway/agent-sdk/pkg/agent"
+	"github.com/Axway/agent-sdk/pkg/agent/handler"
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/Axway/agent-sdk/pkg/jobs"
 
 	corecmd "github.com/Axway/agent-sdk/pkg/cmd"
 	"github.com/Axway/agent-sdk/pkg/cmd/service"
@@ -65,22 +69,107 @@ func run() error {
 func initConfig(centralConfig corecfg.CentralConfig) (interface{}, error) {
 	conf := &config.AgentConfig{
 		CentralConfig:  centralConfig,
-		MulesoftConfig: config.NewMulesoftConfig(RootCmd.GetProperties()),
+		MulesoftConfig: config.NewMulesoftConfig(RootCmd.GetProperties(), false),
 	}
 
 	config.SetConfig(conf)
 
 	if util.IsNotTest() {
-		client = anypoint.NewClient(conf.MulesoftConfig)
-		muleSubClient := subs.NewMuleSubscriptionClient(client)
+		mulesoftConfig := conf.MulesoftConfig
+		tierSettings, err := subs.NewTierSettings(
+			mulesoftConfig.SLATierLimits,
+			mulesoftConfig.SLATierVisible,
+			mulesoftConfig.SLATierAutoApprove,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		client = anypoint.NewClient(mulesoftConfig)
+		muleSubClient := subs.NewMuleSubscriptionClient(client, subs.WithTierSettings(tierSettings))
 		entry := logrus.NewEntry(log.Get())
 
-		agent.RegisterProvisioner(subs.NewProvisioner(muleSubClient, entry))
+		provisioner := subs.NewProvisioner(
+			muleSubClient,
+			entry,
+			subs.WithCentralClient(agent.GetCentralClient(), centralConfig.GetEnvironmentName()),
+			subs.WithTeamCache(agent.GetCacheManager()),
+		)
+		agent.RegisterProvisioner(provisioner)
+		// replaces the access request handler of the provisioner, the access requests waiting for approval are left
+		// to the approval job
+		agent.RegisterResourceEventHandler(subs.AccessRequestHandlerName, subs.NewAccessRequestHandler(
+			handler.NewAccessRequestHandler(provisioner, agent.GetCacheManager(), agent.GetCentralClient(), agent.GetCustomUnitHandler()),
+			agent.GetCacheManager(),
+		))
 		agent.NewAPIKeyAccessRequestBuilder().Register()
 		agent.NewOAuthCredentialRequestBuilder(agent.WithCRDOAuthSecret(), agent.WithCRDIsSuspendable()).Register()
 		agent.NewBasicAuthCredentialRequestBuilder(agent.WithCRDIsSuspendable()).Register()
-
-		discoveryAgent = discovery.NewAgent(conf, client)
+		// the client-id-enforcement policy reads the client id as the api key, along with the client secret
+		agent.NewAPIKeyCredentialRequestBuilder(
+			agent.WithCRDIsSuspendable(),
+			agent.WithCRDProvisionSchemaProperty(
+				provisioning.NewSchemaPropertyBuilder().
+					SetName(common.ClientSecretName).
+					SetLabel(common.ClientSecretLabel).
+					IsString().
+					IsEncrypted()),
+		).Register()
+
+		notifier := subs.NewNotifier(
+			coreapi.NewClient(mulesoftConfig.TLS, mulesoftConfig.ProxyURL),
+			mulesoftConfig.ApprovalWebhookURL,
+			entry,
+		)
+		approvalJob := subs.NewContractApprovalJob(
+			muleSubClient,
+			agent.GetCentralClient(),
+			agent.GetCacheManager(),
+			notifier,
+			mulesoftConfig.ApprovalTimeout,
+			entry,
+		)
+		_, err = jobs.RegisterIntervalJobWithName(approvalJob, mulesoftConfig.ApprovalPollInterval, "Mulesoft Contract Approval")
+		if err != nil {
+			return nil, err
+		}
+
+		reconciliationJob := subs.NewReconciliationJob(
+			muleSubClient,
+			agent.GetCentralClient(),
+			agent.GetCacheManager(),
+			mulesoftConfig.ReconcileReprovision,
+			entry,
+		)
+		_, err = jobs.RegisterIntervalJobWithName(reconciliationJob, mulesoftConfig.ReconcileInterval, "Mulesoft Reconciliation")
+		if err != nil {
+			return nil, err
+		}
+
+		tierSyncJob := subs.NewTierSyncJob(muleSubClient, agent.GetCacheManager(), entry)
+		_, err = jobs.RegisterIntervalJobWithName(tierSyncJob, mulesoftConfig.ReconcileInterval, "Mulesoft SLA Tier Sync")
+		if err != nil {
+			return nil, err
+		}
+
+		if mulesoftConfig.ImportEnabled {
+			importJob := subs.NewImportJob(
+				muleSubClient,
+				agent.GetCentralClient(),
+				agent.GetCacheManager(),
+				centralConfig.GetEnvironmentName(),
+				entry,
+			)
+			_, err = jobs.RegisterIntervalJobWithName(importJob, mulesoftConfig.ReconcileInterval, "Mulesoft Import")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		discoveryAgent, err = discovery.NewAgent(conf, client)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return conf, nil
 }
@@ -0,0 +1,84 @@
+package traceability
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Axway/agents-mulesoft/pkg/traceability"
+)
+
+const (
+	backfillFromFlag  = "from"
+	backfillToFlag    = "to"
+	backfillAPIFlag   = "api"
+	backfillForceFlag = "force"
+	backfillDay       = "2006-01-02"
+)
+
+// newBackfillCmd creates the command reporting the metrics of the monitoring archive for a past time range, the agent
+// runs with its configuration until the metrics are published and stops
+func newBackfillCmd(runAgent func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Report the metrics of the Anypoint Monitoring archive for a past time range",
+		Long: "Report the metrics of the Anypoint Monitoring archive for a past time range, for an API or for all the APIs. " +
+			"The archive keeps the metrics of the last 30 days. The range of an API ends where the metrics reported by the agent start, " +
+			"unless forced. The time of the last report of the APIs is not updated.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := parseBackfillFlags(cmd, time.Now())
+			if err != nil {
+				return err
+			}
+			traceability.SetBackfill(opts)
+			return runAgent(cmd, args)
+		},
+	}
+	cmd.Flags().String(backfillFromFlag, "", "The start of the time range, a date (2006-01-02) or a time (RFC3339), included")
+	cmd.Flags().String(backfillToFlag, "", "The end of the time range, a date (2006-01-02) or a time (RFC3339), excluded. Defaults to now")
+	cmd.Flags().String(backfillAPIFlag, "", "The asset id or API id of the API to report. Defaults to all the APIs")
+	cmd.Flags().Bool(backfillForceFlag, false, "Report the whole time range, even the metrics the agent already reported or may have reported")
+	cmd.MarkFlagRequired(backfillFromFlag)
+	return cmd
+}
+
+func parseBackfillFlags(cmd *cobra.Command, now time.Time) (*traceability.BackfillOptions, error) {
+	fromFlag, _ := cmd.Flags().GetString(backfillFromFlag)
+	toFlag, _ := cmd.Flags().GetString(backfillToFlag)
+	apiID, _ := cmd.Flags().GetString(backfillAPIFlag)
+	force, _ := cmd.Flags().GetBool(backfillForceFlag)
+
+	from, err := parseBackfillTime(fromFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", backfillFromFlag, err)
+	}
+	to := now
+	if toFlag != "" {
+		if to, err = parseBackfillTime(toFlag); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", backfillToFlag, err)
+		}
+	}
+	if to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("--%s must be before --%s and now", backfillFromFlag, backfillToFlag)
+	}
+
+	return &traceability.BackfillOptions{
+		From:  from,
+		To:    to,
+		APIID: apiID,
+		Force: force,
+	}, nil
+}
+
+// parseBackfillTime parses a date, the start of the UTC day, or a time
+func parseBackfillTime(value string) (time.Time, error) {
+	if t, err := time.Parse(backfillDay, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,105 @@
+package traceability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/traceability"
+)
+
+func TestParseBackfillFlags(t *testing.T) {
+	now := time.Date(2024, 10, 22, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		args     []string
+		expected *traceability.BackfillOptions
+	}{
+		{
+			name: "should parse dates and default to now",
+			args: []string{"--from", "2024-10-20", "--api", "1234"},
+			expected: &traceability.BackfillOptions{
+				From:  time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+				To:    now,
+				APIID: "1234",
+			},
+		},
+		{
+			name: "should parse times",
+			args: []string{"--from", "2024-10-20T10:00:00Z", "--to", "2024-10-21T10:00:00Z"},
+			expected: &traceability.BackfillOptions{
+				From: time.Date(2024, 10, 20, 10, 0, 0, 0, time.UTC),
+				To:   time.Date(2024, 10, 21, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "should parse the force flag",
+			args: []string{"--from", "2024-10-20", "--force"},
+			expected: &traceability.BackfillOptions{
+				From:  time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+				To:    now,
+				Force: true,
+			},
+		},
+		{
+			name: "should not backfill after now",
+			args: []string{"--from", "2024-10-20", "--to", "2024-10-25"},
+			expected: &traceability.BackfillOptions{
+				From: time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+				To:   now,
+			},
+		},
+		{
+			name: "should fail for an invalid time",
+			args: []string{"--from", "yesterday"},
+		},
+		{
+			name: "should fail when from is not before to",
+			args: []string{"--from", "2024-10-21", "--to", "2024-10-20"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newBackfillCmd(nil)
+			assert.Nil(t, cmd.ParseFlags(tc.args))
+
+			opts, err := parseBackfillFlags(cmd, now)
+			if tc.expected == nil {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, opts)
+		})
+	}
+}
+
+func TestBackfillCmd(t *testing.T) {
+	defer traceability.SetBackfill(nil)
+
+	ran := false
+	cmd := newBackfillCmd(func(_ *cobra.Command, _ []string) error {
+		ran = true
+		return nil
+	})
+	cmd.SetArgs([]string{"--from", "2024-10-20", "--to", "2024-10-21"})
+	assert.Nil(t, cmd.Execute())
+	assert.True(t, ran)
+	assert.True(t, traceability.IsBackfill())
+
+	// the start of the range is required
+	traceability.SetBackfill(nil)
+	ran = false
+	cmd = newBackfillCmd(func(_ *cobra.Command, _ []string) error {
+		ran = true
+		return nil
+	})
+	cmd.SetArgs([]string{})
+	cmd.SilenceUsage = true
+	assert.NotNil(t, cmd.Execute())
+	assert.False(t, ran)
+	assert.False(t, traceability.IsBackfill())
+}
@@ -9,6 +9,7 @@ import (
 	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
 	libcmd "github.com/elastic/beats/v7/libbeat/cmd"
 	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/spf13/cobra"
 
 	"github.com/Axway/agents-mulesoft/pkg/config"
 	"github.com/Axway/agents-mulesoft/pkg/traceability"
@@ -45,10 +46,25 @@ func init() {
 
 	config.AddConfigProperties(RootCmd.GetProperties(), true)
 	RootCmd.AddCommand(service.GenServiceCmd("pathConfig"))
+	RootCmd.AddCommand(newBackfillCmd(runAgent))
+}
+
+// runAgent initializes and runs the agent from a sub-command
+func runAgent(cmd *cobra.Command, args []string) error {
+	rootCmd := RootCmd.RootCmd()
+	if err := rootCmd.PreRunE(cmd, args); err != nil {
+		return err
+	}
+	return rootCmd.RunE(cmd, args)
 }
 
 // Callback that agent will call to process the execution
 func run() error {
+	// the beat command would parse the backfill sub-command again, the beat is run directly
+	if traceability.IsBackfill() {
+		beatCmd.RunCmd.Run(beatCmd.RunCmd, nil)
+		return nil
+	}
 	return beatCmd.Execute()
 }
 
@@ -69,7 +85,7 @@ func initConfig(centralConfig corecfg.CentralConfig) (interface{}, error) {
 
 	agentConfig := &config.AgentConfig{
 		CentralConfig:  centralConfig,
-		MulesoftConfig: config.NewMulesoftConfig(RootCmd.GetProperties()),
+		MulesoftConfig: config.NewMulesoftConfig(RootCmd.GetProperties(), true),
 	}
 	config.SetConfig(agentConfig)
 	return agentConfig, nil
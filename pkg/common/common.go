@@ -11,9 +11,11 @@ const (
 	AccessCode        = "accessCode"
 	ClientCredentials = "clientCredentials"
 	APIKey            = "apiKey"
+	APIID             = "apiID"
 	AppID             = "appID"
 	AppName           = "appName"
 	Authorization     = "authorization"
+	Imported          = "imported"
 
 	AttrAPIID          = "API ID"
 	AttrAssetID        = "Asset ID"
@@ -27,7 +29,18 @@ const (
 	ClientSecret        = "client-secret"
 	ClientSecretLabel   = "Client Secret"
 	ContractID          = "contractID"
+	ContractRequested   = "contractRequested"
+	ContractStatus      = "contractStatus"
 	CredOrigin          = "credentialsOriginHasHttpBasicAuthenticationHeader"
+	CredentialState     = "credentialState"
+	CustomExpression    = "customExpression"
+
+	ClientIDExpression      = "clientIdExpression"
+	ClientSecretExpression  = "clientSecretExpression"
+	ClientIDEnforcementDesc = "This API requires the client ID and secret of an application for all API requests"
+	ClientIDName            = "clientId"
+	ClientSecretName        = "clientSecret"
+	ClientIDRAMLName        = "clientIdEnforcement"
 
 	ClientCredDesc = "This API supports client credentials for authenticating all API requests"
 	Description    = "description"
@@ -45,6 +58,8 @@ const (
 	BasicAuthOASType  = "http"
 	BasicAuthRAMLType = "Basic Authentication"
 
+	PassThroughRAMLType = "Pass Through"
+
 	Scopes    = "scopes"
 	SLABased  = "sla-based"
 	SlaTier   = "sla-tier"
@@ -53,8 +68,20 @@ const (
 	TierLabel = "SLA Tier"
 	TokenURL  = "tokenUrl"
 
+	// Statuses of a contract in Mulesoft
+	ContractApproved = "APPROVED"
+	ContractPending  = "PENDING"
+	ContractRejected = "REJECTED"
+	ContractRevoked  = "REVOKED"
+
+	// States of the credentials of a managed application
+	CredentialActive    = "active"
+	CredentialSuspended = "suspended"
+	RevokedContracts    = "revokedContracts"
+
 	AxwayAgentSLATierName        = "Axway Agent Tier"
 	AxwayAgentSLATierDescription = "SLA Tier created for Axway Agent Provisioning purposes"
+	AxwayAgentAppDescription     = "Created by Amplify Mulesoft Agent"
 )
 
 const (
@@ -97,15 +124,23 @@ type MetricEvent struct {
 	Metric Metrics
 }
 
+// Metrics - the requests of an API and status code in an observation window. Max, Min, Avg and StdDev are the response
+// times in milliseconds, RequestBytes and ResponseBytes the total size of the requests and responses. Method is the HTTP
+// method of the requests, RequestDisposition whether they were processed or blocked by a policy.
 type Metrics struct {
-	StartTime  time.Time
-	EndTime    time.Time
-	APIID      string
-	Instance   *v1.ResourceInstance
-	ClientID   string
-	StatusCode string
-	Count      int64
-	Max        int64
-	Min        int64
-	Avg        float64
+	StartTime          time.Time
+	EndTime            time.Time
+	APIID              string
+	Instance           *v1.ResourceInstance
+	ClientID           string
+	StatusCode         string
+	Method             string
+	RequestDisposition string
+	Count              int64
+	Max                int64
+	Min                int64
+	Avg                float64
+	StdDev             float64
+	RequestBytes       int64
+	ResponseBytes      int64
 }
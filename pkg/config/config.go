@@ -15,10 +15,12 @@ type props interface {
 	AddStringProperty(name string, defaultVal string, description string)
 	AddStringSliceProperty(name string, defaultVal []string, description string)
 	AddBoolProperty(name string, defaultVal bool, description string)
+	AddIntProperty(name string, defaultVal int, description string, opts ...properties.IntOpt)
 	AddDurationProperty(name string, defaultVal time.Duration, description string, opts ...properties.DurationOpt)
 	StringPropertyValue(name string) string
 	StringSlicePropertyValue(name string) []string
 	BoolPropertyValue(name string) bool
+	IntPropertyValue(name string) int
 	DurationPropertyValue(name string) time.Duration
 }
 
@@ -44,6 +46,24 @@ const (
 	pathCachePath             = "mulesoft.cachePath"
 	pathDiscoverOriginalRaml  = "mulesoft.discoverOriginalRaml"
 	pathUseMonitoringAPI      = "mulesoft.useMonitoringAPI"
+	pathExternalAPIIDTemplate = "mulesoft.externalAPIIDTemplate"
+	pathStageTemplate         = "mulesoft.stageTemplate"
+	pathTitleTemplate         = "mulesoft.titleTemplate"
+	pathVersionTemplate       = "mulesoft.versionTemplate"
+	pathEndpointSources       = "mulesoft.endpointSources"
+	pathSpecValidationMode    = "mulesoft.specValidation.mode"
+	pathSpecLintRules         = "mulesoft.specValidation.lintRules"
+	pathApprovalPollInterval  = "mulesoft.contractApproval.pollInterval"
+	pathApprovalTimeout       = "mulesoft.contractApproval.timeout"
+	pathApprovalWebhookURL    = "mulesoft.contractApproval.webhookUrl"
+	pathSLATierLimits         = "mulesoft.slaTier.limits"
+	pathSLATierVisible        = "mulesoft.slaTier.visible"
+	pathSLATierAutoApprove    = "mulesoft.slaTier.autoApprove"
+	pathReconcileInterval     = "mulesoft.reconciliation.interval"
+	pathReconcileReprovision  = "mulesoft.reconciliation.reprovision"
+	pathImportEnabled         = "mulesoft.import.enabled"
+	pathTrafficLogPath        = "mulesoft.trafficLog.path"
+	pathMetricWorkers         = "mulesoft.metrics.workers"
 )
 
 const (
@@ -89,6 +109,24 @@ type MulesoftConfig struct {
 	ClientSecret          string            `config:"auth.clientSecret"`
 	DiscoverOriginalRaml  bool              `config:"discoverOriginalRaml"`
 	UseMonitoringAPI      bool              `config:"useMonitoringAPI"`
+	ExternalAPIIDTemplate string            `config:"externalAPIIDTemplate"`
+	StageTemplate         string            `config:"stageTemplate"`
+	TitleTemplate         string            `config:"titleTemplate"`
+	VersionTemplate       string            `config:"versionTemplate"`
+	EndpointSources       string            `config:"endpointSources"`
+	SpecValidationMode    string            `config:"specValidation.mode"`
+	SpecLintRules         string            `config:"specValidation.lintRules"`
+	ApprovalPollInterval  time.Duration     `config:"contractApproval.pollInterval"`
+	ApprovalTimeout       time.Duration     `config:"contractApproval.timeout"`
+	ApprovalWebhookURL    string            `config:"contractApproval.webhookUrl"`
+	SLATierLimits         string            `config:"slaTier.limits"`
+	SLATierVisible        bool              `config:"slaTier.visible"`
+	SLATierAutoApprove    bool              `config:"slaTier.autoApprove"`
+	ReconcileInterval     time.Duration     `config:"reconciliation.interval"`
+	ReconcileReprovision  bool              `config:"reconciliation.reprovision"`
+	ImportEnabled         bool              `config:"import.enabled"`
+	TrafficLogPath        string            `config:"trafficLog.path"`
+	MetricWorkers         int               `config:"metrics.workers"`
 }
 
 // ValidateCfg - Validates the gateway config
@@ -135,8 +173,26 @@ func AddConfigProperties(rootProps props, isTA bool) {
 
 	if isTA {
 		rootProps.AddDurationProperty(pathPollInterval, 5*time.Minute, "The interval at which Mulesoft Traceability is checked for updates.", properties.WithLowerLimit(30*time.Second))
+		rootProps.AddStringProperty(pathTrafficLogPath, "", "Path of a file of JSON traffic logs of the Mule runtime or API gateway, one entry per line, reported as transactions. Set to - to read the standard input.")
+		rootProps.AddIntProperty(pathMetricWorkers, 5, "The number of APIs whose metrics are collected at the same time.", properties.WithLowerLimitInt(1))
 	} else {
 		rootProps.AddDurationProperty(pathPollInterval, time.Minute, "The interval at which Mulesoft Discovery is checked for updates.", properties.WithLowerLimit(30*time.Second))
+		rootProps.AddStringProperty(pathExternalAPIIDTemplate, "{{.Asset.ID}}", "Template for the externalAPIID of discovered APIs. Resolved against the Asset, API and ExchangeAsset.")
+		rootProps.AddStringProperty(pathStageTemplate, "{{.API.AssetVersion}}", "Template for the stage of discovered APIs. Resolved against the Asset, API and ExchangeAsset.")
+		rootProps.AddStringProperty(pathTitleTemplate, "{{.Asset.ExchangeAssetName}}", "Template for the title of discovered APIs. Resolved against the Asset, API and ExchangeAsset.")
+		rootProps.AddStringProperty(pathVersionTemplate, "{{.API.AssetVersion}}", "Template for the version of discovered APIs. Resolved against the Asset, API and ExchangeAsset.")
+		rootProps.AddStringProperty(pathEndpointSources, "apimanager", "Comma-separated sources of the consumer facing endpoints of discovered APIs: apimanager, proxy, exchange, external.")
+		rootProps.AddStringProperty(pathSpecValidationMode, "warn", "What to do when a spec fails validation before publishing: off, warn, annotate, block.")
+		rootProps.AddStringProperty(pathSpecLintRules, "", "Comma-separated lint rules applied to specs before publishing: operation-description, security-declared.")
+		rootProps.AddStringProperty(pathApprovalWebhookURL, "", "URL notified with a POST when a contract waiting for approval is approved, rejected or times out.")
+		rootProps.AddStringProperty(pathSLATierLimits, "10/1s", "Comma-separated limits of the SLA tier created by the agent, as maximum requests/time period, e.g. 100/1s,10000/1h.")
+		rootProps.AddBoolProperty(pathSLATierVisible, true, "If the limits of the SLA tier created by the agent are visible to consumers.")
+		rootProps.AddBoolProperty(pathSLATierAutoApprove, true, "If the contracts of the SLA tier created by the agent are approved automatically.")
+		rootProps.AddBoolProperty(pathReconcileReprovision, false, "If contracts removed in Mulesoft are created again, otherwise their access requests fail.")
+		rootProps.AddBoolProperty(pathImportEnabled, false, "If the Mulesoft applications and contracts of discovered APIs are imported into Central.")
+		rootProps.AddDurationProperty(pathApprovalPollInterval, time.Minute, "The interval at which contracts waiting for approval in Mulesoft are checked.", properties.WithLowerLimit(30*time.Second))
+		rootProps.AddDurationProperty(pathApprovalTimeout, 72*time.Hour, "The time a contract may wait for approval in Mulesoft before the access request fails.", properties.WithLowerLimit(time.Minute))
+		rootProps.AddDurationProperty(pathReconcileInterval, time.Hour, "The interval at which access requests and managed applications are reconciled with Mulesoft.", properties.WithLowerLimit(time.Minute))
 	}
 
 	rootProps.AddStringProperty(pathProxyURL, "", "Proxy URL")
@@ -152,8 +208,8 @@ func AddConfigProperties(rootProps props, isTA bool) {
 }
 
 // NewMulesoftConfig - parse the props and create an Mulesoft Configuration structure
-func NewMulesoftConfig(rootProps props) *MulesoftConfig {
-	return &MulesoftConfig{
+func NewMulesoftConfig(rootProps props, isTA bool) *MulesoftConfig {
+	cfg := &MulesoftConfig{
 		AnypointExchangeURL:   rootProps.StringPropertyValue(pathAnypointExchangeURL),
 		AnypointMonitoringURL: rootProps.StringPropertyValue(pathAnypointMonitoringURL),
 		CachePath:             rootProps.StringPropertyValue(pathCachePath),
@@ -173,7 +229,30 @@ func NewMulesoftConfig(rootProps props) *MulesoftConfig {
 			MinVersion:         corecfg.TLSVersionAsValue(rootProps.StringPropertyValue(pathSSLMinVersion)),
 			MaxVersion:         corecfg.TLSVersionAsValue(rootProps.StringPropertyValue(pathSSLMaxVersion)),
 		},
-		DiscoverOriginalRaml: rootProps.BoolPropertyValue(pathDiscoverOriginalRaml),
-		UseMonitoringAPI:     rootProps.BoolPropertyValue(pathUseMonitoringAPI),
+		DiscoverOriginalRaml:  rootProps.BoolPropertyValue(pathDiscoverOriginalRaml),
+		UseMonitoringAPI:      rootProps.BoolPropertyValue(pathUseMonitoringAPI),
+		ExternalAPIIDTemplate: rootProps.StringPropertyValue(pathExternalAPIIDTemplate),
+		StageTemplate:         rootProps.StringPropertyValue(pathStageTemplate),
+		TitleTemplate:         rootProps.StringPropertyValue(pathTitleTemplate),
+		VersionTemplate:       rootProps.StringPropertyValue(pathVersionTemplate),
+		EndpointSources:       rootProps.StringPropertyValue(pathEndpointSources),
+		SpecValidationMode:    rootProps.StringPropertyValue(pathSpecValidationMode),
+		SpecLintRules:         rootProps.StringPropertyValue(pathSpecLintRules),
+		ApprovalWebhookURL:    rootProps.StringPropertyValue(pathApprovalWebhookURL),
+		SLATierLimits:         rootProps.StringPropertyValue(pathSLATierLimits),
+		SLATierVisible:        rootProps.BoolPropertyValue(pathSLATierVisible),
+		SLATierAutoApprove:    rootProps.BoolPropertyValue(pathSLATierAutoApprove),
+		ReconcileReprovision:  rootProps.BoolPropertyValue(pathReconcileReprovision),
+		ImportEnabled:         rootProps.BoolPropertyValue(pathImportEnabled),
+		TrafficLogPath:        rootProps.StringPropertyValue(pathTrafficLogPath),
+		MetricWorkers:         rootProps.IntPropertyValue(pathMetricWorkers),
 	}
+
+	// the provisioning durations are only registered for the discovery agent
+	if !isTA {
+		cfg.ApprovalPollInterval = rootProps.DurationPropertyValue(pathApprovalPollInterval)
+		cfg.ApprovalTimeout = rootProps.DurationPropertyValue(pathApprovalTimeout)
+		cfg.ReconcileInterval = rootProps.DurationPropertyValue(pathReconcileInterval)
+	}
+	return cfg
 }
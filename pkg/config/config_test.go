@@ -68,6 +68,10 @@ func (f *fakeProps) AddBoolProperty(name string, defaultVal bool, description st
 	f.props[name] = propData{"bool", description, defaultVal}
 }
 
+func (f *fakeProps) AddIntProperty(name string, defaultVal int, description string, opts ...properties.IntOpt) {
+	f.props[name] = propData{"int", description, defaultVal}
+}
+
 func (f *fakeProps) StringPropertyValue(name string) string {
 	if prop, ok := f.props[name]; ok {
 		return prop.val.(string)
@@ -96,6 +100,13 @@ func (f *fakeProps) BoolPropertyValue(name string) bool {
 	return false
 }
 
+func (f *fakeProps) IntPropertyValue(name string) int {
+	if prop, ok := f.props[name]; ok {
+		return prop.val.(int)
+	}
+	return 0
+}
+
 func TestKongProperties(t *testing.T) {
 	newProps := &fakeProps{props: map[string]propData{}}
 
@@ -118,9 +129,25 @@ func TestKongProperties(t *testing.T) {
 	assert.Contains(t, newProps.props, pathProxyURL)
 	assert.Contains(t, newProps.props, pathCachePath)
 	assert.Contains(t, newProps.props, pathDiscoverOriginalRaml)
+	assert.Contains(t, newProps.props, pathExternalAPIIDTemplate)
+	assert.Contains(t, newProps.props, pathStageTemplate)
+	assert.Contains(t, newProps.props, pathTitleTemplate)
+	assert.Contains(t, newProps.props, pathVersionTemplate)
+	assert.Contains(t, newProps.props, pathEndpointSources)
+	assert.Contains(t, newProps.props, pathSpecValidationMode)
+	assert.Contains(t, newProps.props, pathSpecLintRules)
+	assert.Contains(t, newProps.props, pathApprovalPollInterval)
+	assert.Contains(t, newProps.props, pathApprovalTimeout)
+	assert.Contains(t, newProps.props, pathApprovalWebhookURL)
+	assert.Contains(t, newProps.props, pathSLATierLimits)
+	assert.Contains(t, newProps.props, pathSLATierVisible)
+	assert.Contains(t, newProps.props, pathSLATierAutoApprove)
+	assert.Contains(t, newProps.props, pathReconcileInterval)
+	assert.Contains(t, newProps.props, pathReconcileReprovision)
+	assert.Contains(t, newProps.props, pathImportEnabled)
 
 	// validate defaults
-	cfg := NewMulesoftConfig(newProps)
+	cfg := NewMulesoftConfig(newProps, false)
 	assert.Equal(t, "https://anypoint.mulesoft.com", cfg.AnypointExchangeURL)
 	assert.Equal(t, "", cfg.Environment)
 	assert.Equal(t, "", cfg.OrgName)
@@ -138,6 +165,23 @@ func TestKongProperties(t *testing.T) {
 	assert.Equal(t, "", cfg.ProxyURL)
 	assert.Equal(t, "/data", cfg.CachePath)
 	assert.Equal(t, false, cfg.DiscoverOriginalRaml)
+	assert.Equal(t, "{{.Asset.ID}}", cfg.ExternalAPIIDTemplate)
+	assert.Equal(t, "{{.API.AssetVersion}}", cfg.StageTemplate)
+	assert.Equal(t, "{{.Asset.ExchangeAssetName}}", cfg.TitleTemplate)
+	assert.Equal(t, "{{.API.AssetVersion}}", cfg.VersionTemplate)
+	assert.Equal(t, "apimanager", cfg.EndpointSources)
+	assert.Equal(t, "warn", cfg.SpecValidationMode)
+	assert.Equal(t, "", cfg.SpecLintRules)
+	assert.Equal(t, time.Minute, cfg.ApprovalPollInterval)
+	assert.Equal(t, 72*time.Hour, cfg.ApprovalTimeout)
+	assert.Equal(t, "", cfg.ApprovalWebhookURL)
+	assert.Equal(t, "10/1s", cfg.SLATierLimits)
+	assert.Equal(t, true, cfg.SLATierVisible)
+	assert.Equal(t, true, cfg.SLATierAutoApprove)
+	assert.Equal(t, time.Hour, cfg.ReconcileInterval)
+	assert.Equal(t, false, cfg.ReconcileReprovision)
+	assert.Equal(t, false, cfg.ImportEnabled)
+	assert.Equal(t, "", cfg.TrafficLogPath)
 
 	// validate changed values
 	newProps.props[pathAnypointExchangeURL] = propData{"string", "", "ok.com"}
@@ -158,7 +202,7 @@ func TestKongProperties(t *testing.T) {
 	newProps.props[pathCachePath] = propData{"string", "", "./config"}
 	newProps.props[pathDiscoverOriginalRaml] = propData{"bool", "", true}
 
-	cfg = NewMulesoftConfig(newProps)
+	cfg = NewMulesoftConfig(newProps, false)
 	assert.Equal(t, "ok.com", cfg.AnypointExchangeURL)
 	assert.Equal(t, "env", cfg.Environment)
 	assert.Equal(t, "orgName", cfg.OrgName)
@@ -177,3 +221,28 @@ func TestKongProperties(t *testing.T) {
 	assert.Equal(t, "./config", cfg.CachePath)
 	assert.Equal(t, true, cfg.DiscoverOriginalRaml)
 }
+
+func TestTraceabilityProperties(t *testing.T) {
+	newProps := &fakeProps{props: map[string]propData{}}
+
+	AddConfigProperties(newProps, true)
+	assert.Contains(t, newProps.props, pathTrafficLogPath)
+	assert.NotContains(t, newProps.props, pathImportEnabled)
+	assert.NotContains(t, newProps.props, pathApprovalPollInterval)
+	assert.NotContains(t, newProps.props, pathApprovalTimeout)
+	assert.NotContains(t, newProps.props, pathReconcileInterval)
+
+	cfg := NewMulesoftConfig(newProps, true)
+	assert.Equal(t, 5*time.Minute, cfg.PollInterval)
+	assert.Equal(t, "", cfg.TrafficLogPath)
+
+	assert.Equal(t, 5, cfg.MetricWorkers)
+	assert.Zero(t, cfg.ApprovalPollInterval)
+	assert.Zero(t, cfg.ReconcileInterval)
+
+	newProps.props[pathTrafficLogPath] = propData{"string", "", "/logs/traffic.log"}
+	newProps.props[pathMetricWorkers] = propData{"int", "", 20}
+	cfg = NewMulesoftConfig(newProps, true)
+	assert.Equal(t, "/logs/traffic.log", cfg.TrafficLogPath)
+	assert.Equal(t, 20, cfg.MetricWorkers)
+}
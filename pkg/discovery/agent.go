@@ -27,15 +27,33 @@ type Agent struct {
 }
 
 // NewAgent creates a new agent
-func NewAgent(cfg *config.AgentConfig, client anypoint.Client) (agent *Agent) {
+func NewAgent(cfg *config.AgentConfig, client anypoint.Client) (*Agent, error) {
 	buffer := 5
 	apiChan := make(chan *ServiceDetail, buffer)
 
+	naming, err := newNamingStrategy(cfg.MulesoftConfig)
+	if err != nil {
+		return nil, err
+	}
+	endpointSources, err := parseEndpointSources(cfg.MulesoftConfig.EndpointSources)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := newSpecValidator(cfg.MulesoftConfig.SpecValidationMode, cfg.MulesoftConfig.SpecLintRules)
+	if err != nil {
+		return nil, err
+	}
+
 	pub := &publisher{
 		apiChan:     apiChan,
 		stopPublish: make(chan bool),
 		publishAPI:  coreAgent.PublishAPI,
 	}
+	// instances published with a previous externalAPIID or stage are removed, whatever the naming strategy in use
+	if centralClient := coreAgent.GetCentralClient(); centralClient != nil {
+		pub.instances = newInstanceIndex(coreAgent.GetCacheManager())
+		pub.deleteInstance = centralClient.DeleteAPIServiceInstance
+	}
 
 	c := cache.New()
 
@@ -46,6 +64,9 @@ func NewAgent(cfg *config.AgentConfig, client anypoint.Client) (agent *Agent) {
 		client:               client,
 		cache:                c,
 		discoverOriginalRaml: cfg.MulesoftConfig.DiscoverOriginalRaml,
+		naming:               naming,
+		endpointSources:      endpointSources,
+		validator:            validator,
 	}
 
 	disc := &discovery{
@@ -59,7 +80,7 @@ func NewAgent(cfg *config.AgentConfig, client anypoint.Client) (agent *Agent) {
 		serviceHandler:    svcHandler,
 	}
 
-	return newAgent(client, disc, pub)
+	return newAgent(client, disc, pub), nil
 }
 
 func newAgent(
@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/discovery/mocks"
 
+	coreAgent "github.com/Axway/agent-sdk/pkg/agent"
 	corecfg "github.com/Axway/agent-sdk/pkg/config"
 	"github.com/Axway/agents-mulesoft/pkg/config"
 
@@ -20,12 +22,27 @@ func TestCleanTags(t *testing.T) {
 func TestAgent(t *testing.T) {
 	cfg := newConfig()
 	mockClient := &anypoint.MockAnypointClient{}
-	a := NewAgent(cfg, mockClient)
+	a, err := NewAgent(cfg, mockClient)
+	assert.Nil(t, err)
 	assert.NotNil(t, a)
 	assert.Equal(t, mockClient, a.client)
 	assert.NotNil(t, a.discovery)
 	assert.NotNil(t, a.publisher)
 	assert.NotNil(t, a.stopAgent)
+
+	// the instances published with a previous naming strategy are removed with the default templates as well
+	coreAgent.InitializeForTest(&mocks.MockCentralClient{})
+	defer coreAgent.InitializeForTest(nil)
+	a, err = NewAgent(cfg, mockClient)
+	assert.Nil(t, err)
+	pub := a.publisher.(*publisher)
+	assert.NotNil(t, pub.instances)
+	assert.NotNil(t, pub.deleteInstance)
+
+	cfg.MulesoftConfig.StageTemplate = "{{.API.Unknown}}"
+	a, err = NewAgent(cfg, mockClient)
+	assert.NotNil(t, err)
+	assert.Nil(t, a)
 }
 
 func TestAgent_Run(t *testing.T) {
@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+// Locations of a credential in a request, named as in the OAS api key security scheme.
+const (
+	credentialInHeader = "header"
+	credentialInQuery  = "query"
+)
+
+// credentialExpression matches the DataWeave expressions of the client-id-enforcement policy that read a header or a
+// query parameter, e.g. #[attributes.headers['client_id']] or #[attributes.queryParams.client_id]
+var credentialExpression = regexp.MustCompile(
+	`^#\[\s*attributes\.(headers|queryParams)(?:\[\s*['"]([^'"]+)['"]\s*\]|\.([A-Za-z_][\w-]*))\s*\]$`,
+)
+
+// credentialLocation is the header or the query parameter that holds a credential, Scheme is the name of its security
+// scheme in the spec.
+type credentialLocation struct {
+	Scheme string
+	In     string
+	Name   string
+}
+
+// clientIDCredentials are the locations of the client id and the optional client secret of a client-id-enforcement
+// policy with a custom expression origin.
+type clientIDCredentials struct {
+	ClientID     credentialLocation
+	ClientSecret *credentialLocation
+}
+
+// parseClientIDCredentials reads the client id and client secret expressions of a client-id-enforcement policy.
+func parseClientIDCredentials(config map[string]interface{}) (*clientIDCredentials, error) {
+	idExpression, _ := config[common.ClientIDExpression].(string)
+	clientID, err := parseCredentialExpression(common.ClientIDName, idExpression)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s: %s", common.ClientIDExpression, err)
+	}
+	creds := &clientIDCredentials{ClientID: *clientID}
+
+	if secretExpression, _ := config[common.ClientSecretExpression].(string); strings.TrimSpace(secretExpression) != "" {
+		creds.ClientSecret, err = parseCredentialExpression(common.ClientSecretName, secretExpression)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported %s: %s", common.ClientSecretExpression, err)
+		}
+	}
+	return creds, nil
+}
+
+func parseCredentialExpression(scheme, expression string) (*credentialLocation, error) {
+	matches := credentialExpression.FindStringSubmatch(strings.TrimSpace(expression))
+	if matches == nil {
+		return nil, fmt.Errorf("'%s' does not read a header or a query parameter", expression)
+	}
+	location := &credentialLocation{Scheme: scheme, In: credentialInHeader, Name: matches[2]}
+	if matches[1] == "queryParams" {
+		location.In = credentialInQuery
+	}
+	if location.Name == "" {
+		location.Name = matches[3]
+	}
+	return location, nil
+}
+
+// locations returns the locations of the credentials, the client id first.
+func (c *clientIDCredentials) locations() []credentialLocation {
+	locations := []credentialLocation{c.ClientID}
+	if c.ClientSecret != nil {
+		locations = append(locations, *c.ClientSecret)
+	}
+	return locations
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+func TestParseClientIDCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		expected []credentialLocation
+		hasErr   bool
+	}{
+		{
+			name: "should read the client id and secret from headers",
+			config: map[string]interface{}{
+				common.ClientIDExpression:     "#[attributes.headers['client_id']]",
+				common.ClientSecretExpression: "#[attributes.headers[\"client_secret\"]]",
+			},
+			expected: []credentialLocation{
+				{Scheme: common.ClientIDName, In: credentialInHeader, Name: "client_id"},
+				{Scheme: common.ClientSecretName, In: credentialInHeader, Name: "client_secret"},
+			},
+		},
+		{
+			name: "should read the client id and secret from query parameters",
+			config: map[string]interface{}{
+				common.ClientIDExpression:     " #[ attributes.queryParams.clientId ] ",
+				common.ClientSecretExpression: "#[attributes.queryParams['client-secret']]",
+			},
+			expected: []credentialLocation{
+				{Scheme: common.ClientIDName, In: credentialInQuery, Name: "clientId"},
+				{Scheme: common.ClientSecretName, In: credentialInQuery, Name: "client-secret"},
+			},
+		},
+		{
+			name: "should read the client id without a client secret",
+			config: map[string]interface{}{
+				common.ClientIDExpression:     "#[attributes.headers.client_id]",
+				common.ClientSecretExpression: "",
+			},
+			expected: []credentialLocation{
+				{Scheme: common.ClientIDName, In: credentialInHeader, Name: "client_id"},
+			},
+		},
+		{
+			name:   "should return an error without a client id expression",
+			config: map[string]interface{}{},
+			hasErr: true,
+		},
+		{
+			name: "should return an error for a client id read from the payload",
+			config: map[string]interface{}{
+				common.ClientIDExpression: "#[payload.client_id]",
+			},
+			hasErr: true,
+		},
+		{
+			name: "should return an error for a client secret read from the payload",
+			config: map[string]interface{}{
+				common.ClientIDExpression:     "#[attributes.headers['client_id']]",
+				common.ClientSecretExpression: "#[payload.client_secret]",
+			},
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			creds, err := parseClientIDCredentials(tc.config)
+			if tc.hasErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, creds.locations())
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package discovery
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
+
+// Endpoint sources that can be published as consumer facing endpoints.
+const (
+	// endpointSourceAPIManager is the API Manager consumer endpoint, with the proxy path appended when the API is proxied.
+	endpointSourceAPIManager = "apimanager"
+	// endpointSourceProxy is the proxy URI configured in API Manager.
+	endpointSourceProxy = "proxy"
+	// endpointSourceExchange are the managed instances listed in Exchange for the environment of the API.
+	endpointSourceExchange = "exchange"
+	// endpointSourceExternal are the external instances listed in Exchange.
+	endpointSourceExternal = "external"
+
+	exchangeExternalInstanceType = "external"
+)
+
+var validEndpointSources = map[string]bool{
+	endpointSourceAPIManager: true,
+	endpointSourceProxy:      true,
+	endpointSourceExchange:   true,
+	endpointSourceExternal:   true,
+}
+
+// parseEndpointSources splits the CSV of endpoint sources. Defaults to the API Manager endpoint when empty.
+func parseEndpointSources(sourcesCSV string) ([]string, error) {
+	sources := cleanTags(sourcesCSV)
+	if len(sources) == 0 {
+		return []string{endpointSourceAPIManager}, nil
+	}
+	for _, src := range sources {
+		if !validEndpointSources[src] {
+			return nil, fmt.Errorf("invalid mulesoft configuration: unknown endpoint source '%s'", src)
+		}
+	}
+	return sources, nil
+}
+
+// collectEndpoints gathers the consumer facing endpoints of an API from the configured sources, in the order of
+// the sources, without duplicates.
+func collectEndpoints(sources []string, api *anypoint.API, proxyURI string, exchangeAsset *anypoint.ExchangeAsset) []string {
+	endpoints := []string{}
+	seen := map[string]bool{}
+	add := func(endpoint string) {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" || seen[endpoint] {
+			return
+		}
+		if _, err := url.ParseRequestURI(endpoint); err != nil {
+			return
+		}
+		seen[endpoint] = true
+		endpoints = append(endpoints, endpoint)
+	}
+
+	for _, src := range sources {
+		switch src {
+		case endpointSourceAPIManager:
+			add(api.EndpointURI)
+		case endpointSourceProxy:
+			add(proxyURI)
+		case endpointSourceExchange, endpointSourceExternal:
+			if exchangeAsset == nil {
+				continue
+			}
+			for _, inst := range exchangeAsset.Instances {
+				isExternal := inst.InstanceType == exchangeExternalInstanceType
+				if isExternal != (src == endpointSourceExternal) {
+					continue
+				}
+				// Managed instances of other environments are not reachable through this API
+				if !isExternal && inst.EnvironmentID != "" && api.EnvironmentID != "" && inst.EnvironmentID != api.EnvironmentID {
+					continue
+				}
+				add(inst.EndpointURI)
+			}
+		}
+	}
+	return endpoints
+}
+
+// toEndpointDefinitions converts the endpoint URLs to the endpoint definitions of the service body.
+func toEndpointDefinitions(endpoints []string) []apic.EndpointDefinition {
+	defs := []apic.EndpointDefinition{}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		port := 443
+		if u.Scheme == "http" {
+			port = 80
+		}
+		if p, err := strconv.Atoi(u.Port()); err == nil {
+			port = p
+		}
+
+		basePath := u.Path
+		if basePath == "" {
+			basePath = "/"
+		}
+
+		defs = append(defs, apic.EndpointDefinition{
+			Host:     u.Hostname(),
+			Port:     int32(port),
+			Protocol: u.Scheme,
+			BasePath: basePath,
+		})
+	}
+	return defs
+}
@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
+
+func TestParseEndpointSources(t *testing.T) {
+	sources, err := parseEndpointSources("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{endpointSourceAPIManager}, sources)
+
+	sources, err = parseEndpointSources("apiManager, Exchange,external")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{endpointSourceAPIManager, endpointSourceExchange, endpointSourceExternal}, sources)
+
+	_, err = parseEndpointSources("apimanager,cloudhub")
+	assert.NotNil(t, err)
+}
+
+func TestCollectEndpoints(t *testing.T) {
+	api := &anypoint.API{
+		EndpointURI:   "https://petstore.us-e2.cloudhub.io/api",
+		EnvironmentID: "env-1",
+	}
+	exchAsset := &anypoint.ExchangeAsset{
+		Instances: []anypoint.ExchangeAPIInstance{
+			{EndpointURI: "https://petstore.us-e2.cloudhub.io/api", EnvironmentID: "env-1", InstanceType: "managed"},
+			{EndpointURI: "https://petstore.eu1.cloudhub.io/api", EnvironmentID: "env-1", InstanceType: "managed"},
+			{EndpointURI: "https://petstore-dev.cloudhub.io/api", EnvironmentID: "env-2", InstanceType: "managed"},
+			{EndpointURI: "https://api.example.com/petstore", InstanceType: "external"},
+			{EndpointURI: "not a url", InstanceType: "external"},
+		},
+	}
+	proxyURI := "http://0.0.0.0:8081/api"
+
+	tests := []struct {
+		name     string
+		sources  []string
+		expected []string
+	}{
+		{
+			name:     "should only return the API Manager endpoint by default",
+			sources:  []string{endpointSourceAPIManager},
+			expected: []string{"https://petstore.us-e2.cloudhub.io/api"},
+		},
+		{
+			name:    "should return the exchange instances of the api environment without duplicates",
+			sources: []string{endpointSourceAPIManager, endpointSourceExchange},
+			expected: []string{
+				"https://petstore.us-e2.cloudhub.io/api",
+				"https://petstore.eu1.cloudhub.io/api",
+			},
+		},
+		{
+			name:     "should return external instances and the proxy uri",
+			sources:  []string{endpointSourceExternal, endpointSourceProxy},
+			expected: []string{"https://api.example.com/petstore", "http://0.0.0.0:8081/api"},
+		},
+		{
+			name:     "should return nothing when there are no sources",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, collectEndpoints(tc.sources, api, proxyURI, exchAsset))
+		})
+	}
+}
+
+func TestToEndpointDefinitions(t *testing.T) {
+	defs := toEndpointDefinitions([]string{
+		"https://petstore.us-e2.cloudhub.io/api",
+		"http://petstore.internal:8081",
+		"/relative",
+	})
+	assert.Equal(t, []apic.EndpointDefinition{
+		{Host: "petstore.us-e2.cloudhub.io", Port: 443, Protocol: "https", BasePath: "/api"},
+		{Host: "petstore.internal", Port: 8081, Protocol: "http", BasePath: "/"},
+	}, defs)
+}
+
+func TestServiceHandlerMultipleEndpoints(t *testing.T) {
+	exchAsset := exchangeAsset
+	exchAsset.Instances = []anypoint.ExchangeAPIInstance{
+		{EndpointURI: "https://petstore3.eu1.cloudhub.io", EnvironmentID: asset.APIs[0].EnvironmentID},
+	}
+	mc := &anypoint.MockAnypointClient{}
+	mc.On("GetPolicies").Return([]anypoint.Policy{}, nil)
+	mc.On("GetExchangeAsset").Return(&exchAsset, nil)
+	mc.On("GetExchangeFileContent").Return([]byte(`{"openapi":"3.0.1","servers":[{"url":"https://abc.com"}], "paths":{}, "info":{"title":"petstore3"}}`), false, nil)
+	mc.On("GetExchangeAssetIcon").Return("", "", nil)
+
+	sh := &serviceHandler{
+		client:          mc,
+		cache:           cache.New(),
+		naming:          defaultNaming(t),
+		endpointSources: []string{endpointSourceAPIManager, endpointSourceExchange},
+	}
+	api := asset.APIs[0]
+	sd, err := sh.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.Len(t, sd.Endpoints, 2)
+
+	spec, err := openapi3.NewLoader().LoadFromData(sd.APISpec)
+	assert.Nil(t, err)
+	assert.Len(t, spec.Servers, 2)
+	assert.Equal(t, api.EndpointURI, spec.Servers[0].URL)
+	assert.Equal(t, "https://petstore3.eu1.cloudhub.io", spec.Servers[1].URL)
+}
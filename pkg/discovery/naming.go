@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/config"
+)
+
+const (
+	defaultExternalAPIIDTemplate = "{{.Asset.ID}}"
+	defaultStageTemplate         = "{{.API.AssetVersion}}"
+	defaultTitleTemplate         = "{{.Asset.ExchangeAssetName}}"
+	defaultVersionTemplate       = "{{.API.AssetVersion}}"
+	defaultFingerprint           = defaultExternalAPIIDTemplate + "|" + defaultStageTemplate + "|" + defaultTitleTemplate + "|" + defaultVersionTemplate
+)
+
+// namingData is the data made available to the naming templates.
+type namingData struct {
+	Asset         *anypoint.Asset
+	API           *anypoint.API
+	ExchangeAsset *anypoint.ExchangeAsset
+}
+
+// namingStrategy resolves the externalAPIID, stage, title and version of a discovered API from templates.
+type namingStrategy struct {
+	externalAPIID *template.Template
+	stage         *template.Template
+	title         *template.Template
+	version       *template.Template
+	fingerprint   string
+}
+
+// newNamingStrategy parses the naming templates in the config and verifies that they resolve against the
+// Asset, API and ExchangeAsset types. Empty templates fall back to the default behavior.
+func newNamingStrategy(cfg *config.MulesoftConfig) (*namingStrategy, error) {
+	sources := map[string]string{
+		"externalAPIIDTemplate": valueOrDefault(cfg.ExternalAPIIDTemplate, defaultExternalAPIIDTemplate),
+		"stageTemplate":         valueOrDefault(cfg.StageTemplate, defaultStageTemplate),
+		"titleTemplate":         valueOrDefault(cfg.TitleTemplate, defaultTitleTemplate),
+		"versionTemplate":       valueOrDefault(cfg.VersionTemplate, defaultVersionTemplate),
+	}
+
+	tmpls := map[string]*template.Template{}
+	sample := &namingData{
+		Asset:         &anypoint.Asset{},
+		API:           &anypoint.API{},
+		ExchangeAsset: &anypoint.ExchangeAsset{},
+	}
+	for name, src := range sources {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mulesoft configuration: %s could not be parsed: %s", name, err)
+		}
+		if _, err := execute(tmpl, sample); err != nil {
+			return nil, fmt.Errorf("invalid mulesoft configuration: %s could not be resolved: %s", name, err)
+		}
+		tmpls[name] = tmpl
+	}
+
+	fingerprint := strings.Join([]string{
+		sources["externalAPIIDTemplate"],
+		sources["stageTemplate"],
+		sources["titleTemplate"],
+		sources["versionTemplate"],
+	}, "|")
+	// The default strategy keeps an empty fingerprint so that checksums of already published APIs do not change.
+	if fingerprint == defaultFingerprint {
+		fingerprint = ""
+	}
+
+	return &namingStrategy{
+		externalAPIID: tmpls["externalAPIIDTemplate"],
+		stage:         tmpls["stageTemplate"],
+		title:         tmpls["titleTemplate"],
+		version:       tmpls["versionTemplate"],
+		fingerprint:   fingerprint,
+	}, nil
+}
+
+// ExternalAPIID returns the externalAPIID for the API.
+func (n *namingStrategy) ExternalAPIID(data *namingData) (string, error) {
+	return resolve("externalAPIID", n.externalAPIID, data)
+}
+
+// Stage returns the stage for the API.
+func (n *namingStrategy) Stage(data *namingData) (string, error) {
+	return resolve("stage", n.stage, data)
+}
+
+// Title returns the title for the API.
+func (n *namingStrategy) Title(data *namingData) (string, error) {
+	return resolve("title", n.title, data)
+}
+
+// Version returns the version for the API.
+func (n *namingStrategy) Version(data *namingData) (string, error) {
+	return resolve("version", n.version, data)
+}
+
+// Fingerprint identifies the templates in use so that a change of strategy can be detected. Empty for the default strategy.
+func (n *namingStrategy) Fingerprint() string {
+	return n.fingerprint
+}
+
+func resolve(name string, tmpl *template.Template, data *namingData) (string, error) {
+	val, err := execute(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	if val == "" {
+		return "", fmt.Errorf("%s resolved to an empty value", name)
+	}
+	return val, nil
+}
+
+func execute(tmpl *template.Template, data *namingData) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func valueOrDefault(val, def string) string {
+	if strings.TrimSpace(val) == "" {
+		return def
+	}
+	return val
+}
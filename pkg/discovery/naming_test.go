@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/Axway/agents-mulesoft/pkg/config"
+)
+
+func defaultNaming(t *testing.T) *namingStrategy {
+	naming, err := newNamingStrategy(&config.MulesoftConfig{})
+	assert.Nil(t, err)
+	return naming
+}
+
+func TestNewNamingStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.MulesoftConfig
+		hasErr      bool
+		fingerprint bool
+	}{
+		{
+			name: "should use the defaults when no templates are configured",
+			cfg:  &config.MulesoftConfig{},
+		},
+		{
+			name: "should keep an empty fingerprint when the defaults are configured explicitly",
+			cfg: &config.MulesoftConfig{
+				ExternalAPIIDTemplate: defaultExternalAPIIDTemplate,
+				StageTemplate:         defaultStageTemplate,
+				TitleTemplate:         defaultTitleTemplate,
+				VersionTemplate:       defaultVersionTemplate,
+			},
+		},
+		{
+			name: "should accept templates over the asset, api and exchange asset",
+			cfg: &config.MulesoftConfig{
+				ExternalAPIIDTemplate: "{{.API.ID}}",
+				StageTemplate:         "{{.API.ProductVersion}}",
+				TitleTemplate:         "{{.ExchangeAsset.Name}} {{.API.InstanceLabel}}",
+				VersionTemplate:       "{{.ExchangeAsset.Version}}",
+			},
+			fingerprint: true,
+		},
+		{
+			name:        "should keep the default externalAPIID when only the stage is configured",
+			cfg:         &config.MulesoftConfig{StageTemplate: "{{.API.ProductVersion}}"},
+			fingerprint: true,
+		},
+		{
+			name:   "should return an error when a template cannot be parsed",
+			cfg:    &config.MulesoftConfig{StageTemplate: "{{.API.ProductVersion"},
+			hasErr: true,
+		},
+		{
+			name:   "should return an error when a template references an unknown field",
+			cfg:    &config.MulesoftConfig{TitleTemplate: "{{.Asset.Unknown}}"},
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			naming, err := newNamingStrategy(tc.cfg)
+			if tc.hasErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, naming)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.fingerprint, naming.Fingerprint() != "")
+		})
+	}
+}
+
+func TestNamingStrategyResolve(t *testing.T) {
+	naming, err := newNamingStrategy(&config.MulesoftConfig{
+		ExternalAPIIDTemplate: "{{.API.ID}}",
+		StageTemplate:         "{{.API.ProductVersion}}",
+		TitleTemplate:         "{{.ExchangeAsset.Name}}",
+		VersionTemplate:       "{{.ExchangeAsset.Version}}",
+	})
+	assert.Nil(t, err)
+
+	data := &namingData{
+		Asset:         &asset,
+		API:           &asset.APIs[0],
+		ExchangeAsset: &exchangeAsset,
+	}
+	id, err := naming.ExternalAPIID(data)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprint(apiID), id)
+
+	stage, err := naming.Stage(data)
+	assert.Nil(t, err)
+	assert.Equal(t, asset.APIs[0].ProductVersion, stage)
+
+	title, err := naming.Title(data)
+	assert.Nil(t, err)
+	assert.Equal(t, exchangeAsset.Name, title)
+
+	version, err := naming.Version(data)
+	assert.Nil(t, err)
+	assert.Equal(t, exchangeAsset.Version, version)
+
+	// an empty value is not a usable name
+	data.ExchangeAsset = &anypoint.ExchangeAsset{}
+	_, err = naming.Title(data)
+	assert.NotNil(t, err)
+}
+
+func TestServiceHandlerNamingStrategy(t *testing.T) {
+	naming, err := newNamingStrategy(&config.MulesoftConfig{
+		ExternalAPIIDTemplate: "{{.API.ID}}",
+		StageTemplate:         "{{.API.ProductVersion}}",
+	})
+	assert.Nil(t, err)
+
+	mc := &anypoint.MockAnypointClient{}
+	mc.On("GetPolicies").Return([]anypoint.Policy{}, nil)
+	mc.On("GetExchangeAsset").Return(&exchangeAsset, nil)
+	mc.On("GetExchangeFileContent").Return([]byte(`{"openapi":"3.0.1","servers":[{"url":"https://abc.com"}], "paths":{}, "info":{"title":"petstore3"}}`), false, nil)
+	mc.On("GetExchangeAssetIcon").Return("", "", nil)
+
+	sh := &serviceHandler{
+		client: mc,
+		cache:  cache.New(),
+		naming: naming,
+	}
+	api := asset.APIs[0]
+	sd, err := sh.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprint(api.ID), sd.ID)
+	assert.Equal(t, api.ProductVersion, sd.Stage)
+	assert.Equal(t, asset.ExchangeAssetName, sd.Title)
+	assert.Equal(t, api.AssetVersion, sd.Version)
+	assert.Equal(t, fmt.Sprint(asset.ID), sd.AgentDetails[common.AttrAssetID])
+
+	// the checksum differs from the one computed with the default strategy so the api is published again
+	defaultSH := &serviceHandler{
+		client: mc,
+		cache:  cache.New(),
+		naming: defaultNaming(t),
+	}
+	api = asset.APIs[0]
+	defaultSD, err := defaultSH.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.NotEqual(t, defaultSD.AgentDetails[common.AttrChecksum], sd.AgentDetails[common.AttrChecksum])
+}
@@ -3,16 +3,80 @@ package discovery
 import (
 	coreAgent "github.com/Axway/agent-sdk/pkg/agent"
 	"github.com/Axway/agent-sdk/pkg/apic"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
 	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/common"
 	"github.com/Axway/agents-mulesoft/pkg/config"
 	"github.com/sirupsen/logrus"
 )
 
+type instanceCache interface {
+	GetAPIServiceInstanceKeys() []string
+	GetAPIServiceInstanceByID(id string) (*v1.ResourceInstance, error)
+}
+
 // publisher implements the Repeater interface. Waits for for items on a channel and publishes them to central
 type publisher struct {
-	apiChan     chan *ServiceDetail
-	stopPublish chan bool
-	publishAPI  coreAgent.PublishAPIFunc
+	apiChan        chan *ServiceDetail
+	stopPublish    chan bool
+	publishAPI     coreAgent.PublishAPIFunc
+	instances      *instanceIndex
+	deleteInstance func(name string) error
+}
+
+// instanceIndex indexes the cached instances by the id of their Mulesoft API. The cache is only read for the
+// instances added since the previous lookup.
+type instanceIndex struct {
+	cache   instanceCache
+	apiIDs  map[string]string
+	byAPIID map[string]map[string]bool
+}
+
+func newInstanceIndex(cache instanceCache) *instanceIndex {
+	return &instanceIndex{
+		cache:   cache,
+		apiIDs:  map[string]string{},
+		byAPIID: map[string]map[string]bool{},
+	}
+}
+
+// lookup returns the cached instances of a Mulesoft API
+func (i *instanceIndex) lookup(apiID string) []*v1.ResourceInstance {
+	i.sync()
+	instances := []*v1.ResourceInstance{}
+	for key := range i.byAPIID[apiID] {
+		instance, err := i.cache.GetAPIServiceInstanceByID(key)
+		if err != nil || instance == nil {
+			delete(i.byAPIID[apiID], key)
+			delete(i.apiIDs, key)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// sync indexes the instances added to the cache
+func (i *instanceIndex) sync() {
+	for _, key := range i.cache.GetAPIServiceInstanceKeys() {
+		if _, ok := i.apiIDs[key]; ok {
+			continue
+		}
+		instance, err := i.cache.GetAPIServiceInstanceByID(key)
+		if err != nil || instance == nil {
+			continue
+		}
+		apiID, _ := util.GetAgentDetailsValue(instance, common.AttrAPIID)
+		i.apiIDs[key] = apiID
+		if apiID == "" {
+			continue
+		}
+		if _, ok := i.byAPIID[apiID]; !ok {
+			i.byAPIID[apiID] = map[string]bool{}
+		}
+		i.byAPIID[apiID][key] = true
+	}
 }
 
 func (p *publisher) Stop() {
@@ -57,6 +121,41 @@ func (p *publisher) publish(serviceDetail *ServiceDetail) {
 		return
 	}
 	log.Infof("Published API to Amplify Central")
+
+	p.removeStaleInstances(serviceDetail)
+}
+
+// removeStaleInstances removes instances that were published for the same Mulesoft API under a different
+// externalAPIID or stage, which happens when the naming strategy changes. Only set up when the agent is connected to
+// Central.
+func (p *publisher) removeStaleInstances(serviceDetail *ServiceDetail) {
+	if p.instances == nil || p.deleteInstance == nil {
+		return
+	}
+	apiID := serviceDetail.AgentDetails[common.AttrAPIID]
+	if apiID == "" {
+		return
+	}
+
+	for _, instance := range p.instances.lookup(apiID) {
+		externalAPIID, _ := util.GetAgentDetailsValue(instance, defs.AttrExternalAPIID)
+		stage, _ := util.GetAgentDetailsValue(instance, defs.AttrExternalAPIStage)
+		if externalAPIID == serviceDetail.ID && stage == serviceDetail.Stage {
+			continue
+		}
+
+		log := logrus.WithFields(logrus.Fields{
+			"instance":         instance.Name,
+			"apiID":            apiID,
+			"oldExternalAPIID": externalAPIID,
+			"oldStage":         stage,
+		})
+		if err := p.deleteInstance(instance.Name); err != nil {
+			log.WithError(err).Error("failed to remove instance published with a previous naming strategy")
+			continue
+		}
+		log.Info("removed instance published with a previous naming strategy")
+	}
 }
 
 // BuildServiceBody - creates the service definition
@@ -79,6 +178,7 @@ func BuildServiceBody(service *ServiceDetail) (apic.ServiceBody, error) {
 		SetResourceType(service.ResourceType).
 		SetServiceAgentDetails(util.MapStringStringToMapStringInterface(service.AgentDetails)).
 		SetServiceAttribute(service.ServiceAttributes).
+		SetRevisionAttribute(service.RevisionAttributes).
 		SetStage(service.Stage).
 		SetState(service.State).
 		SetStatus(service.Status).
@@ -88,6 +188,10 @@ func BuildServiceBody(service *ServiceDetail) (apic.ServiceBody, error) {
 		SetURL(service.URL).
 		SetVersion(service.Version)
 
+	if len(service.Endpoints) > 0 {
+		builder = builder.SetServiceEndpoints(service.Endpoints)
+	}
+
 	if len(service.CRDs) > 0 {
 		return builder.SetAccessRequestDefinitionName(service.ARD, false).
 			SetCredentialRequestDefinitions(service.CRDs).Build()
@@ -1,8 +1,12 @@
 package discovery
 
 import (
+	"fmt"
 	"testing"
 
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
 	"github.com/Axway/agent-sdk/pkg/util"
 	"github.com/Axway/agents-mulesoft/pkg/common"
 
@@ -89,3 +93,85 @@ func (mp *mockAPIPublisher) mockPublishAPI(_ apic.ServiceBody) error {
 	mp.hitCh <- true
 	return nil
 }
+
+type mockInstanceCache struct {
+	instances map[string]*v1.ResourceInstance
+}
+
+func (m *mockInstanceCache) GetAPIServiceInstanceKeys() []string {
+	keys := []string{}
+	for k := range m.instances {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (m *mockInstanceCache) GetAPIServiceInstanceByID(id string) (*v1.ResourceInstance, error) {
+	if ri, ok := m.instances[id]; ok {
+		return ri, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+func newInstance(t *testing.T, name, apiID, externalAPIID, stage string) *v1.ResourceInstance {
+	inst := management.NewAPIServiceInstance(name, "env")
+	util.SetAgentDetailsKey(inst, common.AttrAPIID, apiID)
+	util.SetAgentDetailsKey(inst, defs.AttrExternalAPIID, externalAPIID)
+	util.SetAgentDetailsKey(inst, defs.AttrExternalAPIStage, stage)
+	ri, err := inst.AsInstance()
+	assert.Nil(t, err)
+	return ri
+}
+
+func TestPublishRemovesStaleInstances(t *testing.T) {
+	detail := *sd
+	detail.ID = "16810512"
+	detail.Stage = "v2"
+	detail.AgentDetails = map[string]string{common.AttrAPIID: "16810512"}
+	instCache := &mockInstanceCache{
+		instances: map[string]*v1.ResourceInstance{
+			"current":     newInstance(t, "current", "16810512", "16810512", "v2"),
+			"old-id":      newInstance(t, "old-id", "16810512", "211826270", "2.2.3"),
+			"other-api":   newInstance(t, "other-api", "999", "211826270", "2.2.3"),
+			"old-stage":   newInstance(t, "old-stage", "16810512", "16810512", "2.2.3"),
+			"no-api-info": newInstance(t, "no-api-info", "", "", ""),
+		},
+	}
+	deleted := []string{}
+	pub := &publisher{
+		publishAPI: func(apic.ServiceBody) error { return nil },
+		instances:  newInstanceIndex(instCache),
+		deleteInstance: func(name string) error {
+			deleted = append(deleted, name)
+			return nil
+		},
+	}
+
+	pub.publish(&detail)
+	assert.ElementsMatch(t, []string{"old-id", "old-stage"}, deleted)
+
+	// the instances added to the cache are indexed, the removed ones are dropped
+	delete(instCache.instances, "old-id")
+	delete(instCache.instances, "old-stage")
+	instCache.instances["new-stage"] = newInstance(t, "new-stage", "16810512", "16810512", "v1")
+	deleted = []string{}
+	pub.publish(&detail)
+	assert.Equal(t, []string{"new-stage"}, deleted)
+	assert.Len(t, pub.instances.byAPIID["16810512"], 2)
+}
+
+func TestPublishKeepsInstancesWithoutIndex(t *testing.T) {
+	detail := *sd
+	detail.AgentDetails = map[string]string{common.AttrAPIID: "16810512"}
+	deleted := []string{}
+	pub := &publisher{
+		publishAPI: func(apic.ServiceBody) error { return nil },
+		deleteInstance: func(name string) error {
+			deleted = append(deleted, name)
+			return nil
+		},
+	}
+
+	pub.publish(&detail)
+	assert.Empty(t, deleted)
+}
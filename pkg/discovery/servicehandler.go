@@ -13,7 +13,6 @@ import (
 	"github.com/Axway/agent-sdk/pkg/cache"
 	"github.com/Axway/agent-sdk/pkg/util/oas"
 	"github.com/Axway/agents-mulesoft/pkg/common"
-	"gopkg.in/yaml.v2"
 
 	"github.com/sirupsen/logrus"
 
@@ -25,6 +24,7 @@ import (
 	sdkUtil "github.com/Axway/agent-sdk/pkg/util"
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
 	"github.com/Axway/agents-mulesoft/pkg/config"
+	"github.com/Axway/agents-mulesoft/pkg/raml"
 )
 
 // ServiceHandler converts a mulesoft asset to an array of ServiceDetails
@@ -40,12 +40,34 @@ type serviceHandler struct {
 	client               anypoint.Client
 	cache                cache.Cache
 	discoverOriginalRaml bool
+	naming               *namingStrategy
+	endpointSources      []string
+	validator            *specValidator
 }
 
 func (s *serviceHandler) OnConfigChange(cfg *config.MulesoftConfig) {
 	s.discoveryTags = cleanTags(cfg.DiscoveryTags)
 	s.discoveryIgnoreTags = cleanTags(cfg.DiscoveryIgnoreTags)
 	s.muleEnv = cfg.Environment
+
+	// each setting is applied on its own, an invalid one keeps its previous value
+	if naming, err := newNamingStrategy(cfg); err != nil {
+		logrus.WithError(err).Error("keeping the previous naming strategy")
+	} else {
+		s.naming = naming
+	}
+
+	if sources, err := parseEndpointSources(cfg.EndpointSources); err != nil {
+		logrus.WithError(err).Error("keeping the previous endpoint sources")
+	} else {
+		s.endpointSources = sources
+	}
+
+	if validator, err := newSpecValidator(cfg.SpecValidationMode, cfg.SpecLintRules); err != nil {
+		logrus.WithError(err).Error("keeping the previous spec validation settings")
+	} else {
+		s.validator = validator
+	}
 }
 
 // ToServiceDetails gathers the ServiceDetail for a single Mulesoft Asset. Each Asset has multiple versions and
@@ -67,18 +89,19 @@ func (s *serviceHandler) ToServiceDetails(asset *anypoint.Asset) []*ServiceDetai
 			continue
 		}
 		// ListAssets doesn't have the option to get the proxy endpoint, only GetAPI
+		proxyURI := ""
 		apiDetailed, err := s.client.GetAPI(fmt.Sprint(api.ID))
 		if err != nil {
 			logger.WithError(err).Error("error getting api details")
-		}
-		if apiDetailed.Endpoint != nil {
-			parsedUri, err := url.ParseRequestURI(apiDetailed.Endpoint.ProxyURI)
+		} else if apiDetailed.Endpoint != nil {
+			proxyURI = apiDetailed.Endpoint.ProxyURI
+			parsedUri, err := url.ParseRequestURI(proxyURI)
 			if err == nil {
 				api.EndpointURI = api.EndpointURI + parsedUri.Path
 			}
 
 		}
-		serviceDetail, err := s.getServiceDetail(asset, &api)
+		serviceDetail, err := s.getServiceDetail(asset, &api, proxyURI)
 		if err != nil {
 			logger.Errorf("error getting the service details: %s", err.Error())
 			continue
@@ -91,7 +114,7 @@ func (s *serviceHandler) ToServiceDetails(asset *anypoint.Asset) []*ServiceDetai
 }
 
 // getServiceDetail gets the ServiceDetail for the API asset.
-func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.API) (*ServiceDetail, error) {
+func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.API, proxyURI string) (*ServiceDetail, error) {
 	api.ActiveContractsCount = 0
 	logger := logrus.WithFields(logrus.Fields{
 		"assetName":       asset.AssetID,
@@ -110,7 +133,7 @@ func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.A
 		return nil, err
 	}
 
-	isAlreadyPublished, checksum := isPublished(api, configuration, s.cache)
+	isAlreadyPublished, checksum := isPublished(api, configuration, s.checksumSalt(), s.cache)
 	// If true, then the api is published and there were no changes detected
 	if isAlreadyPublished {
 		logger.Debug("api is already published")
@@ -126,18 +149,7 @@ func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.A
 		logger.Errorf("failed to save api to cache: %s", err)
 	}
 
-	crds := []string{}
-	ard := ""
-	apicAuthsToCRDMapper := map[string]string{
-		apic.Oauth: provisioning.OAuthSecretCRD,
-		apic.Basic: provisioning.BasicAuthCRD,
-	}
-	for _, auth := range apicAuths {
-		if crd, ok := apicAuthsToCRDMapper[auth]; ok {
-			ard = provisioning.APIKeyARD
-			crds = []string{crd}
-		}
-	}
+	ard, crds := getRequestDefinitions(apicAuths)
 
 	exchangeAsset, err := s.client.GetExchangeAsset(api.GroupID, api.AssetID, api.AssetVersion)
 	if err != nil {
@@ -154,21 +166,60 @@ func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.A
 	if err != nil {
 		return nil, err
 	}
+	if _, _, err := raml.ParseHeader(rawSpec); err == nil && !s.discoverOriginalRaml {
+		// Exchange did not provide a conversion of the RAML spec
+		logger.Debug("converting the RAML spec to OAS 3")
+		rawSpec, err = raml.ConvertToOAS3(rawSpec)
+		if err != nil {
+			return nil, err
+		}
+		wasConverted = true
+	}
 	if wasConverted {
 		api.Tags = append(api.Tags, "converted-from-raml")
 	}
 
+	// the spec parser expects the RAML header at the start of the spec
+	rawSpec = raml.Normalize(rawSpec)
 	parser := apic.NewSpecResourceParser(rawSpec, "")
 	err = parser.Parse()
 	if err != nil {
 		return nil, err
 	}
 
-	modifiedSpec, err := updateSpec(parser.GetSpecProcessor(), api.EndpointURI, configuration)
+	endpoints := collectEndpoints(s.endpointSources, api, proxyURI, exchangeAsset)
+	if len(endpoints) == 0 {
+		// keep the API Manager endpoint when none of the configured sources has an endpoint
+		endpoints = []string{api.EndpointURI}
+	}
+	logger.WithField("endpoints", endpoints).Trace("collected endpoints")
+
+	modifiedSpec, err := updateSpec(parser.GetSpecProcessor(), endpoints, configuration)
 	if err != nil {
 		return nil, err
 	}
 
+	tags := api.Tags
+	revisionAttributes := map[string]string{}
+	if s.validator != nil {
+		findings := s.validator.Validate(parser.GetSpecProcessor().GetResourceType(), modifiedSpec)
+		if len(findings) > 0 {
+			summary := summarizeFindings(findings)
+			if s.validator.Blocks(findings) {
+				// the blocked API is validated again on the next run
+				if err := s.cache.Delete(checksum); err != nil {
+					logger.WithError(err).Debug("failed to remove the blocked api from the cache")
+				}
+				return nil, fmt.Errorf("spec validation failed: %s", summary)
+			}
+			logger.WithField("findings", summary).Warn("spec validation found issues")
+			if s.validator.Annotates(findings) {
+				tags = append(append([]string{}, api.Tags...), specValidationFailed)
+				revisionAttributes[attrSpecValidation] = truncate(summary, maxAttributeLength)
+			}
+		}
+	}
+
 	icon, iconContentType, err := s.client.GetExchangeAssetIcon(exchangeAsset.Icon)
 	if err != nil {
 		return nil, err
@@ -179,18 +230,42 @@ func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.A
 		status = apic.DeprecatedStatus
 	}
 
+	data := &namingData{
+		Asset:         asset,
+		API:           api,
+		ExchangeAsset: exchangeAsset,
+	}
+	externalAPIID, err := s.naming.ExternalAPIID(data)
+	if err != nil {
+		return nil, err
+	}
+	stage, err := s.naming.Stage(data)
+	if err != nil {
+		return nil, err
+	}
+	title, err := s.naming.Title(data)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.naming.Version(data)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ServiceDetail{
 		ARD:         ard,
 		CRDs:        crds,
 		APIName:     api.AssetID,
 		APISpec:     modifiedSpec,
 		Description: api.Description,
-		// Use the Asset ID for the externalAPIID so that apis linked to the asset are created as a revision
-		ID:                fmt.Sprint(asset.ID),
-		Image:             icon,
-		ImageContentType:  iconContentType,
-		ResourceType:      parser.GetSpecProcessor().GetResourceType(),
-		ServiceAttributes: map[string]string{},
+		Endpoints:   toEndpointDefinitions(endpoints),
+		// By default the Asset ID is the externalAPIID so that apis linked to the asset are created as a revision
+		ID:                 externalAPIID,
+		Image:              icon,
+		ImageContentType:   iconContentType,
+		ResourceType:       parser.GetSpecProcessor().GetResourceType(),
+		ServiceAttributes:  map[string]string{},
+		RevisionAttributes: revisionAttributes,
 		AgentDetails: map[string]string{
 			common.AttrAssetID:        fmt.Sprint(asset.ID),
 			common.AttrAPIID:          fmt.Sprint(api.ID),
@@ -198,10 +273,10 @@ func (s *serviceHandler) getServiceDetail(asset *anypoint.Asset, api *anypoint.A
 			common.AttrChecksum:       checksum,
 			common.AttrProductVersion: api.ProductVersion,
 		},
-		Stage:            api.AssetVersion,
-		Tags:             api.Tags,
-		Title:            asset.ExchangeAssetName,
-		Version:          api.AssetVersion,
+		Stage:            stage,
+		Tags:             tags,
+		Title:            title,
+		Version:          version,
 		SubscriptionName: "",
 		Status:           status,
 	}, nil
@@ -227,13 +302,33 @@ func shouldDiscoverAPI(endpoint string, discoveryTags, ignoreTags, apiTags []str
 	return true, ""
 }
 
-// updateSpec Updates the spec endpoints based on the given type.
-func updateSpec(processor apic.SpecProcessor, endpointURI string, configuration map[string]interface{}) ([]byte, error) {
+// checksumSalt returns the settings, other than the api and its policies, that change what is published for an api.
+// Empty when the defaults are used so that checksums of already published APIs do not change.
+func (s *serviceHandler) checksumSalt() string {
+	salt := s.naming.Fingerprint()
+	if len(s.endpointSources) > 0 && strings.Join(s.endpointSources, ",") != endpointSourceAPIManager {
+		salt += "|" + strings.Join(s.endpointSources, ",")
+	}
+	if s.validator != nil {
+		if fingerprint := s.validator.Fingerprint(); fingerprint != "" {
+			salt += "|" + fingerprint
+		}
+	}
+	return salt
+}
+
+// updateSpec Updates the spec endpoints based on the given type. OAS2 and RAML only support a single host, so the
+// first endpoint is used for them.
+func updateSpec(processor apic.SpecProcessor, endpoints []string, configuration map[string]interface{}) ([]byte, error) {
 	var err error
 	var oas2Swagger *openapi2.T
 	var oas3Swagger *openapi3.T
 	specType := processor.GetResourceType()
 	specBytes := processor.GetSpecBytes()
+	endpointURI := ""
+	if len(endpoints) > 0 {
+		endpointURI = endpoints[0]
+	}
 
 	switch specType {
 	case apic.Oas2:
@@ -252,7 +347,7 @@ func updateSpec(processor apic.SpecProcessor, endpointURI string, configuration
 		if err != nil {
 			return nil, err
 		}
-		oas.SetOAS3Servers([]string{endpointURI}, oas3Swagger)
+		oas.SetOAS3Servers(endpoints, oas3Swagger)
 		specBytes, err = setOAS3policies(oas3Swagger, configuration)
 
 	case apic.Raml:
@@ -276,8 +371,8 @@ func getExchangeAssetSpecFile(exchangeFiles []anypoint.ExchangeFile, discoverOri
 	if exchangeFiles[0].Classifier != "oas" &&
 		exchangeFiles[0].Classifier != "fat-oas" &&
 		exchangeFiles[0].Classifier != "wsdl" {
-		// Unsupported spec type
-		return nil
+		// Older RAML assets have no conversion, the RAML spec is converted by the agent
+		return getExchangeAssetWithRamlSpecFile(exchangeFiles)
 	}
 	return &exchangeFiles[0]
 }
@@ -310,11 +405,15 @@ func getApicAuthsAndConfig(policies []anypoint.Policy) ([]string, map[string]int
 			if !ok {
 				continue
 			}
-			if v, ok := val.(string); ok && v != "customExpression" {
+			if v, ok := val.(string); ok && v != common.CustomExpression {
 				configs[apic.Basic] = config
 				apicAuths = append(apicAuths, apic.Basic)
+			} else if _, err := parseClientIDCredentials(config); err == nil {
+				// the client id and secret are read from custom headers or query parameters
+				configs[apic.Apikey] = config
+				apicAuths = append(apicAuths, apic.Apikey)
 			} else {
-				return nil, nil, fmt.Errorf("incompatible Mulesoft Policies provided")
+				return nil, nil, fmt.Errorf("incompatible Mulesoft Policies provided: %s", err)
 			}
 		}
 	}
@@ -322,9 +421,47 @@ func getApicAuthsAndConfig(policies []anypoint.Policy) ([]string, map[string]int
 	return sdkUtil.RemoveDuplicateValuesFromStringSlice(apicAuths), configs, nil
 }
 
+// apicAuthsToCRDMapper maps the API Central authentication types to the credential request definitions
+var apicAuthsToCRDMapper = map[string]string{
+	apic.Oauth:  provisioning.OAuthSecretCRD,
+	apic.Basic:  provisioning.BasicAuthCRD,
+	apic.Apikey: provisioning.APIKeyCRD,
+}
+
+// getRequestDefinitions returns the access request definition and a credential request definition for each
+// authentication type of the API, so that consumers can pick the credential type they need.
+func getRequestDefinitions(apicAuths []string) (string, []string) {
+	crds := []string{}
+	ard := ""
+	for _, auth := range sortedAuths(apicAuths) {
+		if crd, ok := apicAuthsToCRDMapper[auth]; ok {
+			ard = provisioning.APIKeyARD
+			crds = append(crds, crd)
+		}
+	}
+	return ard, crds
+}
+
+// sortedAuths returns the authentication types in a stable order, so the specs and the credential request
+// definitions do not change between discovery runs.
+func sortedAuths(auths []string) []string {
+	sorted := sdkUtil.RemoveDuplicateValuesFromStringSlice(auths)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// configuredAuths returns the authentication types of the configuration in a stable order.
+func configuredAuths(configuration map[string]interface{}) []string {
+	auths := make([]string, 0, len(configuration))
+	for auth := range configuration {
+		auths = append(auths, auth)
+	}
+	return sortedAuths(auths)
+}
+
 // makeChecksum generates a makeChecksum for the api for change detection
-func makeChecksum(val interface{}, cfg interface{}) string {
-	sum := sha256.Sum256([]byte(fmt.Sprintf("%v%s", val, cfg)))
+func makeChecksum(val interface{}, cfg interface{}, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v%s%s", val, cfg, salt)))
 	return fmt.Sprintf("%x", sum)
 }
 
@@ -345,7 +482,8 @@ func setOAS2policies(swagger *openapi2.T, configuration map[string]interface{})
 	// remove existing security
 	swagger.SecurityDefinitions = make(map[string]*openapi2.SecurityScheme)
 	swagger.Security = openapi2.SecurityRequirements{}
-	for auth, config := range configuration {
+	for _, auth := range configuredAuths(configuration) {
+		config := configuration[auth]
 		switch auth {
 		case apic.Basic:
 			ss := openapi2.SecurityScheme{
@@ -386,6 +524,24 @@ func setOAS2policies(swagger *openapi2.T, configuration map[string]interface{})
 				Scopes:      scopes,
 			}
 			swagger.SecurityDefinitions[common.Oauth2Name] = &ss
+
+		case apic.Apikey:
+			creds, err := parseClientIDCredentials(getMapFromInterface(config))
+			if err != nil {
+				return nil, err
+			}
+			// the client id and the client secret are both required
+			requirement := map[string][]string{}
+			for _, location := range creds.locations() {
+				swagger.SecurityDefinitions[location.Scheme] = &openapi2.SecurityScheme{
+					Type:        common.APIKey,
+					Description: common.ClientIDEnforcementDesc,
+					In:          location.In,
+					Name:        location.Name,
+				}
+				requirement[location.Scheme] = []string{}
+			}
+			swagger.Security = append(swagger.Security, requirement)
 		}
 	}
 
@@ -399,7 +555,8 @@ func setOAS3policies(spec *openapi3.T, configuration map[string]interface{}) ([]
 	// remove existing security
 	spec.Components.SecuritySchemes = make(openapi3.SecuritySchemes)
 	spec.Security = *openapi3.NewSecurityRequirements()
-	for auth, config := range configuration {
+	for _, auth := range configuredAuths(configuration) {
+		config := configuration[auth]
 		switch auth {
 		case apic.Basic:
 			ssr := openapi3.SecuritySchemeRef{
@@ -445,41 +602,58 @@ func setOAS3policies(spec *openapi3.T, configuration map[string]interface{}) ([]
 				},
 			}
 			spec.Components.SecuritySchemes[common.Oauth2Name] = &ssr
+		case apic.Apikey:
+			creds, err := parseClientIDCredentials(getMapFromInterface(config))
+			if err != nil {
+				return nil, err
+			}
+			// the client id and the client secret are both required
+			requirement := openapi3.NewSecurityRequirement()
+			for _, location := range creds.locations() {
+				spec.Components.SecuritySchemes[location.Scheme] = &openapi3.SecuritySchemeRef{
+					Value: &openapi3.SecurityScheme{
+						Type:        common.APIKey,
+						Description: common.ClientIDEnforcementDesc,
+						In:          location.In,
+						Name:        location.Name,
+					},
+				}
+				requirement.Authenticate(location.Scheme)
+			}
+			spec.Security = *spec.Security.With(requirement)
 		}
 	}
 
 	return json.Marshal(spec)
 }
 
+// setRamlHostAndAuth sets the baseUri and declares the security of the Mule policies in a RAML 0.8 or 1.0 spec. The
+// order and the comments of the spec are kept.
 func setRamlHostAndAuth(spec []byte, endpoint string, configuration map[string]interface{}) ([]byte, error) {
-	var ramlDef map[string]interface{}
-	// We know that this is a valid raml file from the parser, so this never fails. We need this because yaml unmarshal drops the version line
-	ramlVersion := append(spec[0:10], []byte("\n")...)
-	yaml.Unmarshal(spec, &ramlDef)
+	doc, err := raml.Parse(spec)
+	if err != nil {
+		return spec, err
+	}
 
-	securitySchemes := map[string]interface{}{}
 	securedBy := []interface{}{}
-	for auth, config := range configuration {
+	for _, auth := range configuredAuths(configuration) {
 		switch auth {
 		case apic.Basic:
-			securitySchemes[common.BasicAuthName] = map[string]interface{}{
-				"description": common.BasicAuthDesc,
-				"type":        common.BasicAuthRAMLType,
-			}
+			err = doc.AddSecurityScheme(common.BasicAuthName, raml.SecurityScheme{
+				Description: common.BasicAuthDesc,
+				Type:        common.BasicAuthRAMLType,
+			})
 			securedBy = append(securedBy, common.BasicAuthName)
 		case apic.Oauth:
+			config := getMapFromInterface(configuration[auth])
 			tokenURL := ""
-			if token := config.(map[string]interface{})[common.TokenURL]; token != nil {
+			if token := config[common.TokenURL]; token != nil {
 				tokenURL = token.(string)
 			}
 
-			oAuthSettings := map[string]interface{}{
-				"accessTokenUri": tokenURL,
-			}
-			if s := config.(map[string]interface{})[common.Scopes]; s != nil {
+			if s := config[common.Scopes]; s != nil {
 				// formats correctly for raml securedBy format
-				scopesStr := s.(string)
-				scopesSlice := strings.Split(scopesStr, " ")
+				scopesSlice := strings.Split(s.(string), " ")
 				securedBy = append(securedBy,
 					map[string]interface{}{
 						common.Oauth2Name: map[string]interface{}{
@@ -492,11 +666,11 @@ func setRamlHostAndAuth(spec []byte, endpoint string, configuration map[string]i
 				securedBy = append(securedBy, common.Oauth2Name)
 			}
 
-			securitySchemes[common.Oauth2Name] = map[string]interface{}{
-				"description": common.Oauth2Desc,
-				"type":        common.Oauth2RAMLType,
-				"settings":    oAuthSettings,
-				"describedBy": map[string]interface{}{
+			err = doc.AddSecurityScheme(common.Oauth2Name, raml.SecurityScheme{
+				Description: common.Oauth2Desc,
+				Type:        common.Oauth2RAMLType,
+				Settings:    ramlOAuthSettings(doc.Version, tokenURL),
+				DescribedBy: map[string]interface{}{
 					"headers": map[string]interface{}{
 						"Authorization": map[string]interface{}{
 							"description": common.Oauth2Desc,
@@ -504,24 +678,95 @@ func setRamlHostAndAuth(spec []byte, endpoint string, configuration map[string]i
 						},
 					},
 				},
+			})
+		case apic.Apikey:
+			var creds *clientIDCredentials
+			creds, err = parseClientIDCredentials(getMapFromInterface(configuration[auth]))
+			if err != nil {
+				return spec, err
 			}
+			err = doc.AddSecurityScheme(common.ClientIDRAMLName, ramlClientIDScheme(doc.Version, creds))
+			securedBy = append(securedBy, common.ClientIDRAMLName)
+		}
+		if err != nil {
+			return spec, err
 		}
 	}
-	ramlDef["baseUri"] = endpoint
-	ramlDef["securitySchemes"] = securitySchemes
-	ramlDef["securedBy"] = securedBy
 
-	modifiedSpec, err := yaml.Marshal(ramlDef)
+	err = doc.Set("baseUri", endpoint)
 	if err != nil {
 		return spec, err
 	}
-	return append(ramlVersion, modifiedSpec...), nil
+	if len(securedBy) > 0 {
+		err = doc.Set("securedBy", securedBy)
+		if err != nil {
+			return spec, err
+		}
+	}
+
+	return doc.Marshal()
+}
+
+// ramlOAuthSettings returns the settings of the client credentials grant of the Mule OAuth provider. RAML 0.8 names
+// the grant credentials and requires an authorizationUri, which the provider policy does not expose.
+func ramlOAuthSettings(version, tokenURL string) map[string]interface{} {
+	if version == raml.Version08 {
+		return map[string]interface{}{
+			"authorizationUri":    tokenURL,
+			"accessTokenUri":      tokenURL,
+			"authorizationGrants": []string{"credentials"},
+		}
+	}
+	return map[string]interface{}{
+		"accessTokenUri":      tokenURL,
+		"authorizationGrants": []string{"client_credentials"},
+	}
+}
+
+// ramlClientIDScheme declares the headers and query parameters of the client-id-enforcement policy. RAML 0.8 has no
+// Pass Through scheme, a custom scheme is declared instead.
+func ramlClientIDScheme(version string, creds *clientIDCredentials) raml.SecurityScheme {
+	headers := map[string]interface{}{}
+	queryParameters := map[string]interface{}{}
+	labels := map[string]string{
+		common.ClientIDName:     common.ClientIDLabel,
+		common.ClientSecretName: common.ClientSecretLabel,
+	}
+	for _, location := range creds.locations() {
+		param := map[string]interface{}{
+			"description": labels[location.Scheme],
+			"type":        "string",
+		}
+		if location.In == credentialInQuery {
+			queryParameters[location.Name] = param
+		} else {
+			headers[location.Name] = param
+		}
+	}
+
+	describedBy := map[string]interface{}{}
+	if len(headers) > 0 {
+		describedBy["headers"] = headers
+	}
+	if len(queryParameters) > 0 {
+		describedBy["queryParameters"] = queryParameters
+	}
+
+	scheme := raml.SecurityScheme{
+		Description: common.ClientIDEnforcementDesc,
+		Type:        common.PassThroughRAMLType,
+		DescribedBy: describedBy,
+	}
+	if version == raml.Version08 {
+		scheme.Type = "x-" + common.ClientIDEnforcement
+	}
+	return scheme
 }
 
 // isPublished checks if an api is published with the latest changes. Returns true if it is, and false if it is not.
-func isPublished(api *anypoint.API, configuration map[string]interface{}, c cache.Cache) (bool, string) {
-	// Change detection (asset + policies)
-	checksum := makeChecksum(api, configuration)
+func isPublished(api *anypoint.API, configuration map[string]interface{}, salt string, c cache.Cache) (bool, string) {
+	// Change detection (asset + policies + publishing settings)
+	checksum := makeChecksum(api, configuration, salt)
 	item, err := c.Get(checksum)
 	if err != nil || item == nil {
 		return false, checksum
@@ -3,11 +3,11 @@ package discovery
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/Axway/agents-mulesoft/pkg/common"
-	"gopkg.in/yaml.v2"
 
 	"github.com/Axway/agent-sdk/pkg/cache"
 
@@ -15,10 +15,12 @@ import (
 	"github.com/getkin/kin-openapi/openapi3"
 
 	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/config"
 )
 
 var exchangeFile = anypoint.ExchangeFile{
@@ -26,6 +28,15 @@ var exchangeFile = anypoint.ExchangeFile{
 	DownloadURL: "abc.com",
 }
 
+var ramlExchangeAsset = anypoint.ExchangeAsset{
+	AssetID: "petstore-raml",
+	Files: []anypoint.ExchangeFile{
+		{Classifier: "fat-raml", MainFile: "petstore.raml", Packaging: "zip"},
+	},
+	Name:    "petstore-raml",
+	Version: "1.0.0",
+}
+
 var exchangeAsset = anypoint.ExchangeAsset{
 	AssetID:      "petstore-3",
 	AssetType:    "rest-api",
@@ -54,17 +65,34 @@ func TestServiceHandler(t *testing.T) {
 		policies             []anypoint.Policy
 		exchangeAsset        *anypoint.ExchangeAsset
 		expectedResourceType string
+		discoverOriginalRaml bool
+		expectedTags         []string
 	}
+	ramlSpec := "#%RAML 1.0\ntitle: API with Examples\ndescription: Grand Theft Auto:Vice City\nversion: v3\nprotocols: [HTTP,HTTPS]\nbaseUri: https://na1.salesforce.com:4000/services/data/{version}/chatter"
 	cases := []testCase{
 		{
-			content: "#%RAML 1.0\ntitle: API with Examples\ndescription: Grand Theft Auto:Vice City\nversion: v3\nprotocols: [HTTP,HTTPS]\nbaseUri: https://na1.salesforce.com:4000/services/data/{version}/chatter",
+			content: ramlSpec,
 			policies: []anypoint.Policy{
 				{
 					PolicyTemplateID: common.ClientIDEnforcement,
 				},
 			},
-			exchangeAsset:        &exchangeAsset,
+			exchangeAsset:        &ramlExchangeAsset,
 			expectedResourceType: apic.Raml,
+			discoverOriginalRaml: true,
+			expectedTags:         asset.APIs[0].Tags,
+		},
+		{
+			// Exchange has no OAS conversion of the RAML spec
+			content: ramlSpec,
+			policies: []anypoint.Policy{
+				{
+					PolicyTemplateID: common.ClientIDEnforcement,
+				},
+			},
+			exchangeAsset:        &ramlExchangeAsset,
+			expectedResourceType: apic.Oas3,
+			expectedTags:         append([]string{}, append(asset.APIs[0].Tags, "converted-from-raml")...),
 		},
 		{
 			content: `{"openapi":"3.0.1","servers":[{"url":"https://abc.com"}], "paths":{}, "info":{"title":"petstore3"}}`,
@@ -75,22 +103,25 @@ func TestServiceHandler(t *testing.T) {
 			},
 			exchangeAsset:        &exchangeAsset,
 			expectedResourceType: apic.Oas3,
+			expectedTags:         asset.APIs[0].Tags,
 		},
 	}
 	for _, c := range cases {
 		mc := &anypoint.MockAnypointClient{}
 		mc.On("GetPolicies").Return(c.policies, nil)
 		mc.On("GetExchangeAsset").Return(c.exchangeAsset, nil)
-		mc.On("GetExchangeFileContent").Return([]byte(c.content), true, nil)
+		mc.On("GetExchangeFileContent").Return([]byte(c.content), false, nil)
 		mc.On("GetExchangeAssetIcon").Return("", "", nil)
 		mc.On("GetAPI").Return(&asset.APIs[0], nil)
 
 		sh := &serviceHandler{
-			muleEnv:             "Sandbox",
-			discoveryTags:       []string{"tag1"},
-			discoveryIgnoreTags: []string{"nah"},
-			client:              mc,
-			cache:               cache.New(),
+			muleEnv:              "Sandbox",
+			discoveryTags:        []string{"tag1"},
+			discoveryIgnoreTags:  []string{"nah"},
+			client:               mc,
+			cache:                cache.New(),
+			naming:               defaultNaming(t),
+			discoverOriginalRaml: c.discoverOriginalRaml,
 		}
 		list := sh.ToServiceDetails(&asset)
 		api := asset.APIs[0]
@@ -104,7 +135,7 @@ func TestServiceHandler(t *testing.T) {
 		assert.Equal(t, api.AssetVersion, item.Stage)
 		assert.Equal(t, asset.ExchangeAssetName, item.Title)
 		assert.Equal(t, api.AssetVersion, item.Version)
-		assert.Equal(t, api.Tags, item.Tags)
+		assert.Equal(t, c.expectedTags, item.Tags)
 		assert.NotEmpty(t, item.AgentDetails[common.AttrChecksum])
 		assert.Equal(t, fmt.Sprint(api.ID), item.AgentDetails[common.AttrAPIID])
 		assert.Equal(t, fmt.Sprint(asset.ID), item.AgentDetails[common.AttrAssetID])
@@ -141,6 +172,7 @@ func TestServiceHandlerDidNotDiscoverAPI(t *testing.T) {
 		discoveryIgnoreTags: []string{"nah"},
 		client:              mc,
 		cache:               cache.New(),
+		naming:              defaultNaming(t),
 	}
 	details := sh.ToServiceDetails(&asset)
 	assert.Equal(t, 0, len(details))
@@ -160,8 +192,9 @@ func TestServiceHandlerGetExchangeAssetError(t *testing.T) {
 		discoveryIgnoreTags: []string{},
 		client:              mc,
 		cache:               cache.New(),
+		naming:              defaultNaming(t),
 	}
-	sd, err := sh.getServiceDetail(&asset, &asset.APIs[0])
+	sd, err := sh.getServiceDetail(&asset, &asset.APIs[0], "")
 
 	assert.Nil(t, sd)
 	assert.Equal(t, expectedErr, err)
@@ -298,6 +331,22 @@ func TestGetExchangeAssetSpecFile(t *testing.T) {
 				Classifier: "oas",
 			},
 		},
+		{
+			name: "Should return the RAML file to convert when there is no OAS or WSDL file",
+			files: []anypoint.ExchangeFile{
+				{
+					Classifier: "docs",
+				},
+				{
+					Classifier: "fat-raml",
+					MainFile:   "api.raml",
+				},
+			},
+			expected: &anypoint.ExchangeFile{
+				Classifier: "fat-raml",
+				MainFile:   "api.raml",
+			},
+		},
 		{
 			name: "Should sort files and return first non-empty mainFile",
 			files: []anypoint.ExchangeFile{
@@ -329,10 +378,42 @@ func TestGetExchangeAssetSpecFile(t *testing.T) {
 }
 
 func Test_checksum(t *testing.T) {
-	s1 := makeChecksum(&asset, apic.Passthrough)
-	s2 := makeChecksum(&asset, common.ClientIDEnforcement)
+	s1 := makeChecksum(&asset, apic.Passthrough, "")
+	s2 := makeChecksum(&asset, common.ClientIDEnforcement, "")
+	s3 := makeChecksum(&asset, apic.Passthrough, "{{.API.ID}}|{{.API.ProductVersion}}")
 	assert.NotEmpty(t, s1)
 	assert.NotEqual(t, s1, s2)
+	assert.NotEqual(t, s1, s3)
+}
+
+func TestServiceHandlerOnConfigChange(t *testing.T) {
+	naming, _ := newNamingStrategy(&config.MulesoftConfig{})
+	validator, _ := newSpecValidator("", "")
+	sh := &serviceHandler{
+		naming:          naming,
+		endpointSources: []string{endpointSourceAPIManager},
+		validator:       validator,
+	}
+
+	// an invalid naming template does not drop the other settings
+	sh.OnConfigChange(&config.MulesoftConfig{
+		StageTemplate:      "{{.API.Unknown}}",
+		EndpointSources:    endpointSourceExchange,
+		SpecValidationMode: validationModeBlock,
+	})
+	assert.Equal(t, naming, sh.naming)
+	assert.Equal(t, []string{endpointSourceExchange}, sh.endpointSources)
+	assert.Equal(t, validationModeBlock, sh.validator.mode)
+
+	// an invalid endpoint source does not drop the naming and validation changes
+	sh.OnConfigChange(&config.MulesoftConfig{
+		StageTemplate:      "{{.API.ProductVersion}}",
+		EndpointSources:    "unknown",
+		SpecValidationMode: validationModeAnnotate,
+	})
+	assert.NotEqual(t, naming, sh.naming)
+	assert.Equal(t, []string{endpointSourceExchange}, sh.endpointSources)
+	assert.Equal(t, validationModeAnnotate, sh.validator.mode)
 }
 
 func Test_getAuthConfig(t *testing.T) {
@@ -374,16 +455,31 @@ func Test_getAuthConfig(t *testing.T) {
 		},
 		{
 			name:     "ClientIDEnforcementCustomExpression",
+			expected: []string{apic.Apikey},
+			policies: []anypoint.Policy{
+				{
+					Configuration: map[string]interface{}{
+						common.CredOrigin:             common.CustomExpression,
+						common.ClientIDExpression:     "#[attributes.headers['client_id']]",
+						common.ClientSecretExpression: "#[attributes.queryParams.client_secret]",
+					},
+					PolicyTemplateID: common.ClientIDEnforcementPolicy,
+				},
+			},
+		},
+		{
+			name:     "ClientIDEnforcementUnsupportedCustomExpression",
 			expected: []string{},
 			policies: []anypoint.Policy{
 				{
 					Configuration: map[string]interface{}{
-						common.CredOrigin: "customExpression",
+						common.CredOrigin:         common.CustomExpression,
+						common.ClientIDExpression: "#[payload.clientId]",
 					},
 					PolicyTemplateID: common.ClientIDEnforcementPolicy,
 				},
 			},
-			err: fmt.Errorf("incompatible Mulesoft Policies provided"),
+			err: fmt.Errorf("incompatible Mulesoft Policies provided: unsupported clientIdExpression: '#[payload.clientId]' does not read a header or a query parameter"),
 		},
 		{
 			name:     "BasicAuth_ClientIDEnforcement",
@@ -417,8 +513,17 @@ func Test_getAuthConfig(t *testing.T) {
 	}
 }
 
+func Test_getRequestDefinitions(t *testing.T) {
+	ard, crds := getRequestDefinitions([]string{apic.Oauth, apic.Basic, apic.Oauth, apic.Apikey})
+	assert.Equal(t, provisioning.APIKeyARD, ard)
+	assert.Equal(t, []string{provisioning.BasicAuthCRD, provisioning.APIKeyCRD, provisioning.OAuthSecretCRD}, crds)
+
+	ard, crds = getRequestDefinitions([]string{})
+	assert.Empty(t, ard)
+	assert.Empty(t, crds)
+}
+
 func TestSetPolicies(t *testing.T) {
-	urlExample := "https://www.test.com"
 	tests := []struct {
 		name            string
 		configuration   map[string]interface{}
@@ -571,6 +676,60 @@ func TestSetPolicies(t *testing.T) {
 			},
 		},
 
+		{
+			name: "OAS3_ClientIDEnforcement",
+			configuration: map[string]interface{}{
+				apic.Apikey: map[string]interface{}{
+					common.CredOrigin:             common.CustomExpression,
+					common.ClientIDExpression:     "#[attributes.headers['client_id']]",
+					common.ClientSecretExpression: "#[attributes.queryParams['client_secret']]",
+				},
+			},
+			content: &openapi3.T{
+				OpenAPI: "3.0.1",
+				Info: &openapi3.Info{
+					Title: "petstore3",
+				},
+				Paths:   &openapi3.Paths{},
+				Servers: openapi3.Servers{{URL: "http://google.com"}},
+			},
+			expectedContent: map[string]interface{}{
+				"components": map[string]interface{}{
+					"securitySchemes": map[string]interface{}{
+						common.ClientIDName: map[string]interface{}{
+							"description": common.ClientIDEnforcementDesc,
+							"in":          "header",
+							"name":        "client_id",
+							"type":        common.APIKey,
+						},
+						common.ClientSecretName: map[string]interface{}{
+							"description": common.ClientIDEnforcementDesc,
+							"in":          "query",
+							"name":        "client_secret",
+							"type":        common.APIKey,
+						},
+					},
+				},
+				"info": map[string]interface{}{
+					"title":   "petstore3",
+					"version": "",
+				},
+				"openapi": "3.0.1",
+				"paths":   map[string]interface{}{},
+				"security": []map[string]interface{}{
+					map[string]interface{}{
+						common.ClientIDName:     []interface{}{},
+						common.ClientSecretName: []interface{}{},
+					},
+				},
+				"servers": []interface{}{
+					map[string]interface{}{
+						"url": "http://google.com",
+					},
+				},
+			},
+		},
+
 		{
 			name: "OAS2_HttpBasic",
 			configuration: map[string]interface{}{
@@ -702,98 +861,47 @@ func TestSetPolicies(t *testing.T) {
 				"swagger": "2.0",
 			},
 		},
-
 		{
-			name: "RAML_Basic",
+			name: "OAS2_ClientIDEnforcement",
 			configuration: map[string]interface{}{
-				apic.Basic: "",
-			},
-			content: []byte("#%RAML 1.0\ntitle: ok"),
-			expectedContent: map[string]interface{}{
-				"baseUri":   urlExample,
-				"securedBy": []interface{}{common.BasicAuthName},
-				"securitySchemes": map[string]interface{}{
-					common.BasicAuthName: map[string]interface{}{
-						"description": common.BasicAuthDesc,
-						"type":        common.BasicAuthRAMLType,
-					},
+				apic.Apikey: map[string]interface{}{
+					common.CredOrigin:         common.CustomExpression,
+					common.ClientIDExpression: "#[attributes.headers.client_id]",
 				},
-				"title": "ok",
 			},
-		},
-
-		{
-			name: "RAML_OAuth2",
-			configuration: map[string]interface{}{
-				apic.Oauth: map[string]interface{}{
-					common.TokenURL: urlExample,
+			content: &openapi2.T{
+				Swagger: "2.0",
+				Info: openapi3.Info{
+					Title: "petstore2",
 				},
+				Schemes:  []string{"http"},
+				Host:     "www.test.com",
+				BasePath: "/v2",
 			},
-			content: []byte("#%RAML 1.0\ntitle: ok"),
 			expectedContent: map[string]interface{}{
-				"baseUri": urlExample,
-				"securedBy": []interface{}{
-					common.Oauth2Name,
-				},
-				"securitySchemes": map[string]interface{}{
-					common.Oauth2Name: map[string]interface{}{
-						"description": common.Oauth2Desc,
-						"type":        common.Oauth2RAMLType,
-						"settings": map[string]interface{}{
-							"accessTokenUri": urlExample,
-						},
-						"describedBy": map[string]interface{}{
-							"headers": map[string]interface{}{
-								"Authorization": map[string]interface{}{
-									"description": common.Oauth2Desc,
-									"type":        "string",
-								},
-							},
-						},
-					},
+				"basePath": "/v2",
+				"host":     "www.test.com",
+				"info": map[string]interface{}{
+					"title":   "petstore2",
+					"version": "",
 				},
-				"title": "ok",
-			},
-		},
-
-		{
-			name: "RAML_OAuth2_Scopes",
-			configuration: map[string]interface{}{
-				apic.Oauth: map[string]interface{}{
-					common.TokenURL: urlExample, common.Scopes: "read write",
+				"schemes": []interface{}{
+					"http",
 				},
-			},
-			content: []byte("#%RAML 1.0\ntitle: ok"),
-			expectedContent: map[string]interface{}{
-				"baseUri": urlExample,
-				"securedBy": []interface{}{
+				"security": []map[string]interface{}{
 					map[string]interface{}{
-						common.Oauth2Name: map[string]interface{}{
-							"scopes": []interface{}{
-								"read",
-								"write",
-							},
-						},
+						common.ClientIDName: []interface{}{},
 					},
 				},
-				"securitySchemes": map[string]interface{}{
-					common.Oauth2Name: map[string]interface{}{
-						"description": common.Oauth2Desc,
-						"type":        common.Oauth2RAMLType,
-						"settings": map[string]interface{}{
-							"accessTokenUri": urlExample,
-						},
-						"describedBy": map[string]interface{}{
-							"headers": map[string]interface{}{
-								"Authorization": map[string]interface{}{
-									"description": common.Oauth2Desc,
-									"type":        "string",
-								},
-							},
-						},
+				"securityDefinitions": map[string]interface{}{
+					common.ClientIDName: map[string]interface{}{
+						"description": common.ClientIDEnforcementDesc,
+						"in":          "header",
+						"name":        "client_id",
+						"type":        common.APIKey,
 					},
 				},
-				"title": "ok",
+				"swagger": "2.0",
 			},
 		},
 	}
@@ -809,13 +917,96 @@ func TestSetPolicies(t *testing.T) {
 			case *openapi2.T:
 				actual, err = setOAS2policies(content, tc.configuration)
 				expected, _ = json.Marshal(tc.expectedContent)
-			case []byte:
-				actual, err = setRamlHostAndAuth(content, urlExample, tc.configuration)
-				expected, _ = yaml.Marshal(tc.expectedContent)
-				expected = append([]byte("#%RAML 1.0\n"), expected...)
 			}
 			assert.Nil(t, err)
 			assert.Equal(t, expected, actual)
 		})
 	}
 }
+
+func TestSetRamlHostAndAuth(t *testing.T) {
+	basic := map[string]interface{}{
+		apic.Basic: "",
+	}
+	oauthScopes := map[string]interface{}{
+		apic.Oauth: map[string]interface{}{
+			common.TokenURL: "https://auth.test.com/token", common.Scopes: "read write",
+		},
+	}
+	clientID := map[string]interface{}{
+		apic.Apikey: map[string]interface{}{
+			common.CredOrigin:             common.CustomExpression,
+			common.ClientIDExpression:     "#[attributes.headers['client_id']]",
+			common.ClientSecretExpression: "#[attributes.headers['client_secret']]",
+		},
+	}
+	tests := []struct {
+		name          string
+		spec          string
+		configuration map[string]interface{}
+		golden        string
+	}{
+		{
+			name:          "RAML 1.0 with basic authentication",
+			spec:          "petstore-1.0.raml",
+			configuration: basic,
+			golden:        "petstore-1.0-basic.golden.raml",
+		},
+		{
+			name:          "RAML 1.0 with oauth scopes",
+			spec:          "petstore-1.0.raml",
+			configuration: oauthScopes,
+			golden:        "petstore-1.0-oauth.golden.raml",
+		},
+		{
+			name:          "RAML 0.8 with a BOM and CRLF line endings and basic authentication",
+			spec:          "petstore-0.8.raml",
+			configuration: basic,
+			golden:        "petstore-0.8-basic.golden.raml",
+		},
+		{
+			name:          "RAML 0.8 with oauth scopes",
+			spec:          "petstore-0.8.raml",
+			configuration: oauthScopes,
+			golden:        "petstore-0.8-oauth.golden.raml",
+		},
+		{
+			name:          "RAML 1.0 with client id enforcement",
+			spec:          "petstore-1.0.raml",
+			configuration: clientID,
+			golden:        "petstore-1.0-clientid.golden.raml",
+		},
+		{
+			name:          "RAML 0.8 with client id enforcement",
+			spec:          "petstore-0.8.raml",
+			configuration: clientID,
+			golden:        "petstore-0.8-clientid.golden.raml",
+		},
+		{
+			name:          "RAML 1.0 without a policy keeps the security of the spec",
+			spec:          "petstore-1.0.raml",
+			configuration: map[string]interface{}{},
+			golden:        "petstore-1.0-nopolicy.golden.raml",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := os.ReadFile("./testdata/raml/" + tc.spec)
+			assert.Nil(t, err)
+			expected, err := os.ReadFile("./testdata/raml/" + tc.golden)
+			assert.Nil(t, err)
+
+			actual, err := setRamlHostAndAuth(spec, "https://petstore.test.com/api", tc.configuration)
+			assert.Nil(t, err)
+			assert.Equal(t, string(expected), string(actual))
+
+			// the result is still a RAML spec for the spec parser
+			parser := apic.NewSpecResourceParser(actual, "")
+			assert.Nil(t, parser.Parse())
+			assert.Equal(t, apic.Raml, parser.GetSpecProcessor().GetResourceType())
+		})
+	}
+
+	_, err := setRamlHostAndAuth([]byte("title: petstore\n"), "https://petstore.test.com/api", basic)
+	assert.NotNil(t, err)
+}
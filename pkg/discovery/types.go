@@ -1,31 +1,37 @@
 package discovery
 
-import "github.com/Axway/agents-mulesoft/pkg/anypoint"
+import (
+	"github.com/Axway/agent-sdk/pkg/apic"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
 
 // ServiceDetail is the information for the ex
 type ServiceDetail struct {
-	ARD               string
-	APIName           string
-	APISpec           []byte
-	APIUpdateSeverity string
-	AuthPolicy        string
-	CRDs              []string
-	Description       string
-	Documentation     []byte
-	ID                string
-	Image             string
-	ImageContentType  string
-	ResourceType      string
-	ServiceAttributes map[string]string
-	AgentDetails      map[string]string
-	Stage             string
-	State             string
-	Status            string
-	SubscriptionName  string
-	Tags              []string
-	Title             string
-	URL               string
-	Version           string
+	ARD                string
+	APIName            string
+	APISpec            []byte
+	APIUpdateSeverity  string
+	AuthPolicy         string
+	CRDs               []string
+	Description        string
+	Documentation      []byte
+	Endpoints          []apic.EndpointDefinition
+	ID                 string
+	Image              string
+	ImageContentType   string
+	ResourceType       string
+	ServiceAttributes  map[string]string
+	RevisionAttributes map[string]string
+	AgentDetails       map[string]string
+	Stage              string
+	State              string
+	Status             string
+	SubscriptionName   string
+	Tags               []string
+	Title              string
+	URL                string
+	Version            string
 }
 
 var specPreference = map[string]int{
@@ -0,0 +1,322 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Axway/agents-mulesoft/pkg/raml"
+)
+
+// Spec validation modes
+const (
+	// validationModeOff skips spec validation.
+	validationModeOff = "off"
+	// validationModeWarn logs the findings and publishes the API.
+	validationModeWarn = "warn"
+	// validationModeAnnotate publishes the API and records the findings on the revision.
+	validationModeAnnotate = "annotate"
+	// validationModeBlock does not publish an API with findings.
+	validationModeBlock = "block"
+)
+
+// Lint rules
+const (
+	// lintOperationDescription requires every operation to have a summary or a description.
+	lintOperationDescription = "operation-description"
+	// lintSecurityDeclared requires the spec to declare a security requirement.
+	lintSecurityDeclared = "security-declared"
+)
+
+const (
+	attrSpecValidation   = "specValidation"
+	specValidationFailed = "spec-validation-failed"
+	maxAttributeLength   = 350
+)
+
+var validValidationModes = map[string]bool{
+	validationModeOff:      true,
+	validationModeWarn:     true,
+	validationModeAnnotate: true,
+	validationModeBlock:    true,
+}
+
+var validLintRules = map[string]bool{
+	lintOperationDescription: true,
+	lintSecurityDeclared:     true,
+}
+
+// specFinding is a single problem found in a spec.
+type specFinding struct {
+	Rule    string
+	Message string
+}
+
+func (f specFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Rule, f.Message)
+}
+
+// specValidator validates the structure of a spec and applies the configured lint rules.
+type specValidator struct {
+	mode  string
+	rules []string
+}
+
+// newSpecValidator creates a specValidator. Defaults to the warn mode when no mode is set.
+func newSpecValidator(mode, rulesCSV string) (*specValidator, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		mode = validationModeWarn
+	}
+	if !validValidationModes[mode] {
+		return nil, fmt.Errorf("invalid mulesoft configuration: unknown spec validation mode '%s'", mode)
+	}
+
+	rules := cleanTags(rulesCSV)
+	for _, rule := range rules {
+		if !validLintRules[rule] {
+			return nil, fmt.Errorf("invalid mulesoft configuration: unknown spec lint rule '%s'", rule)
+		}
+	}
+
+	return &specValidator{
+		mode:  mode,
+		rules: rules,
+	}, nil
+}
+
+// Fingerprint identifies the validation mode and lint rules so that a change of settings can be detected. Empty for
+// the warn mode without lint rules.
+func (v *specValidator) Fingerprint() string {
+	if v.mode == validationModeWarn && len(v.rules) == 0 {
+		return ""
+	}
+	rules := append([]string{}, v.rules...)
+	sort.Strings(rules)
+	return v.mode + ":" + strings.Join(rules, ",")
+}
+
+// Validate returns the findings for the spec of the given resource type, sorted so that the same spec always
+// gives the same findings.
+func (v *specValidator) Validate(resourceType string, spec []byte) []specFinding {
+	if v.mode == validationModeOff {
+		return nil
+	}
+
+	var findings []specFinding
+	switch resourceType {
+	case apic.Oas2:
+		findings = v.validateOAS2(spec)
+	case apic.Oas3:
+		findings = v.validateOAS3(spec)
+	case apic.Raml:
+		findings = v.validateRaml(spec)
+	case apic.Wsdl:
+		findings = validateWsdl(spec)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].Message < findings[j].Message
+	})
+	return findings
+}
+
+// Blocks returns true when an API with findings must not be published.
+func (v *specValidator) Blocks(findings []specFinding) bool {
+	return v.mode == validationModeBlock && len(findings) > 0
+}
+
+// Annotates returns true when the findings are recorded on the published revision.
+func (v *specValidator) Annotates(findings []specFinding) bool {
+	return v.mode == validationModeAnnotate && len(findings) > 0
+}
+
+func (v *specValidator) hasRule(rule string) bool {
+	for _, r := range v.rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *specValidator) validateOAS2(spec []byte) []specFinding {
+	doc := &openapi2.T{}
+	if err := json.Unmarshal(spec, doc); err != nil {
+		return []specFinding{{Rule: "structure", Message: err.Error()}}
+	}
+	oas3, err := openapi2conv.ToV3(doc)
+	if err != nil {
+		return []specFinding{{Rule: "structure", Message: err.Error()}}
+	}
+	return v.validateOAS3Doc(oas3)
+}
+
+func (v *specValidator) validateOAS3(spec []byte) []specFinding {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return []specFinding{{Rule: "structure", Message: err.Error()}}
+	}
+	return v.validateOAS3Doc(doc)
+}
+
+func (v *specValidator) validateOAS3Doc(doc *openapi3.T) []specFinding {
+	findings := []specFinding{}
+	if err := doc.Validate(context.Background(), openapi3.DisableExamplesValidation()); err != nil {
+		findings = append(findings, specFinding{Rule: "structure", Message: err.Error()})
+	}
+
+	if v.hasRule(lintOperationDescription) && doc.Paths != nil {
+		for path, item := range doc.Paths.Map() {
+			for method, op := range item.Operations() {
+				if op.Summary == "" && op.Description == "" {
+					findings = append(findings, specFinding{
+						Rule:    lintOperationDescription,
+						Message: fmt.Sprintf("%s %s has no summary or description", method, path),
+					})
+				}
+			}
+		}
+	}
+
+	if v.hasRule(lintSecurityDeclared) {
+		secured := len(doc.Security) > 0
+		if !secured && doc.Paths != nil {
+			for _, item := range doc.Paths.Map() {
+				for _, op := range item.Operations() {
+					if op.Security != nil && len(*op.Security) > 0 {
+						secured = true
+					}
+				}
+			}
+		}
+		if !secured {
+			findings = append(findings, specFinding{Rule: lintSecurityDeclared, Message: "no security requirement is declared"})
+		}
+	}
+	return findings
+}
+
+func (v *specValidator) validateRaml(spec []byte) []specFinding {
+	findings := []specFinding{}
+	if _, _, err := raml.ParseHeader(spec); err != nil {
+		findings = append(findings, specFinding{Rule: "structure", Message: err.Error()})
+	}
+
+	def := map[string]interface{}{}
+	if err := yaml.Unmarshal(raml.Normalize(spec), &def); err != nil {
+		return append(findings, specFinding{Rule: "structure", Message: err.Error()})
+	}
+	if title, ok := def["title"]; !ok || fmt.Sprint(title) == "" {
+		findings = append(findings, specFinding{Rule: "structure", Message: "missing the title"})
+	}
+
+	if v.hasRule(lintSecurityDeclared) {
+		if securedBy, ok := def["securedBy"].([]interface{}); !ok || len(securedBy) == 0 {
+			findings = append(findings, specFinding{Rule: lintSecurityDeclared, Message: "no securedBy is declared"})
+		}
+	}
+
+	if v.hasRule(lintOperationDescription) {
+		findings = append(findings, ramlMethodsWithoutDescription("", def)...)
+	}
+	return findings
+}
+
+var ramlMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true,
+}
+
+// ramlMethodsWithoutDescription walks the resources of a RAML definition and reports methods without a description.
+func ramlMethodsWithoutDescription(path string, node map[string]interface{}) []specFinding {
+	findings := []specFinding{}
+	for k, v := range node {
+		key := fmt.Sprint(k)
+		child, ok := toStringMap(v)
+		if strings.HasPrefix(key, "/") {
+			if ok {
+				findings = append(findings, ramlMethodsWithoutDescription(path+key, child)...)
+			}
+			continue
+		}
+		if path != "" && ramlMethods[key] {
+			if !ok || child["description"] == nil {
+				findings = append(findings, specFinding{
+					Rule:    lintOperationDescription,
+					Message: fmt.Sprintf("%s %s has no description", key, path),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		res := map[string]interface{}{}
+		for k, val := range m {
+			res[fmt.Sprint(k)] = val
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func validateWsdl(spec []byte) []specFinding {
+	d := xml.NewDecoder(bytes.NewReader(spec))
+	root := ""
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return []specFinding{{Rule: "structure", Message: err.Error()}}
+		}
+		if el, ok := tok.(xml.StartElement); ok && root == "" {
+			root = el.Name.Local
+		}
+	}
+	if root != "definitions" && root != "description" {
+		return []specFinding{{Rule: "structure", Message: fmt.Sprintf("unexpected root element '%s'", root)}}
+	}
+	return nil
+}
+
+// summarizeFindings formats the findings for logging and revision attributes.
+func summarizeFindings(findings []specFinding) string {
+	msgs := make([]string, 0, len(findings))
+	for _, f := range findings {
+		msgs = append(msgs, f.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// truncate cuts a value to a length in bytes, without splitting a character
+func truncate(val string, length int) string {
+	if len(val) <= length {
+		return val
+	}
+	cut := length - 3
+	for cut > 0 && !utf8.RuneStart(val[cut]) {
+		cut--
+	}
+	return val[:cut] + "..."
+}
@@ -0,0 +1,223 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
+
+const (
+	oas3NoDescription = `{"openapi":"3.0.1","info":{"title":"petstore","version":"1"},"paths":{"/pets":{"get":{"responses":{"200":{"description":"ok"}}}}}}`
+	oas3Documented    = `{"openapi":"3.0.1","info":{"title":"petstore","version":"1"},"security":[{"basicAuth":[]}],"components":{"securitySchemes":{"basicAuth":{"type":"http","scheme":"basic"}}},"paths":{"/pets":{"get":{"summary":"list pets","responses":{"200":{"description":"ok"}}}}}}`
+	oas2NoSecurity    = `{"swagger":"2.0","info":{"title":"petstore","version":"1"},"host":"abc.com","paths":{"/pets":{"get":{"description":"list pets","responses":{"200":{"description":"ok"}}}}}}`
+	ramlNoDescription = "#%RAML 1.0\ntitle: petstore\n/pets:\n  get:\n    responses:\n      200:\n        body: {}\n  /{id}:\n    get:\n      description: a pet\n"
+)
+
+func TestNewSpecValidator(t *testing.T) {
+	v, err := newSpecValidator("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, validationModeWarn, v.mode)
+
+	v, err = newSpecValidator("Block", "operation-description, security-declared")
+	assert.Nil(t, err)
+	assert.Equal(t, validationModeBlock, v.mode)
+	assert.Equal(t, []string{lintOperationDescription, lintSecurityDeclared}, v.rules)
+
+	_, err = newSpecValidator("reject", "")
+	assert.NotNil(t, err)
+
+	_, err = newSpecValidator("warn", "operation-id")
+	assert.NotNil(t, err)
+}
+
+func TestSpecValidatorValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		rules        string
+		resourceType string
+		spec         string
+		rules2Find   []string
+	}{
+		{
+			name:         "should not validate when the mode is off",
+			mode:         validationModeOff,
+			rules:        "operation-description",
+			resourceType: apic.Oas3,
+			spec:         `{}`,
+		},
+		{
+			name:         "should report a malformed oas3 spec",
+			resourceType: apic.Oas3,
+			spec:         `{"openapi":"3.0.1","paths":{}}`,
+			rules2Find:   []string{"structure"},
+		},
+		{
+			name:         "should report oas3 operations without a description and a missing security requirement",
+			rules:        "operation-description,security-declared",
+			resourceType: apic.Oas3,
+			spec:         oas3NoDescription,
+			rules2Find:   []string{lintOperationDescription, lintSecurityDeclared},
+		},
+		{
+			name:         "should not report a documented and secured oas3 spec",
+			rules:        "operation-description,security-declared",
+			resourceType: apic.Oas3,
+			spec:         oas3Documented,
+		},
+		{
+			name:         "should report an oas2 spec without security",
+			rules:        "operation-description,security-declared",
+			resourceType: apic.Oas2,
+			spec:         oas2NoSecurity,
+			rules2Find:   []string{lintSecurityDeclared},
+		},
+		{
+			name:         "should report a raml spec without the header",
+			resourceType: apic.Raml,
+			spec:         "title: petstore\n",
+			rules2Find:   []string{"structure"},
+		},
+		{
+			name:         "should accept a raml 0.8 spec with a BOM and CRLF line endings",
+			resourceType: apic.Raml,
+			spec:         "\ufeff#%RAML 0.8\r\ntitle: petstore\r\n",
+		},
+		{
+			name:         "should report raml methods without a description and a missing securedBy",
+			rules:        "operation-description,security-declared",
+			resourceType: apic.Raml,
+			spec:         ramlNoDescription,
+			rules2Find:   []string{lintSecurityDeclared, lintOperationDescription},
+		},
+		{
+			name:         "should accept a wsdl",
+			resourceType: apic.Wsdl,
+			spec:         `<?xml version="1.0"?><wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"></wsdl:definitions>`,
+		},
+		{
+			name:         "should report a malformed wsdl",
+			resourceType: apic.Wsdl,
+			spec:         `<wsdl:definitions>`,
+			rules2Find:   []string{"structure"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := newSpecValidator(tc.mode, tc.rules)
+			assert.Nil(t, err)
+			findings := v.Validate(tc.resourceType, []byte(tc.spec))
+			found := []string{}
+			for _, f := range findings {
+				found = append(found, f.Rule)
+			}
+			assert.Equal(t, len(tc.rules2Find), len(found), summarizeFindings(findings))
+			for _, rule := range tc.rules2Find {
+				assert.Contains(t, found, rule)
+			}
+		})
+	}
+}
+
+func TestServiceHandlerSpecValidation(t *testing.T) {
+	newHandler := func(mode string) *serviceHandler {
+		mc := &anypoint.MockAnypointClient{}
+		mc.On("GetPolicies").Return([]anypoint.Policy{}, nil)
+		mc.On("GetExchangeAsset").Return(&exchangeAsset, nil)
+		mc.On("GetExchangeFileContent").Return([]byte(oas3NoDescription), false, nil)
+		mc.On("GetExchangeAssetIcon").Return("", "", nil)
+		validator, err := newSpecValidator(mode, lintOperationDescription)
+		assert.Nil(t, err)
+		return &serviceHandler{
+			client:    mc,
+			cache:     cache.New(),
+			naming:    defaultNaming(t),
+			validator: validator,
+		}
+	}
+
+	api := asset.APIs[0]
+	blocking := newHandler(validationModeBlock)
+	sd, err := blocking.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, sd)
+	assert.NotNil(t, err)
+
+	// a blocked api is validated again on the next run
+	api = asset.APIs[0]
+	sd, err = blocking.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, sd)
+	assert.NotNil(t, err)
+
+	// an api is published again when the validation settings change
+	warnValidator, _ := newSpecValidator(validationModeWarn, "")
+	blocking.validator = warnValidator
+	api = asset.APIs[0]
+	sd, err = blocking.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.NotNil(t, sd)
+	annotateValidator, _ := newSpecValidator(validationModeAnnotate, lintOperationDescription)
+	blocking.validator = annotateValidator
+	api = asset.APIs[0]
+	api.Tags = []string{}
+	sd, err = blocking.getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.Contains(t, sd.Tags, specValidationFailed)
+
+	api = asset.APIs[0]
+	api.Tags = []string{}
+	sd, err = newHandler(validationModeWarn).getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.NotContains(t, sd.Tags, specValidationFailed)
+	assert.Empty(t, sd.RevisionAttributes)
+
+	api = asset.APIs[0]
+	api.Tags = []string{}
+	sd, err = newHandler(validationModeAnnotate).getServiceDetail(&asset, &api, "")
+	assert.Nil(t, err)
+	assert.Contains(t, sd.Tags, specValidationFailed)
+	assert.True(t, strings.HasPrefix(sd.RevisionAttributes[attrSpecValidation], lintOperationDescription))
+	// the tags of the api are not changed
+	assert.Empty(t, api.Tags)
+}
+
+func TestSpecValidatorFindingsOrder(t *testing.T) {
+	v, err := newSpecValidator(validationModeAnnotate, "operation-description,security-declared")
+	assert.Nil(t, err)
+
+	expected := summarizeFindings(v.Validate(apic.Raml, []byte(ramlNoDescription)))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, expected, summarizeFindings(v.Validate(apic.Raml, []byte(ramlNoDescription))))
+	}
+	findings := v.Validate(apic.Oas3, []byte(oas3NoDescription))
+	assert.Equal(t, lintOperationDescription, findings[0].Rule)
+	assert.Equal(t, lintSecurityDeclared, findings[len(findings)-1].Rule)
+}
+
+func TestSpecValidatorFingerprint(t *testing.T) {
+	fingerprint := func(mode, rules string) string {
+		v, err := newSpecValidator(mode, rules)
+		assert.Nil(t, err)
+		return v.Fingerprint()
+	}
+	assert.Empty(t, fingerprint("", ""))
+	assert.Empty(t, fingerprint(validationModeWarn, ""))
+	assert.NotEqual(t, fingerprint(validationModeBlock, ""), fingerprint(validationModeAnnotate, ""))
+	assert.Equal(t, fingerprint(validationModeWarn, "operation-description,security-declared"), fingerprint(validationModeWarn, "security-declared,operation-description"))
+	assert.NotEqual(t, fingerprint(validationModeWarn, "operation-description"), fingerprint(validationModeWarn, "security-declared"))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "abcdef...", truncate("abcdefghijkl", 9))
+	// the cut does not split a character
+	truncated := truncate("abcdeééé", 9)
+	assert.Equal(t, "abcde...", truncated)
+	assert.True(t, utf8.ValidString(truncated))
+}
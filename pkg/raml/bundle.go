@@ -0,0 +1,394 @@
+package raml
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const includeMarker = "!include "
+
+var (
+	// includeTag matches the !include tag of a value or sequence item so that it can be kept as a plain string.
+	includeTag = regexp.MustCompile(`(^|[\s:\-\[,])!include\s+([^\s,\]\}#]+)`)
+	// identifier matches the names used in type expressions, trait and resource type references.
+	identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.\-]*`)
+)
+
+// declarationKeys are the root level sections of a library that declare named items.
+var declarationKeys = []string{"types", "schemas", "resourceTypes", "traits", "securitySchemes", "annotationTypes"}
+
+// referenceKeys are the keys whose values reference declared items.
+var referenceKeys = map[string]bool{
+	"type":      true,
+	"items":     true,
+	"is":        true,
+	"securedBy": true,
+	"schema":    true,
+}
+
+// typeMaps are the keys whose entries may declare their type with a shorthand, e.g. properties: { tag: lib.Tag }
+var typeMaps = map[string]bool{
+	"types":             true,
+	"schemas":           true,
+	"properties":        true,
+	"queryParameters":   true,
+	"uriParameters":     true,
+	"baseUriParameters": true,
+	"headers":           true,
+}
+
+// Bundle resolves the !include references, the libraries in uses and the Exchange dependency paths of the RAML
+// mainFile into a single self-contained document. Declarations of libraries are merged into the root of the document,
+// prefixed by the library namespace, e.g. lib.Pet becomes lib_Pet.
+func Bundle(files map[string][]byte, mainFile string) ([]byte, error) {
+	mainFile = cleanPath(mainFile)
+	content, ok := files[mainFile]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in the archive", mainFile)
+	}
+
+	b := &bundler{
+		files:     files,
+		libraries: map[string]string{},
+		merged:    map[string]yaml.MapSlice{},
+	}
+
+	root, err := b.loadDocument(mainFile, 0)
+	if err != nil {
+		return nil, err
+	}
+	root, err = b.resolveUses(mainFile, root, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range declarationKeys {
+		decls, ok := b.merged[key]
+		if !ok {
+			continue
+		}
+		existing, _ := getValue(root, key).(yaml.MapSlice)
+		root = setValue(root, key, append(existing, decls...))
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header(content)+"\n"), out...), nil
+}
+
+type bundler struct {
+	files map[string][]byte
+	// libraries maps the path of a library to the prefix of its merged declarations
+	libraries map[string]string
+	// merged holds the library declarations by section
+	merged map[string]yaml.MapSlice
+}
+
+const maxDepth = 32
+
+// load parses a RAML or YAML file and inlines its !include references. The content of a fragment is not always a
+// mapping, e.g. the list of an example or a scalar.
+func (b *bundler) load(file string, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("too many nested includes in %s", file)
+	}
+	content, ok := b.files[file]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in the archive", file)
+	}
+
+	doc, err := unmarshalNode(markIncludes(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", file, err)
+	}
+	return b.inline(file, doc, depth)
+}
+
+// loadDocument loads a RAML document or library, which must be a mapping.
+func (b *bundler) loadDocument(file string, depth int) (yaml.MapSlice, error) {
+	doc, err := b.load(file, depth)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return yaml.MapSlice{}, nil
+	}
+	m, ok := doc.(yaml.MapSlice)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a RAML document", file)
+	}
+	return m, nil
+}
+
+// unmarshalNode decodes a YAML value of any kind. The mappings are decoded in order, a sequence is decoded under a
+// key so that the mappings it contains keep their order too.
+func unmarshalNode(content []byte) (interface{}, error) {
+	var probe interface{}
+	if err := yaml.Unmarshal(content, &probe); err != nil {
+		return nil, err
+	}
+
+	switch probe.(type) {
+	case map[interface{}]interface{}:
+		doc := yaml.MapSlice{}
+		err := yaml.Unmarshal(content, &doc)
+		return doc, err
+	case []interface{}:
+		wrapped := &strings.Builder{}
+		wrapped.WriteString("value:\n")
+		for _, line := range strings.Split(strings.TrimPrefix(string(content), "\ufeff"), "\n") {
+			if strings.TrimSpace(line) == "---" {
+				continue
+			}
+			wrapped.WriteString("  " + line + "\n")
+		}
+		wrapper := yaml.MapSlice{}
+		if err := yaml.Unmarshal([]byte(wrapped.String()), &wrapper); err != nil {
+			return nil, err
+		}
+		return getValue(wrapper, "value"), nil
+	}
+	return probe, nil
+}
+
+// inline replaces the !include markers in the node with the content of the referenced files.
+func (b *bundler) inline(file string, node interface{}, depth int) (interface{}, error) {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for i := range n {
+			val, err := b.inline(file, n[i].Value, depth)
+			if err != nil {
+				return nil, err
+			}
+			n[i].Value = val
+		}
+		return n, nil
+	case []interface{}:
+		for i := range n {
+			val, err := b.inline(file, n[i], depth)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = val
+		}
+		return n, nil
+	case string:
+		if !strings.HasPrefix(n, includeMarker) {
+			return n, nil
+		}
+		ref, ok := b.resolvePath(file, strings.TrimPrefix(n, includeMarker))
+		if !ok {
+			return nil, fmt.Errorf("%s included from %s not found in the archive", strings.TrimPrefix(n, includeMarker), file)
+		}
+		if !isYAML(ref) {
+			return string(b.files[ref]), nil
+		}
+		included, err := b.load(ref, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		fragment, ok := included.(yaml.MapSlice)
+		if !ok {
+			return included, nil
+		}
+		// Fragments may use libraries of their own
+		return b.resolveUses(ref, fragment, "", depth+1)
+	}
+	return node, nil
+}
+
+// resolveUses merges the libraries of the document into the bundle and renames the references to them. When
+// prefix is set, the document is a library and its own declarations are renamed with that prefix.
+func (b *bundler) resolveUses(file string, doc yaml.MapSlice, prefix string, depth int) (yaml.MapSlice, error) {
+	renames := map[string]string{}
+	if prefix != "" {
+		for _, key := range declarationKeys {
+			decls, _ := getValue(doc, key).(yaml.MapSlice)
+			for _, d := range decls {
+				name := fmt.Sprint(d.Key)
+				renames[name] = prefix + "_" + name
+			}
+		}
+	}
+
+	uses, _ := getValue(doc, "uses").(yaml.MapSlice)
+	for _, use := range uses {
+		namespace := fmt.Sprint(use.Key)
+		ref, ok := b.resolvePath(file, fmt.Sprint(use.Value))
+		if !ok {
+			return nil, fmt.Errorf("library %s used in %s not found in the archive", use.Value, file)
+		}
+		libPrefix, err := b.mergeLibrary(ref, namespace, prefix, depth)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range declarationKeys {
+			// the names are taken from the renamed declarations, which are prefixed with libPrefix
+			for _, d := range b.merged[key] {
+				name := fmt.Sprint(d.Key)
+				if strings.HasPrefix(name, libPrefix+"_") {
+					renames[namespace+"."+strings.TrimPrefix(name, libPrefix+"_")] = name
+				}
+			}
+		}
+	}
+	doc = removeKey(doc, "uses")
+
+	renamed := renameReferences(doc, renames, "").(yaml.MapSlice)
+	if prefix == "" {
+		return renamed, nil
+	}
+
+	// the declarations of a library are moved to the bundle, renamed
+	for _, key := range declarationKeys {
+		decls, _ := getValue(renamed, key).(yaml.MapSlice)
+		for _, d := range decls {
+			b.merged[key] = append(b.merged[key], yaml.MapItem{Key: renames[fmt.Sprint(d.Key)], Value: d.Value})
+		}
+	}
+	return renamed, nil
+}
+
+// mergeLibrary loads a library once and returns the prefix of its declarations.
+func (b *bundler) mergeLibrary(file, namespace, parentPrefix string, depth int) (string, error) {
+	if prefix, ok := b.libraries[file]; ok {
+		return prefix, nil
+	}
+	if depth > maxDepth {
+		return "", fmt.Errorf("too many nested libraries in %s", file)
+	}
+
+	prefix := namespace
+	if parentPrefix != "" {
+		prefix = parentPrefix + "_" + namespace
+	}
+	b.libraries[file] = prefix
+
+	lib, err := b.loadDocument(file, depth+1)
+	if err != nil {
+		return "", err
+	}
+	_, err = b.resolveUses(file, lib, prefix, depth+1)
+	return prefix, err
+}
+
+// resolvePath finds a referenced file relative to the referencing file, then relative to the root of the archive,
+// which is where Exchange places the exchange_modules dependencies.
+func (b *bundler) resolvePath(from, ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	candidates := []string{
+		cleanPath(path.Join(path.Dir(from), ref)),
+		cleanPath(ref),
+	}
+	if idx := strings.Index(ref, "exchange_modules/"); idx > 0 {
+		candidates = append(candidates, cleanPath(ref[idx:]))
+	}
+	for _, c := range candidates {
+		if _, ok := b.files[c]; ok {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// renameReferences renames the references to declarations in the values of reference keys, and in the keys of
+// parameterized resource types and traits.
+func renameReferences(node interface{}, renames map[string]string, parentKey string) interface{} {
+	if len(renames) == 0 {
+		return node
+	}
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for i := range n {
+			key := fmt.Sprint(n[i].Key)
+			if referenceKeys[parentKey] {
+				// e.g. type: { collection: { item: Pet } }
+				if name, ok := renames[key]; ok {
+					n[i].Key = name
+				}
+			}
+			if key == "description" || key == "example" || key == "examples" || key == "displayName" {
+				continue
+			}
+			if typeMaps[parentKey] {
+				// the entry is the type itself, or declares it
+				key = "type"
+			}
+			n[i].Value = renameReferences(n[i].Value, renames, key)
+		}
+		return n
+	case []interface{}:
+		for i := range n {
+			n[i] = renameReferences(n[i], renames, parentKey)
+		}
+		return n
+	case string:
+		if !referenceKeys[parentKey] {
+			return n
+		}
+		return identifier.ReplaceAllStringFunc(n, func(id string) string {
+			if name, ok := renames[id]; ok {
+				return name
+			}
+			return id
+		})
+	}
+	return node
+}
+
+func markIncludes(content []byte) []byte {
+	return includeTag.ReplaceAll(content, []byte(`$1"`+includeMarker+`$2"`))
+}
+
+// header returns the first line of the document, e.g. #%RAML 1.0
+func header(content []byte) string {
+	line := strings.SplitN(string(content), "\n", 2)[0]
+	return strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+}
+
+func isYAML(file string) bool {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".raml", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func getValue(doc yaml.MapSlice, key string) interface{} {
+	for _, item := range doc {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setValue(doc yaml.MapSlice, key string, val interface{}) yaml.MapSlice {
+	for i, item := range doc {
+		if fmt.Sprint(item.Key) == key {
+			doc[i].Value = val
+			return doc
+		}
+	}
+	return append(doc, yaml.MapItem{Key: key, Value: val})
+}
+
+func removeKey(doc yaml.MapSlice, key string) yaml.MapSlice {
+	res := yaml.MapSlice{}
+	for _, item := range doc {
+		if fmt.Sprint(item.Key) != key {
+			res = append(res, item)
+		}
+	}
+	return res
+}
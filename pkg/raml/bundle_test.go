@@ -0,0 +1,179 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var archive = map[string][]byte{
+	"api.raml": []byte(`#%RAML 1.0
+title: petstore
+version: v1
+uses:
+  common: exchange_modules/org/common/1.0.0/common.raml
+  pets: libraries/pets.raml
+types:
+  Error: !include types/error.raml
+/pets:
+  is: [ common.paged ]
+  get:
+    description: list pets
+    responses:
+      200:
+        body:
+          application/json:
+            type: pets.Pet[]
+            example: !include examples/pets.json
+  post:
+    body:
+      application/json:
+        type: pets.Pet
+    responses:
+      400:
+        body:
+          application/json:
+            type: Error
+`),
+	"types/error.raml": []byte(`#%RAML 1.0 DataType
+type: object
+properties:
+  message: string
+`),
+	"examples/pets.json": []byte(`[{"name":"rex"}]`),
+	"libraries/pets.raml": []byte(`#%RAML 1.0 Library
+uses:
+  common: ../exchange_modules/org/common/1.0.0/common.raml
+types:
+  Pet:
+    type: object
+    properties:
+      name: string
+      tag: common.Tag
+  Pets:
+    type: array
+    items: Pet
+`),
+	"exchange_modules/org/common/1.0.0/common.raml": []byte(`#%RAML 1.0 Library
+types:
+  Tag: string
+traits:
+  paged:
+    queryParameters:
+      limit: integer
+`),
+}
+
+func TestBundle(t *testing.T) {
+	bundled, err := Bundle(archive, "api.raml")
+	assert.Nil(t, err)
+	assert.Contains(t, string(bundled), "#%RAML 1.0\n")
+	assert.NotContains(t, string(bundled), "!include")
+
+	doc := yaml.MapSlice{}
+	assert.Nil(t, yaml.Unmarshal(bundled, &doc))
+	assert.Nil(t, getValue(doc, "uses"))
+
+	// the order of the root document is preserved, the library declarations are merged at the end
+	keys := []string{}
+	for _, item := range doc {
+		keys = append(keys, item.Key.(string))
+	}
+	assert.Equal(t, []string{"title", "version", "types", "/pets", "traits"}, keys)
+
+	types := getValue(doc, "types").(yaml.MapSlice)
+	typeNames := []string{}
+	for _, item := range types {
+		typeNames = append(typeNames, item.Key.(string))
+	}
+	assert.Equal(t, []string{"Error", "common_Tag", "pets_Pet", "pets_Pets"}, typeNames)
+
+	// the included data type is inlined
+	errType := getValue(types, "Error").(yaml.MapSlice)
+	assert.Equal(t, "object", getValue(errType, "type"))
+
+	// references within and across libraries are renamed
+	pet := getValue(types, "pets_Pet").(yaml.MapSlice)
+	assert.Equal(t, "common_Tag", getValue(getValue(pet, "properties").(yaml.MapSlice), "tag"))
+	assert.Equal(t, "pets_Pet", getValue(getValue(types, "pets_Pets").(yaml.MapSlice), "items"))
+
+	traits := getValue(doc, "traits").(yaml.MapSlice)
+	assert.NotNil(t, getValue(traits, "common_paged"))
+
+	pets := getValue(doc, "/pets").(yaml.MapSlice)
+	assert.Equal(t, []interface{}{"common_paged"}, getValue(pets, "is"))
+	get := getValue(pets, "get").(yaml.MapSlice)
+	body := getValue(getValue(getValue(getValue(get, "responses").(yaml.MapSlice), "200").(yaml.MapSlice), "body").(yaml.MapSlice), "application/json").(yaml.MapSlice)
+	assert.Equal(t, "pets_Pet[]", getValue(body, "type"))
+	// other includes are inlined as text
+	assert.Equal(t, `[{"name":"rex"}]`, getValue(body, "example"))
+}
+
+func TestBundleFragmentsThatAreNotMaps(t *testing.T) {
+	bundled, err := Bundle(map[string][]byte{
+		"api.raml": []byte(`#%RAML 1.0
+title: petstore
+/pets:
+  get:
+    description: !include docs/description.yaml
+    responses:
+      200:
+        body:
+          application/json:
+            example: !include examples/pets.yaml
+`),
+		"docs/description.yaml": []byte("list the pets\n"),
+		"examples/pets.yaml": []byte(`#%RAML 1.0 NamedExample
+- name: rex
+  tag: dog
+- name: tom
+  tag: cat
+`),
+	}, "api.raml")
+	assert.Nil(t, err)
+
+	doc := yaml.MapSlice{}
+	assert.Nil(t, yaml.Unmarshal(bundled, &doc))
+	get := getValue(getValue(doc, "/pets").(yaml.MapSlice), "get").(yaml.MapSlice)
+	assert.Equal(t, "list the pets", getValue(get, "description"))
+
+	body := getValue(getValue(getValue(getValue(get, "responses").(yaml.MapSlice), "200").(yaml.MapSlice), "body").(yaml.MapSlice), "application/json").(yaml.MapSlice)
+	example := getValue(body, "example").([]interface{})
+	assert.Len(t, example, 2)
+	// the mappings of the list keep their order
+	assert.Equal(t, yaml.MapSlice{{Key: "name", Value: "rex"}, {Key: "tag", Value: "dog"}}, example[0])
+}
+
+func TestBundleErrors(t *testing.T) {
+	_, err := Bundle(archive, "missing.raml")
+	assert.NotNil(t, err)
+
+	_, err = Bundle(map[string][]byte{
+		"api.raml": []byte("#%RAML 1.0\ntitle: petstore\ntypes:\n  Pet: !include pet.raml\n"),
+	}, "api.raml")
+	assert.NotNil(t, err)
+
+	_, err = Bundle(map[string][]byte{
+		"api.raml": []byte("#%RAML 1.0\ntitle: petstore\nuses:\n  lib: lib.raml\n"),
+	}, "api.raml")
+	assert.NotNil(t, err)
+
+	// the main file must be a document
+	_, err = Bundle(map[string][]byte{"api.raml": []byte("- title\n")}, "api.raml")
+	assert.NotNil(t, err)
+
+	// an include cycle is reported instead of recursing forever
+	_, err = Bundle(map[string][]byte{
+		"api.raml": []byte("#%RAML 1.0\ntitle: petstore\ntypes:\n  Pet: !include pet.raml\n"),
+		"pet.raml": []byte("#%RAML 1.0 DataType\nproperties:\n  parent: !include pet.raml\n"),
+	}, "api.raml")
+	assert.NotNil(t, err)
+}
+
+func TestBundleWithoutReferences(t *testing.T) {
+	spec := "#%RAML 0.8\ntitle: petstore\n/pets:\n  get:\n    description: list pets\n"
+	bundled, err := Bundle(map[string][]byte{"api.raml": []byte(spec)}, "/api.raml")
+	assert.Nil(t, err)
+	assert.Equal(t, spec, string(bundled))
+}
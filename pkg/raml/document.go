@@ -0,0 +1,208 @@
+package raml
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// RAML versions
+const (
+	Version08 = "0.8"
+	Version10 = "1.0"
+)
+
+var (
+	bom = []byte("\ufeff")
+	// headerLine matches the RAML header, e.g. #%RAML 1.0 or #%RAML 1.0 Library
+	headerLine = regexp.MustCompile(`^#%RAML[ \t]+(0\.8|1\.0)(?:[ \t]+(\S.*?))?[ \t]*$`)
+)
+
+// Document is a RAML document that keeps the order and the comments of the source when it is modified.
+type Document struct {
+	// Version is the RAML version of the document, 0.8 or 1.0
+	Version string
+	// Fragment is the fragment type of a RAML 1.0 document, e.g. Library, empty for an API definition
+	Fragment string
+	root     *yaml3.Node
+}
+
+// SecurityScheme is the declaration of a security scheme, it has the same syntax in RAML 0.8 and 1.0.
+type SecurityScheme struct {
+	Description string                 `yaml:"description,omitempty"`
+	Type        string                 `yaml:"type"`
+	DescribedBy map[string]interface{} `yaml:"describedBy,omitempty"`
+	Settings    map[string]interface{} `yaml:"settings,omitempty"`
+}
+
+// Normalize removes the byte order mark and converts the line endings of a RAML spec to LF. Specs that do not start
+// with the RAML header are returned unchanged.
+func Normalize(spec []byte) []byte {
+	trimmed := bytes.TrimPrefix(spec, bom)
+	if !bytes.HasPrefix(trimmed, []byte("#%RAML")) {
+		return spec
+	}
+	return bytes.ReplaceAll(trimmed, []byte("\r\n"), []byte("\n"))
+}
+
+// ParseHeader returns the version and the fragment type of the RAML header of the spec.
+func ParseHeader(spec []byte) (string, string, error) {
+	line := string(bytes.SplitN(Normalize(spec), []byte("\n"), 2)[0])
+	matches := headerLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if matches == nil {
+		return "", "", fmt.Errorf("missing the #%%RAML 0.8 or #%%RAML 1.0 header")
+	}
+	return matches[1], matches[2], nil
+}
+
+// Parse parses a RAML 0.8 or 1.0 document.
+func Parse(spec []byte) (*Document, error) {
+	version, fragment, err := ParseHeader(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// the header is written back by Marshal, it is not kept as a comment of the body
+	body := []byte{}
+	if parts := bytes.SplitN(Normalize(spec), []byte("\n"), 2); len(parts) == 2 {
+		body = parts[1]
+	}
+
+	root := &yaml3.Node{}
+	err = yaml3.Unmarshal(body, root)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		// a header without a body
+		root = &yaml3.Node{Kind: yaml3.DocumentNode, Content: []*yaml3.Node{{Kind: yaml3.MappingNode}}}
+	}
+	if root.Content[0].Kind != yaml3.MappingNode {
+		return nil, fmt.Errorf("the RAML document is not a map")
+	}
+
+	return &Document{
+		Version:  version,
+		Fragment: fragment,
+		root:     root,
+	}, nil
+}
+
+// Set sets the value of a root key, in place when the key exists, otherwise before the resources of the document.
+func (d *Document) Set(key string, value interface{}) error {
+	node, err := toNode(value)
+	if err != nil {
+		return err
+	}
+	d.setRoot(key, node)
+	return nil
+}
+
+// AddSecurityScheme declares a security scheme. RAML 1.0 declares the schemes in a map, RAML 0.8 in a sequence of
+// single entry maps. A scheme with the same name is replaced.
+func (d *Document) AddSecurityScheme(name string, scheme SecurityScheme) error {
+	node, err := toNode(scheme)
+	if err != nil {
+		return err
+	}
+
+	schemes := getNode(d.body(), "securitySchemes")
+	if schemes == nil || (schemes.Kind != yaml3.MappingNode && schemes.Kind != yaml3.SequenceNode) {
+		schemes = &yaml3.Node{Kind: yaml3.MappingNode}
+		if d.Version == Version08 {
+			schemes.Kind = yaml3.SequenceNode
+		}
+		d.setRoot("securitySchemes", schemes)
+	}
+
+	if schemes.Kind == yaml3.MappingNode {
+		setNode(schemes, name, node)
+		return nil
+	}
+
+	for _, item := range schemes.Content {
+		if item.Kind == yaml3.MappingNode && getNode(item, name) != nil {
+			setNode(item, name, node)
+			return nil
+		}
+	}
+	entry := &yaml3.Node{Kind: yaml3.MappingNode}
+	setNode(entry, name, node)
+	schemes.Content = append(schemes.Content, entry)
+	return nil
+}
+
+// Marshal returns the document with its header.
+func (d *Document) Marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("#%RAML " + d.Version)
+	if d.Fragment != "" {
+		buf.WriteString(" " + d.Fragment)
+	}
+	buf.WriteString("\n")
+
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(2)
+	err := enc.Encode(d.root)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	return buf.Bytes(), err
+}
+
+func (d *Document) body() *yaml3.Node {
+	return d.root.Content[0]
+}
+
+func (d *Document) setRoot(key string, value *yaml3.Node) {
+	body := d.body()
+	if getNode(body, key) != nil {
+		setNode(body, key, value)
+		return
+	}
+	for i := 0; i+1 < len(body.Content); i += 2 {
+		if strings.HasPrefix(body.Content[i].Value, "/") {
+			// the resources come last
+			content := append([]*yaml3.Node{}, body.Content[:i]...)
+			content = append(content, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key}, value)
+			body.Content = append(content, body.Content[i:]...)
+			return
+		}
+	}
+	setNode(body, key, value)
+}
+
+func toNode(value interface{}) (*yaml3.Node, error) {
+	node := &yaml3.Node{}
+	err := node.Encode(value)
+	return node, err
+}
+
+func getNode(mapping *yaml3.Node, key string) *yaml3.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setNode(mapping *yaml3.Node, key string, value *yaml3.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			// keep the comments attached to the replaced value
+			value.HeadComment = mapping.Content[i+1].HeadComment
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
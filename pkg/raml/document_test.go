@@ -0,0 +1,94 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		version  string
+		fragment string
+		hasErr   bool
+	}{
+		{
+			name:    "should parse a RAML 1.0 header",
+			spec:    "#%RAML 1.0\ntitle: petstore\n",
+			version: Version10,
+		},
+		{
+			name:    "should parse a RAML 0.8 header with a BOM and CRLF line endings",
+			spec:    "\ufeff#%RAML 0.8\r\ntitle: petstore\r\n",
+			version: Version08,
+		},
+		{
+			name:     "should parse the fragment type",
+			spec:     "#%RAML 1.0 Library  \ntypes: {}\n",
+			version:  Version10,
+			fragment: "Library",
+		},
+		{
+			name:   "should return an error for an unknown version",
+			spec:   "#%RAML 2.0\ntitle: petstore\n",
+			hasErr: true,
+		},
+		{
+			name:   "should return an error for a version without a separator",
+			spec:   "#%RAML 1.0x\ntitle: petstore\n",
+			hasErr: true,
+		},
+		{
+			name:   "should return an error without a header",
+			spec:   "title: petstore\n",
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			version, fragment, err := ParseHeader([]byte(tc.spec))
+			if tc.hasErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.version, version)
+			assert.Equal(t, tc.fragment, fragment)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "#%RAML 0.8\ntitle: petstore\n", string(Normalize([]byte("\ufeff#%RAML 0.8\r\ntitle: petstore\r\n"))))
+	// other specs are left alone
+	assert.Equal(t, "{\"openapi\":\"3.0.1\"}\r\n", string(Normalize([]byte("{\"openapi\":\"3.0.1\"}\r\n"))))
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := Parse([]byte("#%RAML 1.0\n# petstore\ntitle: petstore\nsecuritySchemes:\n  basic:\n    type: x-old\n/pets:\n  get: {}\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, Version10, doc.Version)
+
+	assert.Nil(t, doc.Set("title", "pets"))
+	assert.Nil(t, doc.Set("baseUri", "https://petstore.test.com"))
+	assert.Nil(t, doc.AddSecurityScheme("basic", SecurityScheme{Type: "Basic Authentication"}))
+	out, err := doc.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, "#%RAML 1.0\n# petstore\ntitle: pets\nsecuritySchemes:\n  basic:\n    type: Basic Authentication\nbaseUri: https://petstore.test.com\n/pets:\n  get: {}\n", string(out))
+
+	doc, err = Parse([]byte("#%RAML 0.8\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, doc.AddSecurityScheme("basic", SecurityScheme{Type: "Basic Authentication"}))
+	assert.Nil(t, doc.AddSecurityScheme("basic", SecurityScheme{Type: "Basic Authentication", Description: "basic"}))
+	out, err = doc.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, "#%RAML 0.8\nsecuritySchemes:\n  - basic:\n      description: basic\n      type: Basic Authentication\n", string(out))
+
+	_, err = Parse([]byte("#%RAML 1.0\n- petstore\n"))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte("#%RAML 1.0\ntitle: [petstore\n"))
+	assert.NotNil(t, err)
+}
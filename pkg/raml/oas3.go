@@ -0,0 +1,668 @@
+package raml
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v2"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+	// uriParameter matches the parameters of a relative uri, e.g. /pets/{id}
+	uriParameter = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// scalarTypes maps the RAML scalar types to their JSON schema type and format.
+var scalarTypes = map[string][2]string{
+	"string":        {openapi3.TypeString, ""},
+	"number":        {openapi3.TypeNumber, ""},
+	"integer":       {openapi3.TypeInteger, ""},
+	"boolean":       {openapi3.TypeBoolean, ""},
+	"date-only":     {openapi3.TypeString, "date"},
+	"time-only":     {openapi3.TypeString, "time"},
+	"datetime-only": {openapi3.TypeString, "date-time"},
+	"datetime":      {openapi3.TypeString, "date-time"},
+	"file":          {openapi3.TypeString, "binary"},
+	"object":        {openapi3.TypeObject, ""},
+	"array":         {openapi3.TypeArray, ""},
+}
+
+// ConvertToOAS3 converts a RAML 1.0 API definition to an OpenAPI 3 spec in JSON. The resources, methods, types,
+// parameters, bodies, responses, security schemes and the traits without parameters are converted. Includes and
+// libraries have to be resolved first, see Bundle.
+func ConvertToOAS3(spec []byte) ([]byte, error) {
+	version, fragment, err := ParseHeader(spec)
+	if err != nil {
+		return nil, err
+	}
+	if version != Version10 || fragment != "" {
+		return nil, fmt.Errorf("only RAML 1.0 API definitions can be converted to OAS 3")
+	}
+
+	def := yaml.MapSlice{}
+	err = yaml.Unmarshal(Normalize(spec), &def)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &converter{
+		def:        def,
+		mediaTypes: toStrings(getValue(def, "mediaType")),
+		types:      map[string]bool{},
+		traits:     asMap(getValue(def, "traits")),
+	}
+	if len(c.mediaTypes) == 0 {
+		c.mediaTypes = []string{"application/json"}
+	}
+	for _, key := range []string{"types", "schemas"} {
+		for _, t := range asMap(getValue(def, key)) {
+			c.types[fmt.Sprint(t.Key)] = true
+		}
+	}
+
+	return json.Marshal(c.convert())
+}
+
+type converter struct {
+	def        yaml.MapSlice
+	mediaTypes []string
+	// types holds the names of the declared types
+	types  map[string]bool
+	traits yaml.MapSlice
+}
+
+func (c *converter) convert() *openapi3.T {
+	version := asString(getValue(c.def, "version"))
+	doc := &openapi3.T{
+		OpenAPI: "3.0.1",
+		Info: &openapi3.Info{
+			Title:       asString(getValue(c.def, "title")),
+			Description: asString(getValue(c.def, "description")),
+			Version:     version,
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas:         openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{},
+		},
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0"
+	}
+
+	if server := c.server(version); server != nil {
+		doc.Servers = openapi3.Servers{server}
+	}
+
+	for _, key := range []string{"types", "schemas"} {
+		for _, t := range asMap(getValue(c.def, key)) {
+			doc.Components.Schemas[fmt.Sprint(t.Key)] = c.schema(t.Value)
+		}
+	}
+
+	for _, s := range asMap(getValue(c.def, "securitySchemes")) {
+		if scheme := securityScheme(asMap(s.Value)); scheme != nil {
+			doc.Components.SecuritySchemes[fmt.Sprint(s.Key)] = &openapi3.SecuritySchemeRef{Value: scheme}
+		}
+	}
+	doc.Security = c.security(getValue(c.def, "securedBy"), doc.Components.SecuritySchemes)
+
+	c.addResources(doc, "", nil, c.def)
+	return doc
+}
+
+// server converts the baseUri, the {version} parameter is replaced and the other parameters become variables.
+func (c *converter) server(version string) *openapi3.Server {
+	baseURI := asString(getValue(c.def, "baseUri"))
+	if baseURI == "" {
+		return nil
+	}
+	server := &openapi3.Server{URL: strings.ReplaceAll(baseURI, "{version}", version)}
+	params := asMap(getValue(c.def, "baseUriParameters"))
+	for _, match := range uriParameter.FindAllStringSubmatch(server.URL, -1) {
+		variable := &openapi3.ServerVariable{}
+		if param := asMap(getValue(params, match[1])); param != nil {
+			variable.Description = asString(getValue(param, "description"))
+			variable.Default = asString(getValue(param, "default"))
+			variable.Enum = toStrings(getValue(param, "enum"))
+		}
+		if variable.Default == "" && len(variable.Enum) > 0 {
+			variable.Default = variable.Enum[0]
+		}
+		if server.Variables == nil {
+			server.Variables = map[string]*openapi3.ServerVariable{}
+		}
+		server.Variables[match[1]] = variable
+	}
+	return server
+}
+
+// addResources adds the methods of the resources of the node and of their nested resources.
+func (c *converter) addResources(doc *openapi3.T, parentPath string, parentParams openapi3.Parameters, node yaml.MapSlice) {
+	for _, item := range node {
+		key := fmt.Sprint(item.Key)
+		if !strings.HasPrefix(key, "/") {
+			continue
+		}
+		resource := asMap(item.Value)
+		path := parentPath + key
+
+		params := append(openapi3.Parameters{}, parentParams...)
+		declared := asMap(getValue(resource, "uriParameters"))
+		for _, match := range uriParameter.FindAllStringSubmatch(key, -1) {
+			param := openapi3.NewPathParameter(match[1])
+			param.Schema = c.schema(getValue(declared, match[1]))
+			if p := asMap(getValue(declared, match[1])); p != nil {
+				param.Description = asString(getValue(p, "description"))
+			}
+			params = append(params, &openapi3.ParameterRef{Value: param})
+		}
+
+		pathItem := &openapi3.PathItem{
+			Description: asString(getValue(resource, "description")),
+			Summary:     asString(getValue(resource, "displayName")),
+		}
+		hasOperation := false
+		for _, method := range methods {
+			m, ok := lookup(resource, method)
+			if !ok {
+				continue
+			}
+			pathItem.SetOperation(strings.ToUpper(method), c.operation(asMap(m), doc.Components.SecuritySchemes))
+			hasOperation = true
+		}
+		if hasOperation {
+			pathItem.Parameters = params
+			doc.Paths.Set(path, pathItem)
+		}
+
+		c.addResources(doc, path, params, resource)
+	}
+}
+
+func (c *converter) operation(method yaml.MapSlice, schemes openapi3.SecuritySchemes) *openapi3.Operation {
+	op := openapi3.NewOperation()
+	op.Summary = asString(getValue(method, "displayName"))
+	op.Description = asString(getValue(method, "description"))
+
+	// the traits are applied before the method so the method takes precedence
+	sources := []yaml.MapSlice{}
+	for _, name := range toStrings(getValue(method, "is")) {
+		if trait := asMap(getValue(c.traits, name)); trait != nil {
+			sources = append(sources, trait)
+		}
+	}
+	sources = append(sources, method)
+
+	params := map[string]*openapi3.Parameter{}
+	names := []string{}
+	responses := yaml.MapSlice{}
+	for _, source := range sources {
+		for in, key := range map[string]string{openapi3.ParameterInQuery: "queryParameters", openapi3.ParameterInHeader: "headers"} {
+			for _, p := range asMap(getValue(source, key)) {
+				param := c.parameter(in, fmt.Sprint(p.Key), p.Value)
+				id := in + ":" + param.Name
+				if _, ok := params[id]; !ok {
+					names = append(names, id)
+				}
+				params[id] = param
+			}
+		}
+		for _, r := range asMap(getValue(source, "responses")) {
+			responses = setValue(responses, fmt.Sprint(r.Key), r.Value)
+		}
+		if body, ok := lookup(source, "body"); ok {
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithContent(c.content(body))}
+		}
+	}
+	sort.Strings(names)
+	for _, id := range names {
+		op.AddParameter(params[id])
+	}
+
+	op.Responses = openapi3.NewResponsesWithCapacity(len(responses))
+	for _, r := range responses {
+		resp := asMap(r.Value)
+		response := openapi3.NewResponse().WithDescription(asString(getValue(resp, "description")))
+		if body, ok := lookup(resp, "body"); ok {
+			response.Content = c.content(body)
+		}
+		for _, h := range asMap(getValue(resp, "headers")) {
+			header := c.parameter(openapi3.ParameterInHeader, fmt.Sprint(h.Key), h.Value)
+			if response.Headers == nil {
+				response.Headers = openapi3.Headers{}
+			}
+			name := header.Name
+			// a header object has no name and location
+			header.Name, header.In = "", ""
+			response.Headers[name] = &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: *header}}
+		}
+		op.Responses.Set(fmt.Sprint(r.Key), &openapi3.ResponseRef{Value: response})
+	}
+	if op.Responses.Len() == 0 {
+		op.Responses = openapi3.NewResponses()
+	}
+
+	if securedBy, ok := lookup(method, "securedBy"); ok {
+		if security := c.security(securedBy, schemes); security != nil {
+			op.Security = &security
+		}
+	}
+	return op
+}
+
+// parameter converts a query parameter or a header. A name ending with ? is optional, the parameters are required
+// by default.
+func (c *converter) parameter(in, name string, value interface{}) *openapi3.Parameter {
+	required := !strings.HasSuffix(name, "?")
+	param := &openapi3.Parameter{
+		Name:   strings.TrimSuffix(name, "?"),
+		In:     in,
+		Schema: c.schema(value),
+	}
+	if p := asMap(value); p != nil {
+		param.Description = asString(getValue(p, "description"))
+		if r, ok := getValue(p, "required").(bool); ok {
+			required = r
+		}
+	}
+	param.Required = required
+	return param
+}
+
+// content converts a body, which declares the types by media type, or the type of the default media types.
+func (c *converter) content(body interface{}) openapi3.Content {
+	content := openapi3.Content{}
+	bodyMap := asMap(body)
+	byMediaType := len(bodyMap) > 0
+	for _, item := range bodyMap {
+		if !strings.Contains(fmt.Sprint(item.Key), "/") {
+			byMediaType = false
+		}
+	}
+
+	if !byMediaType {
+		for _, mediaType := range c.mediaTypes {
+			content[mediaType] = openapi3.NewMediaType().WithSchemaRef(c.schema(body))
+		}
+		return content
+	}
+	for _, item := range bodyMap {
+		media := openapi3.NewMediaType().WithSchemaRef(c.schema(item.Value))
+		if m := asMap(item.Value); m != nil {
+			if example, ok := lookup(m, "example"); ok {
+				media.Example = toJSONValue(example)
+			}
+		}
+		content[fmt.Sprint(item.Key)] = media
+	}
+	return content
+}
+
+// schema converts a RAML type declaration, a type expression or an inline JSON schema.
+func (c *converter) schema(value interface{}) *openapi3.SchemaRef {
+	switch v := value.(type) {
+	case nil:
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+	case string:
+		return c.typeExpression(v)
+	case []interface{}:
+		// multiple inheritance
+		allOf := openapi3.SchemaRefs{}
+		for _, t := range v {
+			allOf = append(allOf, c.schema(t))
+		}
+		return openapi3.NewSchemaRef("", &openapi3.Schema{AllOf: allOf})
+	}
+
+	decl := asMap(value)
+	if decl == nil {
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+	}
+
+	typeValue, hasType := lookup(decl, "type")
+	if !hasType {
+		typeValue, hasType = lookup(decl, "schema")
+	}
+	properties := asMap(getValue(decl, "properties"))
+	_, hasItems := lookup(decl, "items")
+	if !hasType {
+		switch {
+		case properties != nil:
+			typeValue = "object"
+		case hasItems:
+			typeValue = "array"
+		default:
+			typeValue = "string"
+		}
+	}
+
+	base := c.schema(typeValue)
+	// a declared type, a union or multiple inheritance is extended, the facets of a built-in type are set directly
+	extends := base.Ref != "" || len(base.Value.AllOf) > 0 || len(base.Value.OneOf) > 0
+	schema := &openapi3.Schema{}
+	if !extends {
+		cp := *base.Value
+		schema = &cp
+	}
+	schema.Description = asString(getValue(decl, "description"))
+	if def, ok := lookup(decl, "default"); ok {
+		schema.Default = toJSONValue(def)
+	}
+	if example, ok := lookup(decl, "example"); ok {
+		schema.Example = toJSONValue(example)
+	}
+	if enum := asList(getValue(decl, "enum")); enum != nil {
+		for _, e := range enum {
+			schema.Enum = append(schema.Enum, toJSONValue(e))
+		}
+	}
+	if format := asString(getValue(decl, "format")); format != "" {
+		schema.Format = format
+	}
+	schema.Pattern = asString(getValue(decl, "pattern"))
+	if val, ok := asNumber(getValue(decl, "minLength")); ok {
+		schema.MinLength = uint64(val)
+	}
+	if val, ok := asNumber(getValue(decl, "maxLength")); ok {
+		maxLength := uint64(val)
+		schema.MaxLength = &maxLength
+	}
+	if val, ok := asNumber(getValue(decl, "minimum")); ok {
+		schema.Min = &val
+	}
+	if val, ok := asNumber(getValue(decl, "maximum")); ok {
+		schema.Max = &val
+	}
+	if val, ok := asNumber(getValue(decl, "minItems")); ok {
+		schema.MinItems = uint64(val)
+	}
+	if val, ok := asNumber(getValue(decl, "maxItems")); ok {
+		maxItems := uint64(val)
+		schema.MaxItems = &maxItems
+	}
+	if unique, ok := getValue(decl, "uniqueItems").(bool); ok {
+		schema.UniqueItems = unique
+	}
+	if items, ok := lookup(decl, "items"); ok {
+		schema.Items = c.schema(items)
+	}
+	if properties != nil {
+		schema.Type = &openapi3.Types{openapi3.TypeObject}
+		schema.Properties = openapi3.Schemas{}
+		for _, p := range properties {
+			name := fmt.Sprint(p.Key)
+			required := !strings.HasSuffix(name, "?")
+			name = strings.TrimSuffix(name, "?")
+			if prop := asMap(p.Value); prop != nil {
+				if r, ok := getValue(prop, "required").(bool); ok {
+					required = r
+				}
+			}
+			if required {
+				schema.Required = append(schema.Required, name)
+			}
+			schema.Properties[name] = c.schema(p.Value)
+		}
+	}
+	if additional, ok := getValue(decl, "additionalProperties").(bool); ok {
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Has: &additional}
+	}
+
+	if extends {
+		if schema.Description == "" && len(schema.Properties) == 0 && schema.Items == nil {
+			return base
+		}
+		extended := &openapi3.Schema{
+			Description: schema.Description,
+			AllOf:       openapi3.SchemaRefs{base},
+		}
+		if len(schema.Properties) > 0 || schema.Items != nil {
+			extended.AllOf = append(extended.AllOf, openapi3.NewSchemaRef("", schema))
+		}
+		schema = extended
+	}
+	return openapi3.NewSchemaRef("", schema)
+}
+
+// typeExpression converts a type name, an array or a union, e.g. Pet, Pet[], Cat | Dog, or an inline JSON schema.
+func (c *converter) typeExpression(expr string) *openapi3.SchemaRef {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") {
+		schema := &openapi3.Schema{}
+		if err := json.Unmarshal([]byte(expr), schema); err == nil {
+			return openapi3.NewSchemaRef("", schema)
+		}
+		return openapi3.NewSchemaRef("", openapi3.NewObjectSchema())
+	}
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") && !strings.Contains(expr[1:len(expr)-1], ")") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+
+	if union := splitUnion(expr); len(union) > 1 {
+		oneOf := openapi3.SchemaRefs{}
+		nullable := false
+		for _, member := range union {
+			if member == "nil" {
+				nullable = true
+				continue
+			}
+			oneOf = append(oneOf, c.typeExpression(member))
+		}
+		if len(oneOf) == 1 {
+			// Pet | nil is a nullable Pet
+			if oneOf[0].Ref != "" {
+				return openapi3.NewSchemaRef("", &openapi3.Schema{AllOf: oneOf, Nullable: nullable})
+			}
+			oneOf[0].Value.Nullable = nullable
+			return oneOf[0]
+		}
+		return openapi3.NewSchemaRef("", &openapi3.Schema{OneOf: oneOf, Nullable: nullable})
+	}
+
+	if strings.HasSuffix(expr, "[]") {
+		return openapi3.NewSchemaRef("", &openapi3.Schema{
+			Type:  &openapi3.Types{openapi3.TypeArray},
+			Items: c.typeExpression(strings.TrimSuffix(expr, "[]")),
+		})
+	}
+	if scalar, ok := scalarTypes[expr]; ok {
+		return openapi3.NewSchemaRef("", &openapi3.Schema{Type: &openapi3.Types{scalar[0]}, Format: scalar[1]})
+	}
+	if c.types[expr] {
+		return openapi3.NewSchemaRef(schemaRefPrefix+expr, nil)
+	}
+	if expr == "nil" {
+		return openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true})
+	}
+	// any and the types that are not declared
+	return openapi3.NewSchemaRef("", &openapi3.Schema{})
+}
+
+// security converts a securedBy, null allows anonymous access.
+func (c *converter) security(securedBy interface{}, schemes openapi3.SecuritySchemes) openapi3.SecurityRequirements {
+	requirements := openapi3.SecurityRequirements{}
+	for _, s := range asList(securedBy) {
+		if s == nil {
+			requirements = append(requirements, openapi3.NewSecurityRequirement())
+			continue
+		}
+		name := asString(s)
+		scopes := []string{}
+		if m := asMap(s); len(m) == 1 {
+			name = fmt.Sprint(m[0].Key)
+			scopes = toStrings(getValue(asMap(m[0].Value), "scopes"))
+		}
+		if _, ok := schemes[name]; !ok {
+			continue
+		}
+		requirements = append(requirements, openapi3.NewSecurityRequirement().Authenticate(name, scopes...))
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+	return requirements
+}
+
+// securityScheme converts a RAML security scheme, nil when the scheme has no OAS equivalent.
+func securityScheme(decl yaml.MapSlice) *openapi3.SecurityScheme {
+	description := asString(getValue(decl, "description"))
+	settings := asMap(getValue(decl, "settings"))
+	describedBy := asMap(getValue(decl, "describedBy"))
+
+	switch schemeType := asString(getValue(decl, "type")); {
+	case schemeType == "Basic Authentication":
+		return openapi3.NewSecurityScheme().WithType("http").WithScheme("basic").WithDescription(description)
+	case schemeType == "Digest Authentication":
+		return openapi3.NewSecurityScheme().WithType("http").WithScheme("digest").WithDescription(description)
+	case schemeType == "OAuth 2.0":
+		scopes := openapi3.StringMap{}
+		for _, scope := range toStrings(getValue(settings, "scopes")) {
+			scopes[scope] = ""
+		}
+		flow := func(authorization, token bool) *openapi3.OAuthFlow {
+			f := &openapi3.OAuthFlow{Scopes: scopes}
+			if authorization {
+				f.AuthorizationURL = asString(getValue(settings, "authorizationUri"))
+			}
+			if token {
+				f.TokenURL = asString(getValue(settings, "accessTokenUri"))
+			}
+			return f
+		}
+		flows := &openapi3.OAuthFlows{}
+		for _, grant := range toStrings(getValue(settings, "authorizationGrants")) {
+			switch grant {
+			case "authorization_code":
+				flows.AuthorizationCode = flow(true, true)
+			case "implicit":
+				flows.Implicit = flow(true, false)
+			case "password":
+				flows.Password = flow(false, true)
+			case "client_credentials":
+				flows.ClientCredentials = flow(false, true)
+			}
+		}
+		if flows.AuthorizationCode == nil && flows.Implicit == nil && flows.Password == nil && flows.ClientCredentials == nil {
+			flows.ClientCredentials = flow(false, true)
+		}
+		scheme := openapi3.NewSecurityScheme().WithType("oauth2").WithDescription(description)
+		scheme.Flows = flows
+		return scheme
+	case schemeType == "Pass Through" || strings.HasPrefix(schemeType, "x-"):
+		// the credential is passed in the first header or query parameter
+		for in, key := range map[string]string{"header": "headers", "query": "queryParameters"} {
+			params := asMap(getValue(describedBy, key))
+			if len(params) > 0 {
+				name := strings.TrimSuffix(fmt.Sprint(params[0].Key), "?")
+				return openapi3.NewSecurityScheme().WithType("apiKey").WithIn(in).WithName(name).WithDescription(description)
+			}
+		}
+	}
+	return nil
+}
+
+// splitUnion splits a type expression on the | that are not within parentheses.
+func splitUnion(expr string) []string {
+	members := []string{}
+	depth, start := 0, 0
+	for i, r := range expr {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				members = append(members, strings.TrimSpace(expr[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(members, strings.TrimSpace(expr[start:]))
+}
+
+// lookup returns the value of a key and whether the key is set, e.g. a method without a body is declared as get:
+func lookup(node yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range node {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func asMap(val interface{}) yaml.MapSlice {
+	m, _ := val.(yaml.MapSlice)
+	return m
+}
+
+func asList(val interface{}) []interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return nil
+	}
+	return []interface{}{val}
+}
+
+func asString(val interface{}) string {
+	if val == nil {
+		return ""
+	}
+	if _, ok := val.(yaml.MapSlice); ok {
+		return ""
+	}
+	return fmt.Sprint(val)
+}
+
+func asNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toStrings(val interface{}) []string {
+	res := []string{}
+	for _, v := range asList(val) {
+		if s := asString(v); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// toJSONValue converts the maps of a YAML value so that it can be marshalled to JSON.
+func toJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case yaml.MapSlice:
+		m := map[string]interface{}{}
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = toJSONValue(item.Value)
+		}
+		return m
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i := range v {
+			res[i] = toJSONValue(v[i])
+		}
+		return res
+	}
+	return val
+}
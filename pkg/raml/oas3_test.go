@@ -0,0 +1,39 @@
+package raml
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToOAS3(t *testing.T) {
+	spec, err := os.ReadFile("./testdata/petstore.raml")
+	assert.Nil(t, err)
+	expected, err := os.ReadFile("./testdata/petstore.oas3.json")
+	assert.Nil(t, err)
+
+	converted, err := ConvertToOAS3(spec)
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(expected), string(converted))
+
+	doc, err := openapi3.NewLoader().LoadFromData(converted)
+	assert.Nil(t, err)
+	assert.Nil(t, doc.Validate(context.Background()))
+}
+
+func TestConvertToOAS3Errors(t *testing.T) {
+	_, err := ConvertToOAS3([]byte("#%RAML 0.8\ntitle: petstore\n"))
+	assert.NotNil(t, err)
+
+	_, err = ConvertToOAS3([]byte("#%RAML 1.0 Library\ntypes: {}\n"))
+	assert.NotNil(t, err)
+
+	_, err = ConvertToOAS3([]byte("{\"openapi\":\"3.0.1\"}"))
+	assert.NotNil(t, err)
+
+	_, err = ConvertToOAS3([]byte("#%RAML 1.0\ntitle: [petstore\n"))
+	assert.NotNil(t, err)
+}
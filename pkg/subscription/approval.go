@@ -0,0 +1,163 @@
+package subscription
+
+import (
+	"fmt"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+)
+
+// accessRequestCache is the part of the SDK cache manager with the access requests
+type accessRequestCache interface {
+	ListAccessRequests() []*v1.ResourceInstance
+	AddAccessRequest(ri *v1.ResourceInstance)
+}
+
+// ContractApprovalJob checks the contracts that are waiting for approval in Mulesoft, and updates their access
+// requests once the contracts are approved, rejected, removed or not approved in time.
+type ContractApprovalJob struct {
+	client   MuleSubscriptionClient
+	central  centralClient
+	cache    accessRequestCache
+	notifier Notifier
+	timeout  time.Duration
+	log      logrus.FieldLogger
+}
+
+// NewContractApprovalJob creates a ContractApprovalJob
+func NewContractApprovalJob(
+	client MuleSubscriptionClient,
+	central centralClient,
+	cache accessRequestCache,
+	notifier Notifier,
+	timeout time.Duration,
+	log logrus.FieldLogger,
+) *ContractApprovalJob {
+	return &ContractApprovalJob{
+		client:   client,
+		central:  central,
+		cache:    cache,
+		notifier: notifier,
+		timeout:  timeout,
+		log:      log.WithField("component", "contract-approval"),
+	}
+}
+
+// Execute checks the contracts of the access requests waiting for approval
+func (j *ContractApprovalJob) Execute() error {
+	for _, ri := range j.cache.ListAccessRequests() {
+		details := util.GetAgentDetailStrings(ri)
+		if details[common.ContractStatus] != common.ContractPending {
+			continue
+		}
+		j.check(ri, details)
+	}
+	return nil
+}
+
+// Status returns nil, a failure to check a contract is retried on the next run
+func (j *ContractApprovalJob) Status() error {
+	return nil
+}
+
+// Ready returns true
+func (j *ContractApprovalJob) Ready() bool {
+	return true
+}
+
+func (j *ContractApprovalJob) check(ri *v1.ResourceInstance, details map[string]string) {
+	apiID := details[common.APIID]
+	contractID := details[common.ContractID]
+	logger := j.log.
+		WithField("accessRequest", ri.Name).
+		WithField("api", apiID).
+		WithField("contractID", contractID)
+
+	timedOut := j.timedOut(logger, ri, details)
+	timeoutMessage := fmt.Sprintf("contract %s was not approved in Mulesoft within %s", contractID, j.timeout)
+
+	contract, err := j.client.GetContract(apiID, contractID)
+	if anypoint.IsNotFound(err) {
+		// the contract was removed in Mulesoft, or by a previous run that failed to update the access request
+		message := fmt.Sprintf("contract %s was removed in Mulesoft", contractID)
+		if timedOut {
+			message = timeoutMessage
+		}
+		j.resolve(logger, ri, common.ContractRevoked, prov.Error, message)
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Warn("failed to read the contract, it is checked again on the next run")
+		return
+	}
+
+	switch contract.Status {
+	case common.ContractPending:
+		if !timedOut {
+			return
+		}
+		// the contract is removed so that it cannot be approved after the access request failed
+		if err := j.client.DeleteContract(apiID, contractID); err != nil {
+			logger.WithError(err).Error("failed to remove the contract that was not approved in time")
+			return
+		}
+		j.resolve(logger, ri, common.ContractRevoked, prov.Error, timeoutMessage)
+	case common.ContractApproved:
+		j.resolve(logger, ri, contract.Status, prov.Success,
+			fmt.Sprintf("contract %s was approved in Mulesoft", contractID))
+	default:
+		j.resolve(logger, ri, contract.Status, prov.Error,
+			fmt.Sprintf("contract %s was %s in Mulesoft", contractID, contract.Status))
+	}
+}
+
+// timedOut returns true when the contract of an access request waited for approval longer than the timeout, since it
+// was requested, or since the access request was created when the request time is missing or invalid
+func (j *ContractApprovalJob) timedOut(logger logrus.FieldLogger, ri *v1.ResourceInstance, details map[string]string) bool {
+	requested, err := time.Parse(time.RFC3339, details[common.ContractRequested])
+	if err != nil {
+		requested = time.Time(ri.Metadata.Audit.CreateTimestamp)
+		if requested.IsZero() {
+			logger.Warn("the time the contract was requested is unknown, it does not time out")
+			return false
+		}
+		logger.WithField("created", requested).Debug("the time the contract was requested is unknown, using the creation of the access request")
+	}
+	return time.Since(requested) >= j.timeout
+}
+
+// resolve updates the status and the contract status of an access request, and sends a notification.
+func (j *ContractApprovalJob) resolve(
+	logger logrus.FieldLogger, ri *v1.ResourceInstance, contractStatus string, status prov.Status, message string,
+) {
+	ar := &management.AccessRequest{}
+	if err := ar.FromInstance(ri); err != nil {
+		logger.WithError(err).Error("failed to read the access request")
+		return
+	}
+
+	util.SetAgentDetailsKey(ri, common.ContractStatus, contractStatus)
+	err := setStatus(j.central, logger, ri, accessRequestFinalizer, ar.Status, status, message)
+	if err != nil {
+		logger.WithError(err).Error("failed to update the access request, it is checked again on the next run")
+		return
+	}
+	j.cache.AddAccessRequest(ri)
+
+	details := util.GetAgentDetailStrings(ri)
+	logger.WithField("contractStatus", contractStatus).Info(message)
+	j.notifier.Notify(ContractNotification{
+		AccessRequest: ri.Name,
+		Application:   ar.Spec.ManagedApplication,
+		APIID:         details[common.APIID],
+		ContractID:    details[common.ContractID],
+		Status:        contractStatus,
+		Message:       message,
+	})
+}
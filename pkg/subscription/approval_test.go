@@ -0,0 +1,277 @@
+package subscription
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCentralClient struct {
+	subResources      map[string]interface{}
+	removedFinalizers []string
+	addedFinalizers   []string
+	createSubResErr   error
+	// statuses are the updated statuses by resource kind/name
+	statuses map[string]*v1.ResourceStatus
+}
+
+func (m *mockCentralClient) CreateSubResource(rm v1.ResourceMeta, subs map[string]interface{}) error {
+	m.subResources = subs
+	if m.createSubResErr != nil {
+		return m.createSubResErr
+	}
+	status, ok := subs["status"].(*v1.ResourceStatus)
+	if !ok {
+		return nil
+	}
+	if m.statuses == nil {
+		m.statuses = map[string]*v1.ResourceStatus{}
+	}
+	m.statuses[rm.Kind+"/"+rm.Name] = status
+	return nil
+}
+
+func (m *mockCentralClient) UpdateResourceFinalizer(ri *v1.ResourceInstance, finalizer, _ string, addAction bool) (*v1.ResourceInstance, error) {
+	if !addAction {
+		m.removedFinalizers = append(m.removedFinalizers, finalizer)
+	} else {
+		m.addedFinalizers = append(m.addedFinalizers, finalizer)
+	}
+	return ri, nil
+}
+
+type mockAccessRequestCache struct {
+	accessRequests []*v1.ResourceInstance
+	added          []*v1.ResourceInstance
+}
+
+func (m *mockAccessRequestCache) ListAccessRequests() []*v1.ResourceInstance {
+	return m.accessRequests
+}
+
+func (m *mockAccessRequestCache) AddAccessRequest(ri *v1.ResourceInstance) {
+	m.added = append(m.added, ri)
+}
+
+type mockNotifier struct {
+	notifications []ContractNotification
+}
+
+func (m *mockNotifier) Notify(notification ContractNotification) {
+	m.notifications = append(m.notifications, notification)
+}
+
+func newPendingAccessRequest(t *testing.T, contractStatus string, requested time.Time) *v1.ResourceInstance {
+	ar := management.NewAccessRequest("ar1", "env")
+	ar.Spec.ManagedApplication = "app1"
+	ar.Status = &v1.ResourceStatus{Level: prov.Success.String()}
+	util.SetAgentDetails(ar, map[string]interface{}{
+		common.APIID:             "111",
+		common.ContractID:        "98765",
+		common.ContractStatus:    contractStatus,
+		common.ContractRequested: requested.UTC().Format(time.RFC3339),
+	})
+	ri, err := ar.AsInstance()
+	assert.Nil(t, err)
+	return ri
+}
+
+func TestContractApprovalJob(t *testing.T) {
+	tests := []struct {
+		name             string
+		contractStatus   string
+		requested        time.Time
+		contract         *anypoint.Contract
+		contractErr      error
+		created          time.Time
+		expectedStatus   string
+		expectedMessage  string
+		expectedLevel    string
+		removesContract  bool
+		removesFinalizer bool
+	}{
+		{
+			name:           "should move the access request to success when the contract is approved",
+			contractStatus: common.ContractPending,
+			requested:      time.Now(),
+			contract:       &anypoint.Contract{ID: 98765, Status: common.ContractApproved},
+			expectedStatus: common.ContractApproved,
+			expectedLevel:  prov.Success.String(),
+		},
+		{
+			name:             "should move the access request to failure when the contract is rejected",
+			contractStatus:   common.ContractPending,
+			requested:        time.Now(),
+			contract:         &anypoint.Contract{ID: 98765, Status: common.ContractRejected},
+			expectedStatus:   common.ContractRejected,
+			expectedLevel:    prov.Error.String(),
+			removesFinalizer: true,
+		},
+		{
+			name:             "should remove the contract and fail the access request when the approval times out",
+			contractStatus:   common.ContractPending,
+			requested:        time.Now().Add(-2 * time.Hour),
+			contract:         &anypoint.Contract{ID: 98765, Status: common.ContractPending},
+			expectedStatus:   common.ContractRevoked,
+			expectedLevel:    prov.Error.String(),
+			removesContract:  true,
+			removesFinalizer: true,
+		},
+		{
+			name:             "should fail the access request when the contract was removed in Mulesoft",
+			contractStatus:   common.ContractPending,
+			requested:        time.Now(),
+			contractErr:      fmt.Errorf("could not make request to Mulesoft: 404 - contract not found"),
+			expectedStatus:   common.ContractRevoked,
+			expectedLevel:    prov.Error.String(),
+			expectedMessage:  "contract 98765 was removed in Mulesoft",
+			removesFinalizer: true,
+		},
+		{
+			name:             "should fail the access request when the contract removed on timeout is not found",
+			contractStatus:   common.ContractPending,
+			requested:        time.Now().Add(-2 * time.Hour),
+			contractErr:      fmt.Errorf("could not make request to Mulesoft: 404 - contract not found"),
+			expectedStatus:   common.ContractRevoked,
+			expectedLevel:    prov.Error.String(),
+			expectedMessage:  "contract 98765 was not approved in Mulesoft within 1h0m0s",
+			removesFinalizer: true,
+		},
+		{
+			name:             "should time out from the creation of the access request when the request time is unknown",
+			contractStatus:   common.ContractPending,
+			created:          time.Now().Add(-2 * time.Hour),
+			contract:         &anypoint.Contract{ID: 98765, Status: common.ContractPending},
+			expectedStatus:   common.ContractRevoked,
+			expectedLevel:    prov.Error.String(),
+			removesContract:  true,
+			removesFinalizer: true,
+		},
+		{
+			name:           "should keep waiting when the request time and the creation of the access request are unknown",
+			contractStatus: common.ContractPending,
+			contract:       &anypoint.Contract{ID: 98765, Status: common.ContractPending},
+		},
+		{
+			name:           "should keep waiting for a pending contract",
+			contractStatus: common.ContractPending,
+			requested:      time.Now(),
+			contract:       &anypoint.Contract{ID: 98765, Status: common.ContractPending},
+		},
+		{
+			name:           "should keep waiting when the contract cannot be read",
+			contractStatus: common.ContractPending,
+			requested:      time.Now(),
+			contractErr:    fmt.Errorf("failed to read the contract"),
+		},
+		{
+			name:           "should skip access requests that are not waiting for approval",
+			contractStatus: common.ContractApproved,
+			requested:      time.Now(),
+			contract:       &anypoint.Contract{ID: 98765, Status: common.ContractRejected},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{contract: tc.contract, contractErr: tc.contractErr}
+			central := &mockCentralClient{}
+			ri := newPendingAccessRequest(t, tc.contractStatus, tc.requested)
+			if tc.requested.IsZero() {
+				util.SetAgentDetailsKey(ri, common.ContractRequested, "")
+				ri.Metadata.Audit.CreateTimestamp = v1.Time(tc.created)
+			}
+			cache := &mockAccessRequestCache{accessRequests: []*v1.ResourceInstance{ri}}
+			notifier := &mockNotifier{}
+
+			job := NewContractApprovalJob(client, central, cache, notifier, time.Hour, logrus.StandardLogger())
+			assert.True(t, job.Ready())
+			assert.Nil(t, job.Status())
+			assert.Nil(t, job.Execute())
+
+			if tc.expectedStatus == "" {
+				assert.Nil(t, central.subResources)
+				assert.Empty(t, cache.added)
+				assert.Empty(t, notifier.notifications)
+				assert.Empty(t, client.deletedContracts)
+				return
+			}
+
+			status := central.subResources["status"].(*v1.ResourceStatus)
+			assert.Equal(t, tc.expectedLevel, status.Level)
+			assert.Equal(t, tc.expectedStatus, util.GetAgentDetailStrings(cache.added[0])[common.ContractStatus])
+			if tc.expectedMessage != "" {
+				assert.Equal(t, tc.expectedMessage, status.Reasons[len(status.Reasons)-1].Detail)
+			}
+
+			assert.Len(t, notifier.notifications, 1)
+			assert.Equal(t, ContractNotification{
+				AccessRequest: "ar1",
+				Application:   "app1",
+				APIID:         "111",
+				ContractID:    "98765",
+				Status:        tc.expectedStatus,
+				Message:       status.Reasons[len(status.Reasons)-1].Detail,
+			}, notifier.notifications[0])
+
+			if tc.removesContract {
+				assert.Equal(t, []string{"98765"}, client.deletedContracts)
+			} else {
+				assert.Empty(t, client.deletedContracts)
+			}
+			if tc.removesFinalizer {
+				assert.Equal(t, []string{accessRequestFinalizer}, central.removedFinalizers)
+				assert.Empty(t, central.addedFinalizers)
+			} else {
+				// the SDK did not add its finalizer to the pending access request
+				assert.Empty(t, central.removedFinalizers)
+				assert.Equal(t, []string{accessRequestFinalizer}, central.addedFinalizers)
+			}
+		})
+	}
+}
+
+func TestPendingStatus(t *testing.T) {
+	status := newPendingStatus("waiting", map[string]string{common.ContractID: "98765"}, nil)
+	assert.Equal(t, prov.Pending, status.GetStatus())
+	assert.Equal(t, "waiting", status.GetMessage())
+	assert.Equal(t, map[string]string{common.ContractID: "98765"}, status.GetProperties())
+	assert.Empty(t, status.GetReasons())
+
+	// the last pending reason is replaced
+	current := prov.NewStatusReason(status)
+	assert.Equal(t, prov.Pending.String(), current.Level)
+	status = newPendingStatus("waiting", nil, current)
+	assert.Empty(t, status.GetReasons())
+	assert.Len(t, prov.NewStatusReason(status).Reasons, 1)
+
+	// the reasons of other levels are kept
+	current.Level = prov.Error.String()
+	current.Reasons[0].Type = prov.Error.String()
+	assert.Len(t, newPendingStatus("waiting", nil, current).GetReasons(), 1)
+}
+
+func TestContractApprovalJobUpdateError(t *testing.T) {
+	client := &MockMuleSubscriptionClient{contract: &anypoint.Contract{ID: 98765, Status: common.ContractApproved}}
+	central := &mockCentralClient{createSubResErr: fmt.Errorf("failed to update")}
+	cache := &mockAccessRequestCache{
+		accessRequests: []*v1.ResourceInstance{newPendingAccessRequest(t, common.ContractPending, time.Now())},
+	}
+	notifier := &mockNotifier{}
+
+	job := NewContractApprovalJob(client, central, cache, notifier, time.Hour, logrus.StandardLogger())
+	assert.Nil(t, job.Execute())
+
+	// the access request is checked again on the next run
+	assert.Empty(t, cache.added)
+	assert.Empty(t, notifier.notifications)
+}
@@ -0,0 +1,61 @@
+package subscription
+
+import (
+	"context"
+
+	"github.com/Axway/agent-sdk/pkg/agent/handler"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agent-sdk/pkg/watchmanager/proto"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+// AccessRequestHandlerName is the name the SDK registers its access request handler with
+const AccessRequestHandlerName = "accessrequestHandler"
+
+// accessRequestHandler wraps the access request handler of the SDK. The SDK provisions an access request again each
+// time its status is updated while it is Pending, so every Pending status written for a contract waiting for approval
+// would process the request again. These access requests are only cached, the ContractApprovalJob updates them once
+// their contract is approved, rejected or not approved in time.
+type accessRequestHandler struct {
+	next  handler.Handler
+	cache accessRequestCache
+}
+
+// NewAccessRequestHandler creates a handler that passes the events of the access requests that are not waiting for
+// approval to the SDK access request handler
+func NewAccessRequestHandler(next handler.Handler, cache accessRequestCache) handler.Handler {
+	return &accessRequestHandler{
+		next:  next,
+		cache: cache,
+	}
+}
+
+// Handle skips the access requests waiting for approval
+func (h *accessRequestHandler) Handle(ctx context.Context, meta *proto.EventMeta, resource *v1.ResourceInstance) error {
+	action := handler.GetActionFromContext(ctx)
+	if action != proto.Event_DELETED && waitingForApproval(resource) {
+		h.cache.AddAccessRequest(resource)
+		return nil
+	}
+	return h.next.Handle(ctx, meta, resource)
+}
+
+// waitingForApproval returns true for a pending access request whose contract is pending in Mulesoft
+func waitingForApproval(ri *v1.ResourceInstance) bool {
+	if ri == nil || ri.Kind != management.AccessRequestGVK().Kind {
+		return false
+	}
+	ar := &management.AccessRequest{}
+	if err := ar.FromInstance(ri); err != nil {
+		return false
+	}
+	if ar.Status == nil || ar.Status.Level != prov.Pending.String() || ar.Metadata.State == v1.ResourceDeleting ||
+		ar.Spec.AccessRequest != "" {
+		return false
+	}
+	details := util.GetAgentDetailStrings(ri)
+	return details[common.ContractID] != "" && details[common.ContractStatus] == common.ContractPending
+}
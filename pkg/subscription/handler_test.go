@@ -0,0 +1,103 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/agent/handler"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agent-sdk/pkg/watchmanager/proto"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHandler struct {
+	handled []*v1.ResourceInstance
+}
+
+func (m *mockHandler) Handle(_ context.Context, _ *proto.EventMeta, resource *v1.ResourceInstance) error {
+	m.handled = append(m.handled, resource)
+	return nil
+}
+
+func TestAccessRequestHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		action         proto.Event_Type
+		level          string
+		state          string
+		contractStatus string
+		skipped        bool
+	}{
+		{
+			name:           "should skip a pending access request waiting for approval",
+			action:         proto.Event_SUBRESOURCEUPDATED,
+			level:          prov.Pending.String(),
+			contractStatus: common.ContractPending,
+			skipped:        true,
+		},
+		{
+			name:   "should handle a new access request",
+			action: proto.Event_CREATED,
+			level:  prov.Pending.String(),
+		},
+		{
+			name:           "should handle a pending access request whose contract was approved",
+			action:         proto.Event_SUBRESOURCEUPDATED,
+			level:          prov.Pending.String(),
+			contractStatus: common.ContractApproved,
+		},
+		{
+			name:           "should handle a provisioned access request",
+			action:         proto.Event_UPDATED,
+			level:          prov.Success.String(),
+			contractStatus: common.ContractPending,
+		},
+		{
+			name:           "should handle an access request being deleted",
+			action:         proto.Event_UPDATED,
+			level:          prov.Pending.String(),
+			state:          v1.ResourceDeleting,
+			contractStatus: common.ContractPending,
+		},
+		{
+			name:           "should handle a deleted access request",
+			action:         proto.Event_DELETED,
+			level:          prov.Pending.String(),
+			contractStatus: common.ContractPending,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ar := management.NewAccessRequest("ar1", "env")
+			ar.Status = &v1.ResourceStatus{Level: tc.level}
+			ar.Metadata.State = tc.state
+			if tc.contractStatus != "" {
+				util.SetAgentDetails(ar, map[string]interface{}{
+					common.ContractID:     "98765",
+					common.ContractStatus: tc.contractStatus,
+				})
+			}
+			ri, err := ar.AsInstance()
+			assert.Nil(t, err)
+
+			next := &mockHandler{}
+			cache := &mockAccessRequestCache{}
+			h := NewAccessRequestHandler(next, cache)
+
+			ctx := handler.NewEventContext(tc.action, nil, ri.Kind, ri.Name)
+			assert.Nil(t, h.Handle(ctx, nil, ri))
+			if tc.skipped {
+				assert.Empty(t, next.handled)
+				assert.Equal(t, 1, len(cache.added))
+				return
+			}
+			assert.Equal(t, 1, len(next.handled))
+			assert.Empty(t, cache.added)
+		})
+	}
+}
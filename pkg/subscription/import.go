@@ -0,0 +1,315 @@
+package subscription
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+)
+
+// importCache is the part of the SDK cache manager the import reads to skip the resources already in Central
+type importCache interface {
+	reconcileCache
+	ListAPIServiceInstances() []*v1.ResourceInstance
+}
+
+// importCentralClient is the part of the Central client that creates marketplace resources
+type importCentralClient interface {
+	centralClient
+	GetResource(url string) (*v1.ResourceInstance, error)
+	CreateResourceInstance(ri v1.Interface) (*v1.ResourceInstance, error)
+}
+
+// ImportJob imports the Mulesoft client applications and their contracts for the discovered APIs into Central. Each
+// application is imported as a ManagedApplication with a Credential, and each contract as an AccessRequest. The
+// resources are named after the Mulesoft ids, and the applications and contracts already in Central are skipped, so
+// the import can run again.
+type ImportJob struct {
+	client  MuleSubscriptionClient
+	central importCentralClient
+	cache   importCache
+	envName string
+	log     logrus.FieldLogger
+}
+
+// NewImportJob creates an ImportJob
+func NewImportJob(
+	client MuleSubscriptionClient,
+	central importCentralClient,
+	cache importCache,
+	envName string,
+	log logrus.FieldLogger,
+) *ImportJob {
+	return &ImportJob{
+		client:  client,
+		central: central,
+		cache:   cache,
+		envName: envName,
+		log:     log.WithField("component", "import"),
+	}
+}
+
+// Execute imports the applications and contracts that are not in Central yet
+func (j *ImportJob) Execute() error {
+	list, err := j.client.ListApps()
+	if err != nil {
+		j.log.WithError(err).Warn("failed to list the Mulesoft applications, the import runs again on the next interval")
+		return nil
+	}
+	apps := map[string]anypoint.Application{}
+	for _, app := range list {
+		apps[strconv.Itoa(app.ID)] = app
+	}
+
+	managedApps := j.managedApps()
+	contracts := j.importedContracts()
+	instances := j.instancesByAPI()
+
+	apiIDs := make([]string, 0, len(instances))
+	for apiID := range instances {
+		apiIDs = append(apiIDs, apiID)
+	}
+	sort.Strings(apiIDs)
+
+	imported := 0
+	for _, apiID := range apiIDs {
+		apiContracts, err := j.client.ListContracts(apiID)
+		if err != nil {
+			j.log.WithError(err).WithField("api", apiID).Warn("failed to list the contracts of the api")
+			continue
+		}
+
+		for _, contract := range apiContracts {
+			contractID := strconv.Itoa(contract.ID)
+			if contracts[contractID] {
+				continue
+			}
+			if contract.Status != common.ContractApproved && contract.Status != common.ContractPending {
+				continue
+			}
+			app, ok := apps[strconv.Itoa(contract.ApplicationID)]
+			if !ok {
+				continue
+			}
+
+			logger := j.log.WithField("api", apiID).WithField("appID", app.ID).WithField("contractID", contract.ID)
+			appName, ok := managedApps[strconv.Itoa(app.ID)]
+			if !ok {
+				appName, err = j.importApp(logger, app, instances[apiID])
+				if err != nil {
+					logger.WithError(err).Error("failed to import the application")
+					continue
+				}
+				managedApps[strconv.Itoa(app.ID)] = appName
+			}
+
+			if err := j.importContract(logger, apiID, contract, instances[apiID].Name, appName); err != nil {
+				logger.WithError(err).Error("failed to import the contract")
+				continue
+			}
+			contracts[contractID] = true
+			imported++
+		}
+	}
+
+	if imported > 0 {
+		j.log.WithField("contracts", imported).Info("imported Mulesoft contracts")
+	}
+	return nil
+}
+
+// Status returns nil, a failure to import a resource is retried on the next run
+func (j *ImportJob) Status() error {
+	return nil
+}
+
+// Ready returns true
+func (j *ImportJob) Ready() bool {
+	return true
+}
+
+// managedApps returns the names of the managed applications by Mule app id
+func (j *ImportJob) managedApps() map[string]string {
+	managedApps := map[string]string{}
+	for _, key := range j.cache.GetManagedApplicationCacheKeys() {
+		ri := j.cache.GetManagedApplication(key)
+		if ri == nil {
+			continue
+		}
+		if appID := util.GetAgentDetailStrings(ri)[common.AppID]; appID != "" {
+			managedApps[appID] = ri.Name
+		}
+	}
+	return managedApps
+}
+
+// importedContracts returns the ids of the contracts of the access requests
+func (j *ImportJob) importedContracts() map[string]bool {
+	contracts := map[string]bool{}
+	for _, ri := range j.cache.ListAccessRequests() {
+		if contractID := util.GetAgentDetailStrings(ri)[common.ContractID]; contractID != "" {
+			contracts[contractID] = true
+		}
+	}
+	return contracts
+}
+
+// instancesByAPI returns the discovered instances by Mulesoft api id
+func (j *ImportJob) instancesByAPI() map[string]*management.APIServiceInstance {
+	instances := map[string]*management.APIServiceInstance{}
+	for _, ri := range j.cache.ListAPIServiceInstances() {
+		apiID := util.GetAgentDetailStrings(ri)[common.AttrAPIID]
+		if _, ok := instances[apiID]; apiID == "" || ok {
+			continue
+		}
+		instance := &management.APIServiceInstance{}
+		if err := instance.FromInstance(ri); err != nil {
+			continue
+		}
+		instances[apiID] = instance
+	}
+	return instances
+}
+
+// importApp creates a provisioned ManagedApplication for a Mule app, and a Credential that the agent provisions with
+// the client id and secret of the app.
+func (j *ImportJob) importApp(
+	logger logrus.FieldLogger, app anypoint.Application, instance *management.APIServiceInstance,
+) (string, error) {
+	name := fmt.Sprintf("mulesoft-app-%d", app.ID)
+	managedApp := management.NewManagedApplication(name, j.envName)
+	managedApp.Title = app.Name
+
+	ri, err := j.getOrCreate(managedApp, managedApp.GetSelfLink())
+	if err != nil {
+		return "", err
+	}
+	util.SetAgentDetails(ri, map[string]interface{}{
+		common.AppID:    strconv.Itoa(app.ID),
+		common.Imported: "true",
+	})
+	message := fmt.Sprintf("imported from Mulesoft application %d", app.ID)
+	if err := j.provisioned(logger, ri, managedAppFinalizer, message); err != nil {
+		return "", err
+	}
+	logger.WithField("managedApp", name).Info(message)
+
+	crd := credentialRequestDefinition(app, instance.Spec.CredentialRequestDefinitions)
+	if crd == "" {
+		return name, nil
+	}
+	credential := management.NewCredential(name, j.envName)
+	credential.Spec = management.CredentialSpec{
+		CredentialRequestDefinition: crd,
+		ManagedApplication:          name,
+		Data:                        map[string]interface{}{},
+	}
+	if _, err := j.central.GetResource(credential.GetSelfLink()); err == nil {
+		return name, nil
+	}
+	credRI, err := j.central.CreateResourceInstance(credential)
+	if err != nil {
+		logger.WithError(err).Error("failed to create the credential of the imported application")
+		return name, nil
+	}
+	// the SDK provisions pending credentials with the client id and secret of the app
+	err = j.central.CreateSubResource(credRI.ResourceMeta, map[string]interface{}{
+		"status": &v1.ResourceStatus{
+			Level: prov.Pending.String(),
+			Reasons: []v1.ResourceStatusReason{{
+				Type:      prov.Pending.String(),
+				Detail:    message,
+				Timestamp: v1.Time(time.Now()),
+			}},
+		},
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to request the credential of the imported application")
+	}
+	return name, nil
+}
+
+// credentialRequestDefinition returns the credential request definition of the instance that matches the auth type of
+// the app. Apps with OAuth grant types get an OAuth credential, the other apps a client id credential, when the
+// instance has one.
+func credentialRequestDefinition(app anypoint.Application, crds []string) string {
+	preferred := []string{prov.APIKeyCRD, prov.BasicAuthCRD, prov.OAuthSecretCRD}
+	if len(app.GrantTypes) > 0 {
+		preferred = []string{prov.OAuthSecretCRD, prov.APIKeyCRD, prov.BasicAuthCRD}
+	}
+	for _, crd := range preferred {
+		for _, available := range crds {
+			if available == crd {
+				return crd
+			}
+		}
+	}
+	return ""
+}
+
+// importContract creates a provisioned AccessRequest for a contract
+func (j *ImportJob) importContract(
+	logger logrus.FieldLogger, apiID string, contract anypoint.Contract, instanceName, appName string,
+) error {
+	name := fmt.Sprintf("mulesoft-contract-%d", contract.ID)
+	ar := management.NewAccessRequest(name, j.envName)
+	ar.Spec = management.AccessRequestSpec{
+		ApiServiceInstance: instanceName,
+		ManagedApplication: appName,
+		Data:               map[string]interface{}{},
+	}
+
+	ri, err := j.getOrCreate(ar, ar.GetSelfLink())
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		common.ContractID: strconv.Itoa(contract.ID),
+		common.Imported:   "true",
+	}
+	if contract.RequestedTierID > 0 {
+		details[common.SlaTier] = strconv.Itoa(contract.RequestedTierID)
+	}
+	if contract.Status == common.ContractPending {
+		details[common.ContractStatus] = contract.Status
+		details[common.ContractRequested] = time.Now().UTC().Format(time.RFC3339)
+		details[common.APIID] = apiID
+	}
+	util.SetAgentDetails(ri, details)
+
+	message := fmt.Sprintf("imported from Mulesoft contract %d", contract.ID)
+	if contract.Status == common.ContractPending {
+		// the approval job moves the access request to Success once the contract is approved
+		err = setStatus(j.central, logger, ri, accessRequestFinalizer, nil, prov.Pending, message+", waiting for approval")
+	} else {
+		err = j.provisioned(logger, ri, accessRequestFinalizer, message)
+	}
+	if err != nil {
+		return err
+	}
+	j.cache.AddAccessRequest(ri)
+	logger.WithField("accessRequest", name).Info(message)
+	return nil
+}
+
+// getOrCreate returns the resource when it is in Central already, or creates it
+func (j *ImportJob) getOrCreate(resource v1.Interface, selfLink string) (*v1.ResourceInstance, error) {
+	if ri, err := j.central.GetResource(selfLink); err == nil && ri != nil {
+		return ri, nil
+	}
+	return j.central.CreateResourceInstance(resource)
+}
+
+// provisioned sets the Success status of an imported resource, with the finalizer of the SDK so that the resource
+// is deprovisioned when it is removed.
+func (j *ImportJob) provisioned(logger logrus.FieldLogger, ri *v1.ResourceInstance, finalizer, message string) error {
+	return setStatus(j.central, logger, ri, finalizer, nil, prov.Success, message)
+}
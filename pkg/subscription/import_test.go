@@ -0,0 +1,231 @@
+package subscription
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockImportCentralClient struct {
+	mockCentralClient
+	resources       map[string]*v1.ResourceInstance
+	created         []string
+	addedFinalizers []string
+}
+
+func (m *mockImportCentralClient) GetResource(url string) (*v1.ResourceInstance, error) {
+	if ri, ok := m.resources[url]; ok {
+		return ri, nil
+	}
+	return nil, fmt.Errorf("resource %s not found", url)
+}
+
+func (m *mockImportCentralClient) CreateResourceInstance(resource v1.Interface) (*v1.ResourceInstance, error) {
+	ri, err := resource.AsInstance()
+	if err != nil {
+		return nil, err
+	}
+	if m.resources == nil {
+		m.resources = map[string]*v1.ResourceInstance{}
+	}
+	m.resources[ri.GetSelfLink()] = ri
+	m.created = append(m.created, ri.Kind+"/"+ri.Name)
+	return ri, nil
+}
+
+func (m *mockImportCentralClient) GetAPIV1ResourceInstances(_ map[string]string, url string) ([]*v1.ResourceInstance, error) {
+	instances := []*v1.ResourceInstance{}
+	for _, ri := range m.resources {
+		if strings.HasPrefix(ri.GetSelfLink(), url+"/") {
+			instances = append(instances, ri)
+		}
+	}
+	return instances, nil
+}
+
+func (m *mockImportCentralClient) UpdateResourceFinalizer(ri *v1.ResourceInstance, finalizer, _ string, addAction bool) (*v1.ResourceInstance, error) {
+	if addAction {
+		m.addedFinalizers = append(m.addedFinalizers, finalizer)
+	}
+	return m.mockCentralClient.UpdateResourceFinalizer(ri, finalizer, "", addAction)
+}
+
+type mockImportCache struct {
+	*mockReconcileCache
+}
+
+func (m *mockImportCache) ListAPIServiceInstances() []*v1.ResourceInstance {
+	instances := []*v1.ResourceInstance{}
+	for _, ri := range m.instances {
+		instances = append(instances, ri)
+	}
+	return instances
+}
+
+// newImportCache returns a cache with a discovered instance, and optionally the managed application of the Mule app 555
+// and the access request of the contract 98765.
+func newImportCache(t *testing.T, withApp, withAccessRequest bool) *mockImportCache {
+	cache := newReconcileCache(t, "")
+
+	instance := management.NewAPIServiceInstance("inst1", "env")
+	instance.Spec.CredentialRequestDefinitions = []string{prov.OAuthSecretCRD}
+	util.SetAgentDetails(instance, map[string]interface{}{common.AttrAPIID: "111"})
+	instanceRI, err := instance.AsInstance()
+	assert.Nil(t, err)
+	cache.instances["inst1"] = instanceRI
+
+	if !withApp {
+		cache.managedApps = map[string]*v1.ResourceInstance{}
+	}
+	if !withAccessRequest {
+		cache.accessRequests = []*v1.ResourceInstance{}
+	}
+	return &mockImportCache{cache}
+}
+
+func TestImportJob(t *testing.T) {
+	muleApps := []anypoint.Application{
+		{ID: 555, Name: "Mobile App", Description: "created in Mulesoft"},
+	}
+
+	tests := []struct {
+		name              string
+		withApp           bool
+		withAccessRequest bool
+		contracts         []anypoint.Contract
+		listErr           error
+		expectedCreated   []string
+		expectedApp       string
+		expectedDetails   map[string]string
+	}{
+		{
+			name:      "should import an application with its contract and a credential",
+			contracts: []anypoint.Contract{{ID: 98765, ApplicationID: 555, RequestedTierID: 14214, Status: common.ContractApproved}},
+			expectedCreated: []string{
+				"ManagedApplication/mulesoft-app-555",
+				"Credential/mulesoft-app-555",
+				"AccessRequest/mulesoft-contract-98765",
+			},
+			expectedApp: "mulesoft-app-555",
+			expectedDetails: map[string]string{
+				common.ContractID: "98765",
+				common.SlaTier:    "14214",
+				common.Imported:   "true",
+			},
+		},
+		{
+			name:            "should import a contract waiting for approval",
+			contracts:       []anypoint.Contract{{ID: 98765, ApplicationID: 555, Status: common.ContractPending}},
+			expectedCreated: []string{"ManagedApplication/mulesoft-app-555", "Credential/mulesoft-app-555", "AccessRequest/mulesoft-contract-98765"},
+			expectedApp:     "mulesoft-app-555",
+			expectedDetails: map[string]string{
+				common.ContractID:     "98765",
+				common.ContractStatus: common.ContractPending,
+				common.APIID:          "111",
+			},
+		},
+		{
+			name:            "should import the contract of an application in Central",
+			withApp:         true,
+			contracts:       []anypoint.Contract{{ID: 98765, ApplicationID: 555, Status: common.ContractApproved}},
+			expectedCreated: []string{"AccessRequest/mulesoft-contract-98765"},
+			expectedApp:     "app1",
+			expectedDetails: map[string]string{common.ContractID: "98765"},
+		},
+		{
+			name:              "should skip the contracts in Central",
+			withApp:           true,
+			withAccessRequest: true,
+			contracts:         []anypoint.Contract{{ID: 98765, ApplicationID: 555, Status: common.ContractApproved}},
+		},
+		{
+			name: "should skip revoked contracts and contracts of unknown applications",
+			contracts: []anypoint.Contract{
+				{ID: 98765, ApplicationID: 555, Status: common.ContractRevoked},
+				{ID: 98766, ApplicationID: 999, Status: common.ContractApproved},
+			},
+		},
+		{
+			name:    "should not import when the Mule apps cannot be listed",
+			listErr: fmt.Errorf("failed to list"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				apps:      muleApps,
+				contracts: map[string][]anypoint.Contract{"111": tc.contracts},
+				listErr:   tc.listErr,
+			}
+			central := &mockImportCentralClient{}
+			cache := newImportCache(t, tc.withApp, tc.withAccessRequest)
+
+			job := NewImportJob(client, central, cache, "env", logrus.StandardLogger())
+			assert.True(t, job.Ready())
+			assert.Nil(t, job.Status())
+			assert.Nil(t, job.Execute())
+			assert.Equal(t, tc.expectedCreated, central.created)
+
+			if tc.expectedApp == "" {
+				assert.Empty(t, cache.added)
+				return
+			}
+
+			ar := &management.AccessRequest{}
+			assert.Nil(t, ar.FromInstance(cache.added[0]))
+			assert.Equal(t, "inst1", ar.Spec.ApiServiceInstance)
+			assert.Equal(t, tc.expectedApp, ar.Spec.ManagedApplication)
+			details := util.GetAgentDetailStrings(cache.added[0])
+			for key, value := range tc.expectedDetails {
+				assert.Equal(t, value, details[key], key)
+			}
+			if details[common.ContractStatus] == common.ContractPending {
+				// the approval job provisions the access request once the contract is approved
+				assert.Equal(t, prov.Pending.String(), central.statuses["AccessRequest/"+ar.Name].Level)
+				assert.NotContains(t, central.addedFinalizers, accessRequestFinalizer)
+			} else {
+				assert.Equal(t, prov.Success.String(), central.statuses["AccessRequest/"+ar.Name].Level)
+				assert.Contains(t, central.addedFinalizers, accessRequestFinalizer)
+			}
+
+			if tc.expectedApp != "mulesoft-app-555" {
+				return
+			}
+			// the managed application is provisioned, the credential is provisioned by the SDK
+			assert.Equal(t, prov.Success.String(), central.statuses["ManagedApplication/mulesoft-app-555"].Level)
+			assert.Equal(t, prov.Pending.String(), central.statuses["Credential/mulesoft-app-555"].Level)
+			app := central.resources[management.NewManagedApplication("mulesoft-app-555", "env").GetSelfLink()]
+			assert.Equal(t, "555", util.GetAgentDetailStrings(app)[common.AppID])
+			assert.Equal(t, "true", util.GetAgentDetailStrings(app)[common.Imported])
+			assert.Contains(t, central.addedFinalizers, managedAppFinalizer)
+
+			// the import can run again
+			created := central.created
+			cache.added = nil
+			assert.Nil(t, job.Execute())
+			assert.Equal(t, created, central.created)
+		})
+	}
+}
+
+func TestCredentialRequestDefinition(t *testing.T) {
+	oauthApp := anypoint.Application{ID: 1, GrantTypes: []string{"client_credentials"}}
+	clientIDApp := anypoint.Application{ID: 2}
+	all := []string{prov.OAuthSecretCRD, prov.BasicAuthCRD, prov.APIKeyCRD}
+
+	assert.Equal(t, prov.OAuthSecretCRD, credentialRequestDefinition(oauthApp, all))
+	assert.Equal(t, prov.APIKeyCRD, credentialRequestDefinition(clientIDApp, all))
+	assert.Equal(t, prov.BasicAuthCRD, credentialRequestDefinition(clientIDApp, []string{prov.OAuthSecretCRD, prov.BasicAuthCRD}))
+	assert.Equal(t, prov.BasicAuthCRD, credentialRequestDefinition(oauthApp, []string{prov.BasicAuthCRD}))
+	assert.Equal(t, "", credentialRequestDefinition(oauthApp, []string{}))
+}
@@ -10,32 +10,111 @@ type MockMuleSubscriptionClient struct {
 	err       error
 	rotateErr error
 	contract  *anypoint.Contract
+	// contractErr is returned when the contract is read
+	contractErr error
+	// deletedContracts are the ids of the deleted contracts
+	deletedContracts []string
+	// contracts are the contracts listed per api id
+	contracts map[string][]anypoint.Contract
+	// apps are the listed apps, listErr is returned when listing contracts or apps
+	apps    []anypoint.Application
+	listErr error
+	// createdContracts are the ids of the apps a contract was created for
+	createdContracts []string
+	// tierErr and createContractErr fail the creation of the SLA tier and of the contract
+	tierErr           error
+	createContractErr error
+	// deletedApps are the ids of the deleted apps, deleteErr is returned when an app is deleted
+	deletedApps []string
+	deleteErr   error
+	// appDescription is the description of the created app
+	appDescription string
+	// appContracts are the contracts of the app, revokedContracts and restoredContracts the ids of the revoked and
+	// restored contracts, revokeErr is returned when a contract is revoked or restored
+	appContracts      []anypoint.Contract
+	revokedContracts  []string
+	restoredContracts []string
+	revokeErr         error
+	// resetApps are the ids of the apps whose secret was reset
+	resetApps []string
+	// updatedTiers are the ids of the apis whose SLA tier was synced, with the tierSettings of the apis
+	updatedTiers []string
+	tierSettings *TierSettings
 }
 
 func (m *MockMuleSubscriptionClient) CreateApp(appName, apiID, description string) (*anypoint.Application, error) {
+	m.appDescription = description
 	return m.app, m.err
 }
 
-func (m *MockMuleSubscriptionClient) CreateContract(_, _, _ string) (*anypoint.Contract, error) {
+func (m *MockMuleSubscriptionClient) CreateContract(_, _, appID string) (*anypoint.Contract, error) {
+	m.createdContracts = append(m.createdContracts, appID)
+	if m.createContractErr != nil {
+		return nil, m.createContractErr
+	}
 	return m.contract, m.err
 }
 
-func (m *MockMuleSubscriptionClient) DeleteApp(appName string) error {
+func (m *MockMuleSubscriptionClient) DeleteApp(appID string) error {
+	m.deletedApps = append(m.deletedApps, appID)
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
 	return m.err
 }
 
 func (m *MockMuleSubscriptionClient) DeleteContract(apiID, contractID string) error {
+	m.deletedContracts = append(m.deletedContracts, contractID)
 	return m.err
 }
 
+func (m *MockMuleSubscriptionClient) RevokeContract(apiID, contractID string) error {
+	m.revokedContracts = append(m.revokedContracts, contractID)
+	return m.revokeErr
+}
+
+func (m *MockMuleSubscriptionClient) RestoreContract(apiID, contractID string) error {
+	m.restoredContracts = append(m.restoredContracts, contractID)
+	return m.revokeErr
+}
+
+func (m *MockMuleSubscriptionClient) ListAppContracts(appID string) ([]anypoint.Contract, error) {
+	return m.appContracts, m.listErr
+}
+
+func (m *MockMuleSubscriptionClient) GetContract(apiID, contractID string) (*anypoint.Contract, error) {
+	return m.contract, m.contractErr
+}
+
+func (m *MockMuleSubscriptionClient) ListContracts(apiID string) ([]anypoint.Contract, error) {
+	return m.contracts[apiID], m.listErr
+}
+
+func (m *MockMuleSubscriptionClient) ListApps() ([]anypoint.Application, error) {
+	return m.apps, m.listErr
+}
+
 func (m *MockMuleSubscriptionClient) GetApp(appID string) (*anypoint.Application, error) {
 	return m.app, m.err
 }
 
 func (m *MockMuleSubscriptionClient) ResetAppSecret(appID string) (*anypoint.Application, error) {
+	m.resetApps = append(m.resetApps, appID)
 	return m.newApp, m.rotateErr
 }
 
-func (m *MockMuleSubscriptionClient) CreateIfNotExistingSLATier(appID string) (string, error) {
-	return "", nil
+func (m *MockMuleSubscriptionClient) CreateOrUpdateSLATier(apiID string) (string, error) {
+	return "", m.tierErr
+}
+
+func (m *MockMuleSubscriptionClient) UpdateSLATier(apiID string) error {
+	m.updatedTiers = append(m.updatedTiers, apiID)
+	return m.tierErr
+}
+
+func (m *MockMuleSubscriptionClient) GetSLATierSettings(apiID string) (*TierSettings, error) {
+	if m.tierSettings == nil {
+		return DefaultTierSettings(), nil
+	}
+	return m.tierSettings, nil
 }
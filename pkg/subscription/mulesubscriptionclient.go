@@ -3,6 +3,8 @@ package subscription
 import (
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
 	"github.com/Axway/agents-mulesoft/pkg/common"
@@ -14,20 +16,79 @@ type MuleSubscriptionClient interface {
 	CreateContract(apiID, tierID, appID string) (*anypoint.Contract, error)
 	DeleteApp(appID string) error
 	DeleteContract(apiID, contractID string) error
+	RevokeContract(apiID, contractID string) error
+	RestoreContract(apiID, contractID string) error
+	GetContract(apiID, contractID string) (*anypoint.Contract, error)
+	ListContracts(apiID string) ([]anypoint.Contract, error)
+	ListApps() ([]anypoint.Application, error)
+	ListAppContracts(appID string) ([]anypoint.Contract, error)
 	GetApp(appID string) (*anypoint.Application, error)
 	ResetAppSecret(appID string) (*anypoint.Application, error)
-	CreateIfNotExistingSLATier(apiID string) (string, error)
+	CreateOrUpdateSLATier(apiID string) (string, error)
+	UpdateSLATier(apiID string) error
+	GetSLATierSettings(apiID string) (*TierSettings, error)
 }
 
+// apiCacheTTL is how long an API looked up by the client is reused, so that its tags are read again after a while
+const apiCacheTTL = 5 * time.Minute
+
 type muleSubscription struct {
 	client anypoint.Client
+	tier   *TierSettings
+	apis   *apiCache
+}
+
+// apiCache keeps the APIs looked up when creating contracts and SLA tiers
+type apiCache struct {
+	lock sync.Mutex
+	apis map[string]cachedAPI
+}
+
+type cachedAPI struct {
+	api     *anypoint.API
+	expires time.Time
+}
+
+// get returns the cached API, or looks it up when it is not cached or expired
+func (a *apiCache) get(client anypoint.Client, apiID string) (*anypoint.API, error) {
+	a.lock.Lock()
+	cached, ok := a.apis[apiID]
+	a.lock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.api, nil
+	}
+
+	api, err := client.GetAPI(apiID)
+	if err != nil {
+		return nil, err
+	}
+	a.lock.Lock()
+	a.apis[apiID] = cachedAPI{api: api, expires: time.Now().Add(apiCacheTTL)}
+	a.lock.Unlock()
+	return api, nil
+}
+
+// SubscriptionOption configures the MuleSubscriptionClient
+type SubscriptionOption func(*muleSubscription)
+
+// WithTierSettings sets the limits and the approval mode of the SLA tier created by the agent
+func WithTierSettings(tier *TierSettings) SubscriptionOption {
+	return func(c *muleSubscription) {
+		c.tier = tier
+	}
 }
 
 // NewMuleSubscriptionClient creates a MuleSubscriptionClient
-func NewMuleSubscriptionClient(client anypoint.Client) MuleSubscriptionClient {
-	return &muleSubscription{
+func NewMuleSubscriptionClient(client anypoint.Client, opts ...SubscriptionOption) MuleSubscriptionClient {
+	c := &muleSubscription{
 		client: client,
+		tier:   DefaultTierSettings(),
+		apis:   &apiCache{apis: map[string]cachedAPI{}},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // ResetAppSecret resets the secret for an app
@@ -58,7 +119,7 @@ func (c muleSubscription) CreateApp(appName string, apiID string, description st
 
 // CreateContract creates a contract between an API and an app
 func (c muleSubscription) CreateContract(apiID, tierID, appID string) (*anypoint.Contract, error) {
-	api, err := c.client.GetAPI(apiID)
+	api, err := c.apis.get(c.client, apiID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +134,26 @@ func (c muleSubscription) CreateContract(apiID, tierID, appID string) (*anypoint
 	return c.client.CreateContract(appID, contract)
 }
 
+// GetContract gets a contract between an API and an app
+func (c muleSubscription) GetContract(apiID, contractID string) (*anypoint.Contract, error) {
+	return c.client.GetContract(apiID, contractID)
+}
+
+// ListContracts lists the contracts of an API
+func (c muleSubscription) ListContracts(apiID string) ([]anypoint.Contract, error) {
+	return c.client.ListContracts(apiID)
+}
+
+// ListAppContracts lists the contracts of a mulesoft app
+func (c muleSubscription) ListAppContracts(appID string) ([]anypoint.Contract, error) {
+	return c.client.ListApplicationContracts(appID)
+}
+
+// ListApps lists the mulesoft apps of the organization
+func (c muleSubscription) ListApps() ([]anypoint.Application, error) {
+	return c.client.ListClientApplications()
+}
+
 // DeleteApp deletes the mulesoft app
 func (c muleSubscription) DeleteApp(appID string) error {
 	return c.client.DeleteClientApplication(appID)
@@ -87,22 +168,77 @@ func (c muleSubscription) DeleteContract(apiID, contractID string) error {
 	return c.client.DeleteContract(apiID, contractID)
 }
 
-func (c muleSubscription) CreateIfNotExistingSLATier(apiID string) (string, error) {
-	existingTiers, err := c.client.GetSLATiers(apiID, common.AxwayAgentSLATierName)
+// RevokeContract revokes the access of the app to the api, the contract can be restored
+func (c muleSubscription) RevokeContract(apiID, contractID string) error {
+	return c.client.RevokeContract(apiID, contractID)
+}
+
+// RestoreContract restores the access of the app to the api
+func (c muleSubscription) RestoreContract(apiID, contractID string) error {
+	return c.client.RestoreContract(apiID, contractID)
+}
+
+// CreateOrUpdateSLATier returns the ID of the agent SLA tier of an API. The tier is created when it does not exist,
+// and updated when its limits or approval mode differ from the settings of the API.
+func (c muleSubscription) CreateOrUpdateSLATier(apiID string) (string, error) {
+	tier, tierID, err := c.syncSLATier(apiID)
+	if err != nil || tierID != "" {
+		return tierID, err
+	}
+
+	id, err := c.client.CreateSLATier(apiID, tier)
 	if err != nil {
-		return "", fmt.Errorf("error getting SLA tiers: %s", err)
+		return "", err
 	}
-	for _, tier := range existingTiers.Tiers {
-		if tier.Name == common.AxwayAgentSLATierName {
-			return strconv.Itoa(*tier.ID), nil
-		}
+	return strconv.Itoa(id), nil
+}
+
+// UpdateSLATier updates the agent SLA tier of an API when its limits or approval mode differ from the settings of the
+// API. Nothing is done when the API has no agent tier.
+func (c muleSubscription) UpdateSLATier(apiID string) error {
+	_, _, err := c.syncSLATier(apiID)
+	return err
+}
+
+// GetSLATierSettings returns the settings of the agent SLA tier of an API, overridden by the tags of the API
+func (c muleSubscription) GetSLATierSettings(apiID string) (*TierSettings, error) {
+	api, err := c.apis.get(c.client, apiID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := c.tier.forAPI(api.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the SLA tier settings of the api: %s", err)
 	}
+	return settings, nil
+}
 
-	tierID, err := c.client.CreateSLATier(apiID)
+// syncSLATier updates the existing agent SLA tier of an API and returns its ID. The ID is empty when the API has no
+// agent tier, along with the tier to create.
+func (c muleSubscription) syncSLATier(apiID string) (*anypoint.SLATier, string, error) {
+	settings, err := c.GetSLATierSettings(apiID)
 	if err != nil {
-		return "", err
+		return nil, "", err
+	}
+	tier := settings.slaTier()
+
+	existingTiers, err := c.client.GetSLATiers(apiID, common.AxwayAgentSLATierName)
+	if err != nil {
+		return nil, "", fmt.Errorf("error getting SLA tiers: %s", err)
+	}
+	for _, existing := range existingTiers.Tiers {
+		if existing.Name != common.AxwayAgentSLATierName {
+			continue
+		}
+		if !settings.matches(existing) {
+			tier.ID = existing.ID
+			if err := c.client.UpdateSLATier(apiID, tier); err != nil {
+				return nil, "", fmt.Errorf("error updating SLA tier: %s", err)
+			}
+		}
+		return tier, strconv.Itoa(*existing.ID), nil
 	}
-	return strconv.Itoa(tierID), nil
+	return tier, "", nil
 }
 
 func newContract(apiID, versionGroup string, tierID string, api *anypoint.API) *anypoint.Contract {
@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestCreateApp(t *testing.T) {
@@ -198,3 +200,133 @@ func TestDeleteContract(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateOrUpdateSLATier(t *testing.T) {
+	agentTier := func(maxRequests float64, autoApprove bool) anypoint.SLATier {
+		return anypoint.SLATier{
+			ID:          anypoint.ToPointer[int](14214),
+			Name:        common.AxwayAgentSLATierName,
+			AutoApprove: autoApprove,
+			Limits: []anypoint.Limits{
+				{MaximumRequests: maxRequests, TimePeriodInMilliseconds: 1000, Visible: true},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		tags          []string
+		tiers         []anypoint.SLATier
+		getTiersErr   error
+		expectedID    string
+		expectCreate  bool
+		expectUpdate  bool
+		expectedLimit int
+		autoApprove   bool
+		hasErr        bool
+	}{
+		{
+			name:          "should create the tier when it does not exist",
+			tiers:         []anypoint.SLATier{},
+			expectedID:    "1",
+			expectCreate:  true,
+			expectedLimit: 10,
+			autoApprove:   true,
+		},
+		{
+			name:       "should return the existing tier when its settings did not change",
+			tiers:      []anypoint.SLATier{agentTier(10, true)},
+			expectedID: "14214",
+		},
+		{
+			name:          "should update the existing tier when the settings changed",
+			tiers:         []anypoint.SLATier{agentTier(10, true)},
+			tags:          []string{"slaTier.limits:500/1s", "slaTier.autoApprove:false"},
+			expectedID:    "14214",
+			expectUpdate:  true,
+			expectedLimit: 500,
+			autoApprove:   false,
+		},
+		{
+			name:   "should return an error for invalid slaTier tags",
+			tags:   []string{"slaTier.limits:500"},
+			hasErr: true,
+		},
+		{
+			name:        "should return an error when failing to get the tiers",
+			tiers:       []anypoint.SLATier{},
+			getTiersErr: fmt.Errorf("get tiers err"),
+			hasErr:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &anypoint.MockAnypointClient{}
+			client.On("GetAPI").Return(&anypoint.API{ID: 123, Tags: tc.tags}, nil)
+			client.On("GetSLATiers").Return(&anypoint.Tiers{Total: len(tc.tiers), Tiers: tc.tiers}, tc.getTiersErr)
+			client.On("CreateSLATier", mock.Anything).Return(1, nil)
+			client.On("UpdateSLATier", mock.Anything).Return(nil)
+			subClient := NewMuleSubscriptionClient(client)
+
+			tierID, err := subClient.CreateOrUpdateSLATier("123")
+			if tc.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedID, tierID)
+
+			if !tc.expectCreate {
+				client.AssertNotCalled(t, "CreateSLATier", mock.Anything)
+			}
+			if !tc.expectUpdate {
+				client.AssertNotCalled(t, "UpdateSLATier", mock.Anything)
+			}
+			for _, call := range client.Calls {
+				if call.Method != "CreateSLATier" && call.Method != "UpdateSLATier" {
+					continue
+				}
+				tier := call.Arguments.Get(0).(*anypoint.SLATier)
+				assert.Equal(t, common.AxwayAgentSLATierName, tier.Name)
+				assert.Equal(t, tc.autoApprove, tier.AutoApprove)
+				assert.Equal(t, tc.expectedLimit, tier.Limits[0].MaximumRequests)
+				if tc.expectUpdate {
+					assert.Equal(t, 14214, *tier.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateSLATier(t *testing.T) {
+	existing := anypoint.SLATier{
+		ID:          anypoint.ToPointer[int](14214),
+		Name:        common.AxwayAgentSLATierName,
+		AutoApprove: true,
+		Limits:      []anypoint.Limits{{MaximumRequests: float64(5), TimePeriodInMilliseconds: 1000, Visible: true}},
+	}
+	client := &anypoint.MockAnypointClient{}
+	client.On("GetAPI").Return(&anypoint.API{ID: 123}, nil)
+	client.On("GetSLATiers").Return(&anypoint.Tiers{Total: 1, Tiers: []anypoint.SLATier{existing}}, nil)
+	client.On("UpdateSLATier", mock.Anything).Return(nil)
+	subClient := NewMuleSubscriptionClient(client)
+
+	assert.Nil(t, subClient.UpdateSLATier("123"))
+	client.AssertNumberOfCalls(t, "UpdateSLATier", 1)
+	client.AssertNotCalled(t, "CreateSLATier", mock.Anything)
+
+	// the api is looked up once
+	_, err := subClient.CreateOrUpdateSLATier("123")
+	assert.Nil(t, err)
+	client.AssertNumberOfCalls(t, "GetAPI", 1)
+
+	// an api without an agent tier is left as is
+	client = &anypoint.MockAnypointClient{}
+	client.On("GetAPI").Return(&anypoint.API{ID: 123}, nil)
+	client.On("GetSLATiers").Return(&anypoint.Tiers{}, nil)
+	subClient = NewMuleSubscriptionClient(client)
+	assert.Nil(t, subClient.UpdateSLATier("123"))
+	client.AssertNotCalled(t, "CreateSLATier", mock.Anything)
+	client.AssertNotCalled(t, "UpdateSLATier", mock.Anything)
+}
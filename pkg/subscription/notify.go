@@ -0,0 +1,77 @@
+package subscription
+
+import (
+	"encoding/json"
+	"fmt"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/sirupsen/logrus"
+)
+
+// ContractNotification is sent when a contract that was waiting for approval is approved, rejected or times out.
+type ContractNotification struct {
+	AccessRequest string `json:"accessRequest"`
+	Application   string `json:"application"`
+	APIID         string `json:"apiId"`
+	ContractID    string `json:"contractId"`
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+}
+
+// Notifier sends contract notifications
+type Notifier interface {
+	Notify(notification ContractNotification)
+}
+
+// NewNotifier returns a Notifier that posts the notifications to the webhook URL, or a Notifier that drops them when
+// the URL is empty.
+func NewNotifier(client coreapi.Client, webhookURL string, log logrus.FieldLogger) Notifier {
+	if webhookURL == "" {
+		return noopNotifier{}
+	}
+	return &webhookNotifier{
+		client: client,
+		url:    webhookURL,
+		log:    log.WithField("component", "contract-notifier"),
+	}
+}
+
+type noopNotifier struct{}
+
+// Notify drops the notification
+func (noopNotifier) Notify(ContractNotification) {}
+
+type webhookNotifier struct {
+	client coreapi.Client
+	url    string
+	log    logrus.FieldLogger
+}
+
+// Notify posts the notification as JSON to the webhook. Failures are logged, the notification is not retried.
+func (n *webhookNotifier) Notify(notification ContractNotification) {
+	err := n.post(notification)
+	if err != nil {
+		n.log.WithError(err).WithField("contractID", notification.ContractID).Error("failed to send the contract notification")
+	}
+}
+
+func (n *webhookNotifier) post(notification ContractNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	res, err := n.client.Send(coreapi.Request{
+		Method:  coreapi.POST,
+		URL:     n.url,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	if res.Code < 200 || res.Code >= 300 {
+		return fmt.Errorf("the webhook responded with status %d", res.Code)
+	}
+	return nil
+}
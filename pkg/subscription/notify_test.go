@@ -0,0 +1,100 @@
+package subscription
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockWebhookClient struct {
+	requests []coreapi.Request
+	code     int
+	err      error
+}
+
+func (m *mockWebhookClient) Send(request coreapi.Request) (*coreapi.Response, error) {
+	m.requests = append(m.requests, request)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &coreapi.Response{Code: m.code}, nil
+}
+
+func TestNotifier(t *testing.T) {
+	notification := ContractNotification{
+		AccessRequest: "ar1",
+		Application:   "app1",
+		APIID:         "111",
+		ContractID:    "98765",
+		Status:        "APPROVED",
+		Message:       "contract 98765 was approved in Mulesoft",
+	}
+
+	tests := []struct {
+		name       string
+		webhookURL string
+		code       int
+		err        error
+		sent       bool
+		hasErr     bool
+	}{
+		{
+			name: "should not send the notification without a webhook",
+		},
+		{
+			name:       "should post the notification to the webhook",
+			webhookURL: "https://hooks.example.com/contracts",
+			code:       204,
+			sent:       true,
+		},
+		{
+			name:       "should fail when the webhook responds with an error",
+			webhookURL: "https://hooks.example.com/contracts",
+			code:       500,
+			sent:       true,
+			hasErr:     true,
+		},
+		{
+			name:       "should fail when the webhook cannot be reached",
+			webhookURL: "https://hooks.example.com/contracts",
+			err:        fmt.Errorf("connection refused"),
+			sent:       true,
+			hasErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockWebhookClient{code: tc.code, err: tc.err}
+			notifier := NewNotifier(client, tc.webhookURL, logrus.StandardLogger())
+			notifier.Notify(notification)
+
+			if !tc.sent {
+				assert.IsType(t, noopNotifier{}, notifier)
+				assert.Empty(t, client.requests)
+				return
+			}
+
+			assert.Len(t, client.requests, 1)
+			req := client.requests[0]
+			assert.Equal(t, coreapi.POST, req.Method)
+			assert.Equal(t, tc.webhookURL, req.URL)
+			assert.Equal(t, "application/json", req.Headers["Content-Type"])
+
+			sent := ContractNotification{}
+			assert.Nil(t, json.Unmarshal(req.Body, &sent))
+			assert.Equal(t, notification, sent)
+
+			err := notifier.(*webhookNotifier).post(notification)
+			if tc.hasErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -3,31 +3,71 @@ package subscription
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/Axway/agent-sdk/pkg/agent"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
 	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
 	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
 	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
 	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
 	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
 	"github.com/Axway/agents-mulesoft/pkg/common"
 	"github.com/sirupsen/logrus"
 )
 
+// provisionCentralClient is the part of the Central client that saves the Mule app of a managed application
+type provisionCentralClient interface {
+	GetResource(url string) (*v1.ResourceInstance, error)
+	CreateSubResource(rm v1.ResourceMeta, subs map[string]interface{}) error
+	GetAPIV1ResourceInstances(query map[string]string, url string) ([]*v1.ResourceInstance, error)
+}
+
+// teamCache is the part of the SDK cache manager with the platform teams
+type teamCache interface {
+	GetTeamByID(id string) *defs.PlatformTeam
+}
+
 type provisioner struct {
-	client MuleSubscriptionClient
-	log    logrus.FieldLogger
+	client  MuleSubscriptionClient
+	central provisionCentralClient
+	teams   teamCache
+	envName string
+	log     logrus.FieldLogger
+}
+
+// ProvisionerOption configures the provisioner
+type ProvisionerOption func(*provisioner)
+
+// WithCentralClient sets the Central client and the environment of the managed applications the provisioner updates
+func WithCentralClient(central provisionCentralClient, envName string) ProvisionerOption {
+	return func(p *provisioner) {
+		p.central = central
+		p.envName = envName
+	}
+}
+
+// WithTeamCache sets the cache the owning team of the managed applications is read from
+func WithTeamCache(teams teamCache) ProvisionerOption {
+	return func(p *provisioner) {
+		p.teams = teams
+	}
 }
 
 // NewProvisioner creates a type to implement the SDK Provisioning methods for handling subscriptions
-func NewProvisioner(client MuleSubscriptionClient, log logrus.FieldLogger) prov.Provisioning {
-	return &provisioner{
+func NewProvisioner(client MuleSubscriptionClient, log logrus.FieldLogger, opts ...ProvisionerOption) prov.Provisioning {
+	p := &provisioner{
 		client: client,
 		log:    log.WithField("component", "mp-provisioner"),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
-// AccessRequestDeprovision deletes a contract
+// AccessRequestDeprovision deletes a contract created by the agent
 func (p provisioner) AccessRequestDeprovision(req prov.AccessRequest) prov.RequestStatus {
 	p.log.Info("deprovisioning access request")
 	rs := prov.NewRequestStatusBuilder()
@@ -44,6 +84,12 @@ func (p provisioner) AccessRequestDeprovision(req prov.AccessRequest) prov.Reque
 	}
 
 	logger := p.log.WithField("api", apiID).WithField("app", req.GetApplicationName()).WithField("contractID", contractID)
+	if req.GetAccessRequestDetailsValue(common.Imported) == "true" {
+		// like the imported apps, contracts imported from Mulesoft were not created by the agent, they are kept
+		msg := "removed imported access, the Mulesoft contract is kept"
+		logger.Info(msg)
+		return rs.SetMessage(msg).Success()
+	}
 	// logging only because contract may already be deleted if the managed app was deleted first.
 	if err := p.client.DeleteContract(apiID, contractID); err != nil {
 		logger.WithError(err).Error("failed to delete contract")
@@ -69,61 +115,127 @@ func (p provisioner) AccessRequestProvision(req prov.AccessRequest) (prov.Reques
 	if stage == "" {
 		return p.failed(rs, notFound(defs.AttrExternalAPIStage)), nil
 	}
+	// access requests imported from Mulesoft, or reconciled, have a contract already
+	if contractID := req.GetAccessRequestDetailsValue(common.ContractID); contractID != "" {
+		return p.existingContract(req, apiID, contractID), nil
+	}
+	logger := p.log.
+		WithField("api", apiID).
+		WithField("app", req.GetApplicationName())
+
+	// the Mulesoft resources created by the request are removed again when a later step fails
+	tx := newTransaction(logger)
+
 	appID := req.GetApplicationDetailsValue(common.AppID)
-	if appID == "" {
-		// Create the application
+	savesApp := appID == ""
+	if savesApp {
+		// managed applications provisioned before the agent created their Mule app have no app yet
 		appName := req.GetApplicationName()
 		if appName == "" {
 			return p.failed(rs, notFound("managed application name")), nil
 		}
 
-		app, err := p.client.CreateApp(appName, apiID, "Created by Amplify Mulesoft Agent")
+		app, created, err := p.getOrCreateApp(appName, apiID, "")
 		if err != nil {
 			return p.failed(rs, fmt.Errorf("failed to create app: %s", err)), nil
 		}
-		appID = fmt.Sprintf("%d", app.ID)
-
-		// Update resource
-		managedApp := management.NewManagedApplication(appName, agent.GetCentralConfig().GetEnvironmentName())
-		ri, err := agent.GetCentralClient().GetResource(managedApp.GetSelfLink())
-		if err == nil {
-			util.SetAgentDetailsKey(ri, common.AppID, appID)
-			details := util.GetAgentDetails(ri)
-			util.SetAgentDetails(ri, details)
-
-			agent.GetCentralClient().CreateSubResource(ri.ResourceMeta, map[string]interface{}{defs.XAgentDetails: details})
+		appID = strconv.Itoa(app.ID)
+		if created {
+			tx.record(fmt.Sprintf("delete app %s", appID), func() error {
+				return p.client.DeleteApp(appID)
+			})
 		}
 	}
 
 	tierID := util.ToString(reqData[common.SlaTier])
 	if tierID == "" {
-		tierID, err = p.client.CreateIfNotExistingSLATier(apiID)
+		// the agent SLA tier is shared by the access requests of the API, it is not removed on a rollback
+		tierID, err = p.client.CreateOrUpdateSLATier(apiID)
 		if err != nil {
-			return p.failed(rs, fmt.Errorf("failed to create SLA tier: %s", err)), nil
+			return p.rollback(rs, tx, fmt.Errorf("failed to create SLA tier: %s", err)), nil
 		}
 	}
 
 	contract, err := p.client.CreateContract(apiID, tierID, appID)
 	if err != nil {
-		return p.failed(rs, fmt.Errorf("failed to create contract: %s", err)), nil
+		return p.rollback(rs, tx, fmt.Errorf("failed to create contract: %s", err)), nil
+	}
+	contractID := strconv.Itoa(contract.ID)
+	tx.record(fmt.Sprintf("delete contract %s", contractID), func() error {
+		return p.client.DeleteContract(apiID, contractID)
+	})
+
+	// the app is saved last, an app that is not saved to the managed application would never be removed
+	if savesApp {
+		if err := p.setAppDetails(req.GetApplicationName(), map[string]string{common.AppID: appID}); err != nil {
+			return p.rollback(rs, tx, fmt.Errorf("failed to save the app id to the managed application: %s", err)), nil
+		}
+	}
+
+	if contract.Status == common.ContractPending {
+		// the access request stays pending until the approval job moves it to Success once the contract is
+		// approved, or fails it when the contract is rejected or not approved in time
+		logger.WithField("contractID", contract.ID).Info("access is waiting for approval")
+		return p.pending(contractID, map[string]string{
+			common.ContractID:        contractID,
+			common.SlaTier:           tierID,
+			common.ContractStatus:    contract.Status,
+			common.ContractRequested: time.Now().UTC().Format(time.RFC3339),
+			common.APIID:             apiID,
+		}), nil
 	}
 
-	rs.AddProperty(common.ContractID, strconv.Itoa(contract.ID)).
+	rs.AddProperty(common.ContractID, contractID).
 		AddProperty(common.SlaTier, tierID)
 
-	p.log.
-		WithField("api", apiID).
-		WithField("app", req.GetApplicationName()).
-		Info("granted access")
+	logger.Info("granted access")
 	return rs.Success(), nil
 }
 
-// ApplicationRequestDeprovision deletes an app
+// existingContract returns the status of an access request that has a contract already. Access is only granted while
+// the contract exists and is approved in Mulesoft.
+func (p provisioner) existingContract(req prov.AccessRequest, apiID, contractID string) prov.RequestStatus {
+	rs := prov.NewRequestStatusBuilder()
+	logger := p.log.WithField("api", apiID).WithField("contractID", contractID)
+
+	contract, err := p.client.GetContract(apiID, contractID)
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to read the contract %s: %s", contractID, err))
+	}
+
+	switch contract.Status {
+	case common.ContractApproved:
+		logger.Info("access is granted by an existing contract")
+		return rs.AddProperty(common.ContractStatus, contract.Status).Success()
+	case common.ContractPending:
+		properties := map[string]string{
+			common.ContractStatus: contract.Status,
+			common.APIID:          apiID,
+		}
+		if req.GetAccessRequestDetailsValue(common.ContractRequested) == "" {
+			properties[common.ContractRequested] = time.Now().UTC().Format(time.RFC3339)
+		}
+		logger.Info("access is waiting for approval")
+		return p.pending(contractID, properties)
+	default:
+		return p.failed(rs, fmt.Errorf("contract %s is %s in Mulesoft", contractID, contract.Status))
+	}
+}
+
+// ApplicationRequestDeprovision deletes an app created by the agent
 func (p provisioner) ApplicationRequestDeprovision(req prov.ApplicationRequest) prov.RequestStatus {
 	p.log.Info("deprovisioning application")
 	rs := prov.NewRequestStatusBuilder()
 
 	appID := req.GetApplicationDetailsValue(common.AppID)
+	if appID != "" && req.GetApplicationDetailsValue(common.Imported) == "true" {
+		// apps imported from Mulesoft were not created by the agent, they are kept
+		p.log.
+			WithField("appName", req.GetManagedApplicationName()).
+			WithField("appID", appID).
+			Info("removed imported application, the Mulesoft application is kept")
+		return rs.Success()
+	}
 	// Application not provisioned yet by the access request handler
 	if appID != "" {
 		err := p.client.DeleteApp(appID)
@@ -138,7 +250,8 @@ func (p provisioner) ApplicationRequestDeprovision(req prov.ApplicationRequest)
 	return rs.Success()
 }
 
-// ApplicationRequestProvision creates an app
+// ApplicationRequestProvision creates a Mule app for the managed application, the contracts of its access requests
+// are added to the app
 func (p provisioner) ApplicationRequestProvision(req prov.ApplicationRequest) prov.RequestStatus {
 	p.log.Info("provisioning application")
 	rs := prov.NewRequestStatusBuilder()
@@ -147,21 +260,135 @@ func (p provisioner) ApplicationRequestProvision(req prov.ApplicationRequest) pr
 	if appName == "" {
 		return p.failed(rs, notFound("managed application name"))
 	}
+	logger := p.log.WithField("appName", appName)
 
-	p.log.
-		WithField("appName", req.GetManagedApplicationName()).
-		Info("created application")
+	// the SDK provisions an application again when its status is updated
+	if appID := req.GetApplicationDetailsValue(common.AppID); appID != "" {
+		logger.WithField("appID", appID).Info("application is provisioned already")
+		return rs.AddProperty(common.AppID, appID).Success()
+	}
 
-	return rs.Success()
+	app, created, err := p.getOrCreateApp(appName, "", req.GetTeamName())
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to create app: %s", err))
+	}
+
+	if created {
+		logger.WithField("appID", app.ID).Info("created application")
+	} else {
+		logger.WithField("appID", app.ID).Info("using the application created by the agent before")
+	}
+	return rs.AddProperty(common.AppID, strconv.Itoa(app.ID)).Success()
 }
 
-// CredentialDeprovision returns success since credentials are removed with the app
-func (p provisioner) CredentialDeprovision(_ prov.CredentialRequest) prov.RequestStatus {
-	msg := "credentials will be removed when the application is deleted"
-	p.log.Info(msg)
-	return prov.NewRequestStatusBuilder().
-		SetMessage(msg).
-		Success()
+// getOrCreateApp returns the Mule app the agent created for a managed application, or creates it. An app is looked up
+// by name first, so that a provisioning that failed after the app was created does not leave a second app. Only the
+// apps with the agent description are used, an app of the same name created outside of the agent is not taken over.
+func (p provisioner) getOrCreateApp(appName, apiID, teamName string) (*anypoint.Application, bool, error) {
+	apps, err := p.client.ListApps()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list the apps: %s", err)
+	}
+	for _, app := range apps {
+		if app.Name == appName && strings.HasPrefix(app.Description, common.AxwayAgentAppDescription) {
+			return &app, false, nil
+		}
+	}
+
+	app, err := p.client.CreateApp(appName, apiID, p.appDescription(appName, teamName))
+	if err != nil {
+		return nil, false, err
+	}
+	return app, true, nil
+}
+
+// appDescription returns the description of the Mule app of a managed application, with the title and the owning
+// team read from the managed application. The team is read from the cache when it is not known.
+func (p provisioner) appDescription(appName, teamName string) string {
+	if p.central == nil {
+		return newAppDescription("", teamName)
+	}
+	managedApp := management.NewManagedApplication(appName, p.envName)
+	ri, err := p.central.GetResource(managedApp.GetSelfLink())
+	if err != nil || ri == nil {
+		p.log.WithField("appName", appName).WithError(err).Debug("failed to read the managed application")
+		return newAppDescription("", teamName)
+	}
+	if teamName == "" && p.teams != nil && ri.Owner != nil && ri.Owner.ID != "" {
+		if team := p.teams.GetTeamByID(ri.Owner.ID); team != nil {
+			teamName = team.Name
+		}
+	}
+	return newAppDescription(ri.Title, teamName)
+}
+
+// newAppDescription returns the description of a Mule app created by the agent, with the title and the owning team of
+// the managed application. The description starts with the agent description so that the reconciliation finds the
+// apps created by the agent.
+func newAppDescription(title, teamName string) string {
+	description := common.AxwayAgentAppDescription
+	if teamName != "" {
+		description = fmt.Sprintf("%s for team %s", description, teamName)
+	}
+	if title != "" {
+		description = fmt.Sprintf("%s: %s", description, title)
+	}
+	return description
+}
+
+// CredentialDeprovision resets the secret of the app so that the removed credential cannot be used anymore. The
+// secret is shared by the credentials of the managed application, it is kept while another credential is active. The
+// contracts revoked when the credentials were suspended are restored, the new secret is required to use them.
+func (p provisioner) CredentialDeprovision(req prov.CredentialRequest) prov.RequestStatus {
+	p.log.Info("deprovisioning credentials")
+	rs := prov.NewRequestStatusBuilder()
+
+	appID := req.GetApplicationDetailsValue(common.AppID)
+	logger := p.log.WithField("appName", req.GetApplicationName()).WithField("appID", appID)
+	if appID == "" {
+		logger.Info("removed credentials of an application without a Mulesoft app")
+		return rs.Success()
+	}
+	if req.GetApplicationDetailsValue(common.Imported) == "true" {
+		// the secret of apps imported from Mulesoft may be used outside of Central
+		msg := "removed credentials, the secret of the imported application is kept"
+		logger.Info(msg)
+		return rs.SetMessage(msg).Success()
+	}
+	// logging only because the app may already be deleted in Mulesoft
+	if _, err := p.client.GetApp(appID); err != nil {
+		logger.WithError(err).Error("failed to retrieve app, the credentials are removed")
+		return rs.Success()
+	}
+
+	shared, err := p.hasOtherActiveCredentials(req)
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to read the credentials of the application: %s", err))
+	}
+	if shared {
+		msg := "removed credentials, the secret is kept for the other active credentials of the application"
+		logger.Info(msg)
+		return rs.SetMessage(msg).Success()
+	}
+
+	if _, err := p.client.ResetAppSecret(appID); err != nil {
+		return p.failed(rs, fmt.Errorf("failed to reset application secret: %s", err))
+	}
+
+	if req.GetApplicationDetailsValue(common.RevokedContracts) != "" {
+		tx := newTransaction(logger)
+		if err := p.restoreContracts(tx, req); err != nil {
+			logger.WithError(err).Error("failed to restore the contracts of the suspended credentials")
+		} else if err := p.setAppDetails(req.GetApplicationName(), map[string]string{
+			common.CredentialState:  common.CredentialActive,
+			common.RevokedContracts: "",
+		}); err != nil {
+			logger.WithError(err).Error("failed to update the managed application")
+		}
+	}
+
+	logger.Info("removed credentials")
+	return rs.Success()
 }
 
 // CredentialProvision retrieves the credentials from an app
@@ -176,7 +403,12 @@ func (p provisioner) CredentialProvision(req prov.CredentialRequest) (prov.Reque
 
 	appID := req.GetApplicationDetailsValue(common.AppID)
 	if appID == "" {
-		return p.failed(rs, notFound(appID)), nil
+		return p.failed(rs, notFound(common.AppID)), nil
+	}
+
+	credType := req.GetCredentialType()
+	if !isSupportedCredentialType(credType) {
+		return p.failed(rs, fmt.Errorf("invalid credential type provided: %s", credType)), nil
 	}
 
 	app, err := p.client.GetApp(appID)
@@ -184,32 +416,42 @@ func (p provisioner) CredentialProvision(req prov.CredentialRequest) (prov.Reque
 		return p.failed(rs, fmt.Errorf("failed to retrieve app: %s", err)), nil
 	}
 
-	var cr prov.Credential
-	credType := req.GetCredentialType()
-	switch credType {
-	case provisioning.BasicAuthCRD:
-		cr = prov.NewCredentialBuilder().SetHTTPBasic(app.ClientID, app.ClientSecret)
-	case provisioning.OAuthSecretCRD:
-		cr = prov.NewCredentialBuilder().SetOAuthIDAndSecret(app.ClientID, app.ClientSecret)
-	default:
-		return p.failed(rs, fmt.Errorf("invalid credential type provided: %s", credType)), nil
-	}
+	cr := newCredential(credType, app.ClientID, app.ClientSecret)
 
-	p.log.Info("created credentials")
+	p.log.WithField("credentialType", credType).Info("created credentials")
 
 	return rs.Success(), cr
 }
 
+// CredentialUpdate rotates the secret of the app, or suspends and enables the credentials of the app by revoking and
+// restoring its contracts. A Mule app has a single client id and secret, so the action applies to all the credentials of
+// the managed application.
 func (p provisioner) CredentialUpdate(req prov.CredentialRequest) (prov.RequestStatus, prov.Credential) {
-	p.log.Info("updating credential for app %s", req.GetApplicationName())
+	p.log.Infof("updating credential for app %s", req.GetApplicationName())
 	rs := prov.NewRequestStatusBuilder()
+
+	if credType := req.GetCredentialType(); credType != "" && !isSupportedCredentialType(credType) {
+		return p.failed(rs, fmt.Errorf("invalid credential type provided: %s", credType)), nil
+	}
 	appID := req.GetApplicationDetailsValue(common.AppID)
+	if appID == "" {
+		return p.failed(rs, notFound(common.AppID)), nil
+	}
 
-	// return right away if the action is rotate
-	if req.GetCredentialAction() != prov.Rotate {
-		return p.failed(rs, fmt.Errorf("%s is not available for mulesoft credentials", req.GetCredentialAction())), nil
+	switch req.GetCredentialAction() {
+	case prov.Rotate:
+		return p.rotateCredential(rs, req, appID)
+	case prov.Suspend:
+		return p.suspendCredential(rs, req, appID)
+	case prov.Enable:
+		return p.enableCredential(rs, req, appID)
 	}
+	return p.failed(rs, fmt.Errorf("%s is not available for mulesoft credentials", req.GetCredentialAction())), nil
+}
 
+func (p provisioner) rotateCredential(
+	rs prov.RequestStatusBuilder, req prov.CredentialRequest, appID string,
+) (prov.RequestStatus, prov.Credential) {
 	app, err := p.client.GetApp(appID)
 	if err != nil {
 		return p.failed(rs, fmt.Errorf("failed to rotate application secret: %s", err)), nil
@@ -220,13 +462,209 @@ func (p provisioner) CredentialUpdate(req prov.CredentialRequest) (prov.RequestS
 		return p.failed(rs, fmt.Errorf("failed to rotate application secret: %s", err)), nil
 	}
 
-	cr := prov.NewCredentialBuilder().SetOAuthIDAndSecret(app.ClientID, secret.ClientSecret)
+	cr := newCredential(credentialType(req), app.ClientID, secret.ClientSecret)
 
 	p.log.Infof("updated credentials for app %s", req.GetApplicationName())
 
 	return rs.Success(), cr
 }
 
+// suspendCredential revokes the approved and pending contracts of the app, and keeps their ids in the managed
+// application details to restore them when a credential is enabled. The managed application is marked as suspended so
+// that the reconciliation keeps its access requests. The contracts are shared by the credentials of the managed
+// application, they are kept while another credential is active.
+func (p provisioner) suspendCredential(
+	rs prov.RequestStatusBuilder, req prov.CredentialRequest, appID string,
+) (prov.RequestStatus, prov.Credential) {
+	logger := p.log.WithField("appName", req.GetApplicationName()).WithField("appID", appID)
+
+	shared, err := p.hasOtherActiveCredentials(req)
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to read the credentials of the application: %s", err)), nil
+	}
+	if shared {
+		msg := "suspended credentials, the contracts are kept for the other active credentials of the application"
+		logger.Info(msg)
+		return rs.SetMessage(msg).AddProperty(common.CredentialState, common.CredentialSuspended).Success(), nil
+	}
+
+	contracts, err := p.client.ListAppContracts(appID)
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to list the contracts of the app: %s", err)), nil
+	}
+
+	tx := newTransaction(logger)
+	revoked := []string{}
+	for _, contract := range contracts {
+		if contract.Status != common.ContractApproved && contract.Status != common.ContractPending {
+			continue
+		}
+		apiID, contractID := contract.ApiID, strconv.Itoa(contract.ID)
+		if err := p.client.RevokeContract(apiID, contractID); err != nil {
+			return p.rollback(rs, tx, fmt.Errorf("failed to revoke contract %s: %s", contractID, err)), nil
+		}
+		tx.record(fmt.Sprintf("restore contract %s", contractID), func() error {
+			return p.client.RestoreContract(apiID, contractID)
+		})
+		revoked = append(revoked, apiID+"/"+contractID)
+	}
+
+	err = p.setAppDetails(req.GetApplicationName(), map[string]string{
+		common.CredentialState:  common.CredentialSuspended,
+		common.RevokedContracts: strings.Join(revoked, ","),
+	})
+	if err != nil {
+		return p.rollback(rs, tx, fmt.Errorf("failed to suspend the managed application: %s", err)), nil
+	}
+
+	logger.WithField("contracts", len(revoked)).Info("suspended credentials")
+	return rs.AddProperty(common.CredentialState, common.CredentialSuspended).Success(), nil
+}
+
+// hasOtherActiveCredentials returns true when another credential of the managed application of a request is active.
+// The credentials of a managed application share the client id and secret of its Mule app.
+func (p provisioner) hasOtherActiveCredentials(req prov.CredentialRequest) (bool, error) {
+	if p.central == nil {
+		return false, nil
+	}
+	appName := req.GetApplicationName()
+	credentials, err := p.central.GetAPIV1ResourceInstances(
+		map[string]string{"query": fmt.Sprintf("spec.managedApplication==%s", appName)},
+		management.NewCredential("", p.envName).GetKindLink(),
+	)
+	if err != nil {
+		return false, err
+	}
+	for _, ri := range credentials {
+		if ri.Name == req.GetName() || (req.GetID() != "" && ri.Metadata.ID == req.GetID()) {
+			continue
+		}
+		credential := &management.Credential{}
+		if err := credential.FromInstance(ri); err != nil {
+			continue
+		}
+		if credential.Spec.ManagedApplication != appName || credential.Metadata.State == v1.ResourceDeleting {
+			continue
+		}
+		if credential.Spec.State.Name == v1.Active && credential.Status != nil &&
+			credential.Status.Level == prov.Success.String() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// enableCredential restores the contracts revoked when the credential was suspended, and returns the credentials of
+// the app again
+func (p provisioner) enableCredential(
+	rs prov.RequestStatusBuilder, req prov.CredentialRequest, appID string,
+) (prov.RequestStatus, prov.Credential) {
+	logger := p.log.WithField("appName", req.GetApplicationName()).WithField("appID", appID)
+
+	app, err := p.client.GetApp(appID)
+	if err != nil {
+		return p.failed(rs, fmt.Errorf("failed to retrieve app: %s", err)), nil
+	}
+
+	tx := newTransaction(logger)
+	if err := p.restoreContracts(tx, req); err != nil {
+		return p.rollback(rs, tx, err), nil
+	}
+	err = p.setAppDetails(req.GetApplicationName(), map[string]string{
+		common.CredentialState:  common.CredentialActive,
+		common.RevokedContracts: "",
+	})
+	if err != nil {
+		return p.rollback(rs, tx, fmt.Errorf("failed to enable the managed application: %s", err)), nil
+	}
+
+	logger.Info("enabled credentials")
+	cr := newCredential(credentialType(req), app.ClientID, app.ClientSecret)
+	return rs.AddProperty(common.CredentialState, common.CredentialActive).Success(), cr
+}
+
+// restoreContracts restores the contracts revoked when the credentials of the managed application were suspended, and
+// records their revocation in the transaction
+func (p provisioner) restoreContracts(tx *transaction, req prov.CredentialRequest) error {
+	for _, revoked := range strings.Split(req.GetApplicationDetailsValue(common.RevokedContracts), ",") {
+		apiID, contractID, ok := strings.Cut(revoked, "/")
+		if !ok {
+			continue
+		}
+		if err := p.client.RestoreContract(apiID, contractID); err != nil {
+			return fmt.Errorf("failed to restore contract %s: %s", contractID, err)
+		}
+		tx.record(fmt.Sprintf("revoke contract %s", contractID), func() error {
+			return p.client.RevokeContract(apiID, contractID)
+		})
+	}
+	return nil
+}
+
+// credentialType returns the type of the credential of a request
+func credentialType(req prov.CredentialRequest) string {
+	if credType := req.GetCredentialType(); credType != "" {
+		return credType
+	}
+	// credentials provisioned before the API offered several credential types
+	return provisioning.OAuthSecretCRD
+}
+
+// isSupportedCredentialType returns true for the credential request definitions published for the auth policies
+func isSupportedCredentialType(credType string) bool {
+	return credType == provisioning.BasicAuthCRD ||
+		credType == provisioning.OAuthSecretCRD ||
+		credType == provisioning.APIKeyCRD
+}
+
+// newCredential returns the client id and secret of a Mule app in the format of the credential type. A Mule app has a
+// single client id and secret, which are used as the basic auth username and password, as the OAuth client or as the
+// api key and secret of the client-id-enforcement policy.
+func newCredential(credType, clientID, clientSecret string) prov.Credential {
+	switch credType {
+	case provisioning.BasicAuthCRD:
+		return prov.NewCredentialBuilder().SetHTTPBasic(clientID, clientSecret)
+	case provisioning.APIKeyCRD:
+		return prov.NewCredentialBuilder().SetCredential(map[string]interface{}{
+			provisioning.APIKey:     clientID,
+			common.ClientSecretName: clientSecret,
+		})
+	}
+	return prov.NewCredentialBuilder().SetOAuthIDAndSecret(clientID, clientSecret)
+}
+
+// setAppDetails writes values to the agent details of a managed application, such as the id of the Mule app created
+// for it
+func (p provisioner) setAppDetails(appName string, details map[string]string) error {
+	if p.central == nil {
+		return fmt.Errorf("central client not configured")
+	}
+	managedApp := management.NewManagedApplication(appName, p.envName)
+	ri, err := p.central.GetResource(managedApp.GetSelfLink())
+	if err != nil {
+		return err
+	}
+	for key, value := range details {
+		util.SetAgentDetailsKey(ri, key, value)
+	}
+	return p.central.CreateSubResource(ri.ResourceMeta, map[string]interface{}{defs.XAgentDetails: util.GetAgentDetails(ri)})
+}
+
+// rollback removes the Mulesoft resources created by a request that failed, and fails the request
+func (p provisioner) rollback(rs prov.RequestStatusBuilder, tx *transaction, err error) prov.RequestStatus {
+	if rbErr := tx.rollback(); rbErr != nil {
+		err = fmt.Errorf("%s, %s", err, rbErr)
+	}
+	return p.failed(rs, err)
+}
+
+// pending returns the Pending status of an access request waiting for the approval of its contract
+func (p provisioner) pending(contractID string, properties map[string]string) prov.RequestStatus {
+	return newPendingStatus(
+		fmt.Sprintf("contract %s is waiting for approval of the SLA tier in Mulesoft", contractID), properties, nil,
+	)
+}
+
 func (p provisioner) failed(rs prov.RequestStatusBuilder, err error) prov.RequestStatus {
 	rs.SetMessage(err.Error())
 	p.log.Error(err)
@@ -2,8 +2,11 @@ package subscription
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
 	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
 	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
 	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
@@ -19,6 +22,7 @@ func TestAccessRequestDeprovision(t *testing.T) {
 		name       string
 		apiID      string
 		contractID string
+		imported   bool
 		status     prov.Status
 	}{
 		{
@@ -37,6 +41,13 @@ func TestAccessRequestDeprovision(t *testing.T) {
 			apiID:  "1234",
 			status: prov.Error,
 		},
+		{
+			name:       "should keep an imported contract",
+			apiID:      "1234",
+			contractID: "5432",
+			imported:   true,
+			status:     prov.Success,
+		},
 	}
 
 	for _, tc := range tests {
@@ -47,6 +58,7 @@ func TestAccessRequestDeprovision(t *testing.T) {
 				AppName: "app1",
 				Details: map[string]string{
 					common.ContractID: tc.contractID,
+					common.Imported:   strconv.FormatBool(tc.imported),
 				},
 				InstanceDetails: map[string]interface{}{
 					common.AttrAPIID: tc.apiID,
@@ -54,19 +66,29 @@ func TestAccessRequestDeprovision(t *testing.T) {
 			}
 			status := prv.AccessRequestDeprovision(req)
 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
+			if tc.imported {
+				assert.Empty(t, client.deletedContracts)
+			} else if tc.status == prov.Success {
+				assert.Equal(t, []string{tc.contractID}, client.deletedContracts)
+			}
 		})
 	}
 }
 
 func TestAccessRequestProvision(t *testing.T) {
 	tests := []struct {
-		name    string
-		status  prov.Status
-		apiID   string
-		stage   string
-		appID   string
-		slaTier string
-		err     error
+		name           string
+		status         prov.Status
+		apiID          string
+		stage          string
+		appID          string
+		slaTier        string
+		err            error
+		pending        bool
+		revoked        bool
+		contractErr    error
+		contractID     string
+		contractStatus string
 	}{
 		{
 			name:    "should provision an access request",
@@ -76,6 +98,15 @@ func TestAccessRequestProvision(t *testing.T) {
 			appID:   "65432",
 			slaTier: "1143480-free",
 		},
+		{
+			name:    "should keep an access request with a contract waiting for approval pending",
+			status:  prov.Pending,
+			apiID:   "111",
+			stage:   "v1",
+			appID:   "65432",
+			slaTier: "1143480-gold",
+			pending: true,
+		},
 		{
 			name:    "should return an error when provisioning",
 			status:  prov.Error,
@@ -117,18 +148,62 @@ func TestAccessRequestProvision(t *testing.T) {
 			appID:   "65432",
 			slaTier: "",
 		},
+		{
+			name:       "should not create a contract for an access request with an approved contract",
+			status:     prov.Success,
+			apiID:      "111",
+			stage:      "v1",
+			appID:      "65432",
+			contractID: "98765",
+		},
+		{
+			name:           "should keep an access request pending while its contract waits for approval",
+			status:         prov.Pending,
+			apiID:          "111",
+			stage:          "v1",
+			appID:          "65432",
+			contractID:     "98765",
+			contractStatus: common.ContractPending,
+			pending:        true,
+		},
+		{
+			name:       "should fail an access request whose contract was revoked",
+			status:     prov.Error,
+			apiID:      "111",
+			stage:      "v1",
+			appID:      "65432",
+			contractID: "98765",
+			revoked:    true,
+		},
+		{
+			name:        "should fail an access request whose contract was removed",
+			status:      prov.Error,
+			apiID:       "111",
+			stage:       "v1",
+			appID:       "65432",
+			contractID:  "98765",
+			contractErr: fmt.Errorf("not found"),
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			contract := &anypoint.Contract{
-				ID: 98765,
+				ID:     98765,
+				Status: common.ContractApproved,
+			}
+			if tc.pending {
+				contract.Status = common.ContractPending
+			}
+			if tc.revoked {
+				contract.Status = common.ContractRevoked
 			}
 
 			client := &MockMuleSubscriptionClient{
-				app:      nil,
-				err:      tc.err,
-				contract: contract,
+				app:         nil,
+				err:         tc.err,
+				contract:    contract,
+				contractErr: tc.contractErr,
 			}
 
 			prv := NewProvisioner(client, logrus.StandardLogger())
@@ -146,12 +221,29 @@ func TestAccessRequestProvision(t *testing.T) {
 				AccessRequestData: map[string]interface{}{
 					common.SlaTier: tc.slaTier,
 				},
+				Details: map[string]string{
+					common.ContractID:     tc.contractID,
+					common.ContractStatus: tc.contractStatus,
+				},
 			}
 
 			status, _ := prv.AccessRequestProvision(req)
 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
-			if tc.status == prov.Success {
+			if tc.contractID != "" {
+				assert.Empty(t, client.createdContracts)
+				if tc.status != prov.Error {
+					assert.Equal(t, contract.Status, status.GetProperties()[common.ContractStatus])
+				}
+			} else if tc.status != prov.Error {
 				assert.Contains(t, status.GetProperties(), common.ContractID)
+				if tc.pending {
+					assert.Equal(t, common.ContractPending, status.GetProperties()[common.ContractStatus])
+					assert.Equal(t, tc.apiID, status.GetProperties()[common.APIID])
+					assert.Contains(t, status.GetProperties(), common.ContractRequested)
+					assert.Contains(t, status.GetMessage(), "waiting for approval")
+				} else {
+					assert.NotContains(t, status.GetProperties(), common.ContractStatus)
+				}
 			} else {
 				assert.Empty(t, status.GetProperties(), common.ContractID)
 			}
@@ -159,6 +251,121 @@ func TestAccessRequestProvision(t *testing.T) {
 	}
 }
 
+func TestAccessRequestProvisionRollback(t *testing.T) {
+	managedApp := management.NewManagedApplication("app1", "env")
+	managedAppRI, err := managedApp.AsInstance()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name              string
+		appID             string
+		tierErr           error
+		createContractErr error
+		saveErr           error
+		deleteErr         error
+		status            prov.Status
+		deletedApps       []string
+		deletedContracts  []string
+		message           string
+	}{
+		{
+			name:   "should save the id of the created app",
+			status: prov.Success,
+		},
+		{
+			name:        "should delete the created app when the SLA tier cannot be created",
+			tierErr:     fmt.Errorf("tier error"),
+			status:      prov.Error,
+			deletedApps: []string{"555"},
+			message:     "failed to create SLA tier: tier error",
+		},
+		{
+			name:              "should delete the created app when the contract cannot be created",
+			createContractErr: fmt.Errorf("contract error"),
+			status:            prov.Error,
+			deletedApps:       []string{"555"},
+			message:           "failed to create contract: contract error",
+		},
+		{
+			name:             "should delete the contract and the app when the app id cannot be saved",
+			saveErr:          fmt.Errorf("save error"),
+			status:           prov.Error,
+			deletedApps:      []string{"555"},
+			deletedContracts: []string{"98765"},
+			message:          "failed to save the app id to the managed application: save error",
+		},
+		{
+			name:              "should keep an existing app when the contract cannot be created",
+			appID:             "65432",
+			createContractErr: fmt.Errorf("contract error"),
+			status:            prov.Error,
+			message:           "failed to create contract: contract error",
+		},
+		{
+			name:              "should report the resources that cannot be removed",
+			createContractErr: fmt.Errorf("contract error"),
+			deleteErr:         fmt.Errorf("delete error"),
+			status:            prov.Error,
+			deletedApps:       []string{"555"},
+			message:           "failed to create contract: contract error, failed to roll back delete app 555: delete error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				app:               &anypoint.Application{ID: 555},
+				contract:          &anypoint.Contract{ID: 98765, Status: common.ContractApproved},
+				tierErr:           tc.tierErr,
+				createContractErr: tc.createContractErr,
+				deleteErr:         tc.deleteErr,
+			}
+			central := &mockImportCentralClient{
+				mockCentralClient: mockCentralClient{createSubResErr: tc.saveErr},
+				resources:         map[string]*v1.ResourceInstance{managedApp.GetSelfLink(): managedAppRI},
+			}
+
+			prv := NewProvisioner(client, logrus.StandardLogger(), WithCentralClient(central, "env"))
+			req := &mock.MockAccessRequest{
+				AppName:    "app1",
+				AppDetails: map[string]string{common.AppID: tc.appID},
+				InstanceDetails: map[string]interface{}{
+					common.AttrAPIID:          "111",
+					defs.AttrExternalAPIStage: "v1",
+				},
+			}
+
+			status, _ := prv.AccessRequestProvision(req)
+			assert.Equal(t, tc.status.String(), status.GetStatus().String())
+			assert.Equal(t, tc.deletedApps, client.deletedApps)
+			assert.Equal(t, tc.deletedContracts, client.deletedContracts)
+			if tc.status == prov.Error {
+				assert.Equal(t, tc.message, status.GetMessage())
+				return
+			}
+			details := central.subResources[defs.XAgentDetails].(map[string]interface{})
+			assert.Equal(t, "555", details[common.AppID])
+		})
+	}
+}
+
+func TestImportedApplicationRequestDeprovision(t *testing.T) {
+	// the Mulesoft app of an imported application is not removed
+	client := &MockMuleSubscriptionClient{
+		err: fmt.Errorf("the app should not be removed"),
+	}
+	prv := NewProvisioner(client, logrus.StandardLogger())
+	req := mock.MockApplicationRequest{
+		AppName: "mulesoft-app-65432",
+		Details: map[string]string{
+			common.AppID:    "65432",
+			common.Imported: "true",
+		},
+	}
+	status := prv.ApplicationRequestDeprovision(req)
+	assert.Equal(t, prov.Success.String(), status.GetStatus().String())
+}
+
 // func TestApplicationRequestDeprovision(t *testing.T) {
 // 	tests := []struct {
 // 		name   string
@@ -202,71 +409,402 @@ func TestAccessRequestProvision(t *testing.T) {
 // 	}
 // }
 
-// func TestApplicationRequestProvision(t *testing.T) {
-// 	tests := []struct {
-// 		name    string
-// 		status  prov.Status
-// 		err     error
-// 		appName string
-// 	}{
-// 		{
-// 			name:    "should provision an application",
-// 			appName: "app1",
-// 			status:  prov.Success,
-// 		},
-// 		{
-// 			name:   "should return an error when the appName is not found",
-// 			status: prov.Error,
-// 		},
-// 		{
-// 			name:    "should return an error when the appName is not found",
-// 			err:     fmt.Errorf("fail to deprovision"),
-// 			appName: "app1",
-// 			status:  prov.Error,
-// 		},
-// 	}
+type mockTeamCache struct{}
 
-// 	for _, tc := range tests {
-// 		t.Run(tc.name, func(t *testing.T) {
-// 			app := &anypoint.Application{
-// 				APIEndpoints: false,
-// 				ID:           65432,
-// 				Name:         tc.appName,
-// 			}
-// 			client := &MockMuleSubscriptionClient{
-// 				err: tc.err,
-// 				app: app,
-// 			}
-// 			prv := NewProvisioner(client, logrus.StandardLogger())
-// 			req := mock.MockApplicationRequest{
-// 				AppName: tc.appName,
-// 			}
-// 			status := prv.ApplicationRequestProvision(req)
-// 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
-// 			if tc.status == prov.Success {
-// 				assert.Contains(t, status.GetProperties(), common.AppID)
-// 			} else {
-// 				assert.Empty(t, status.GetProperties(), common.AppID)
-// 			}
-// 		})
-// 	}
-// }
+func (m mockTeamCache) GetTeamByID(id string) *defs.PlatformTeam {
+	if id != "team-id" {
+		return nil
+	}
+	return &defs.PlatformTeam{ID: id, Name: "owner"}
+}
 
-func TestCredentialDeprovision(t *testing.T) {
-	client := &MockMuleSubscriptionClient{}
-	prv := NewProvisioner(client, logrus.StandardLogger())
-	req := mock.MockCredentialRequest{}
-	status := prv.CredentialDeprovision(req)
+func TestApplicationRequestProvision(t *testing.T) {
+	managedApp := management.NewManagedApplication("app1", "env")
+	managedApp.Title = "App One"
+	managedAppRI, err := managedApp.AsInstance()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		status      prov.Status
+		err         error
+		listErr     error
+		apps        []anypoint.Application
+		appName     string
+		appID       string
+		teamName    string
+		description string
+		expectedID  string
+	}{
+		{
+			name:       "should use the app the agent created before",
+			appName:    "app1",
+			apps:       []anypoint.Application{{ID: 777, Name: "app1", Description: common.AxwayAgentAppDescription}},
+			status:     prov.Success,
+			expectedID: "777",
+		},
+		{
+			name:        "should not take over an app created outside of the agent",
+			appName:     "app1",
+			apps:        []anypoint.Application{{ID: 778, Name: "app1", Description: "other"}},
+			status:      prov.Success,
+			description: common.AxwayAgentAppDescription + ": App One",
+		},
+		{
+			name:    "should return an error when the apps cannot be listed",
+			appName: "app1",
+			listErr: fmt.Errorf("list error"),
+			status:  prov.Error,
+		},
+		{
+			name:        "should provision an application",
+			appName:     "app1",
+			status:      prov.Success,
+			description: common.AxwayAgentAppDescription + ": App One",
+		},
+		{
+			name:        "should create the app with the owning team",
+			appName:     "app1",
+			teamName:    "team1",
+			status:      prov.Success,
+			description: common.AxwayAgentAppDescription + " for team team1: App One",
+		},
+		{
+			name:    "should keep the app of a provisioned application",
+			appName: "app1",
+			appID:   "12345",
+			status:  prov.Success,
+		},
+		{
+			name:   "should return an error when the appName is not found",
+			status: prov.Error,
+		},
+		{
+			name:        "should return an error when the app cannot be created",
+			err:         fmt.Errorf("fail to create"),
+			appName:     "app1",
+			status:      prov.Error,
+			description: common.AxwayAgentAppDescription + ": App One",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				err:     tc.err,
+				app:     &anypoint.Application{ID: 65432, Name: tc.appName},
+				apps:    tc.apps,
+				listErr: tc.listErr,
+			}
+			central := &mockImportCentralClient{
+				resources: map[string]*v1.ResourceInstance{managedApp.GetSelfLink(): managedAppRI},
+			}
+			prv := NewProvisioner(client, logrus.StandardLogger(), WithCentralClient(central, "env"))
+			req := mock.MockApplicationRequest{
+				AppName:  tc.appName,
+				TeamName: tc.teamName,
+				Details:  map[string]string{common.AppID: tc.appID},
+			}
+			status := prv.ApplicationRequestProvision(req)
+			assert.Equal(t, tc.status.String(), status.GetStatus().String())
+			assert.Equal(t, tc.description, client.appDescription)
+			switch {
+			case tc.status == prov.Error:
+				assert.Empty(t, status.GetProperties())
+			case tc.appID != "":
+				assert.Equal(t, tc.appID, status.GetProperties()[common.AppID])
+			case tc.expectedID != "":
+				assert.Equal(t, tc.expectedID, status.GetProperties()[common.AppID])
+			default:
+				assert.Equal(t, "65432", status.GetProperties()[common.AppID])
+			}
+		})
+	}
+}
+
+func TestAccessRequestProvisionAppDescription(t *testing.T) {
+	managedApp := management.NewManagedApplication("app1", "env")
+	managedApp.Title = "App One"
+	managedApp.Owner = &v1.Owner{Type: v1.TeamOwner, ID: "team-id"}
+	managedAppRI, err := managedApp.AsInstance()
+	assert.Nil(t, err)
+
+	client := &MockMuleSubscriptionClient{
+		app:      &anypoint.Application{ID: 555},
+		contract: &anypoint.Contract{ID: 98765, Status: common.ContractApproved},
+	}
+	central := &mockImportCentralClient{
+		resources: map[string]*v1.ResourceInstance{managedApp.GetSelfLink(): managedAppRI},
+	}
+	prv := NewProvisioner(client, logrus.StandardLogger(), WithCentralClient(central, "env"), WithTeamCache(mockTeamCache{}))
+	req := &mock.MockAccessRequest{
+		AppName:         "app1",
+		AppDetails:      map[string]string{},
+		InstanceDetails: map[string]interface{}{common.AttrAPIID: "111", defs.AttrExternalAPIStage: "v1"},
+	}
+
+	status, _ := prv.AccessRequestProvision(req)
 	assert.Equal(t, prov.Success.String(), status.GetStatus().String())
+	// the app created for an access request has the same description as the app of an application request
+	assert.Equal(t, common.AxwayAgentAppDescription+" for team owner: App One", client.appDescription)
+}
+
+func TestCredentialDeprovision(t *testing.T) {
+	tests := []struct {
+		name      string
+		appID     string
+		imported  string
+		state     string
+		getAppErr error
+		rotateErr error
+		status    prov.Status
+		reset     bool
+		restored  []string
+		shared    bool
+	}{
+		{
+			name:   "should deprovision credentials of an application without an app",
+			status: prov.Success,
+		},
+		{
+			name:   "should reset the secret of the app",
+			appID:  "65432",
+			status: prov.Success,
+			reset:  true,
+		},
+		{
+			name:     "should restore the contracts of suspended credentials",
+			appID:    "65432",
+			state:    common.CredentialSuspended,
+			status:   prov.Success,
+			reset:    true,
+			restored: []string{"98765"},
+		},
+		{
+			name:   "should keep the secret of an app with other active credentials",
+			appID:  "65432",
+			shared: true,
+			status: prov.Success,
+		},
+		{
+			name:     "should keep the secret of an imported app",
+			appID:    "65432",
+			imported: "true",
+			status:   prov.Success,
+		},
+		{
+			name:      "should deprovision credentials of a removed app",
+			appID:     "65432",
+			getAppErr: fmt.Errorf("app not found"),
+			status:    prov.Success,
+		},
+		{
+			name:      "should fail when the secret cannot be reset",
+			appID:     "65432",
+			rotateErr: fmt.Errorf("failed to reset"),
+			status:    prov.Error,
+			reset:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				app:       &anypoint.Application{ID: 65432},
+				newApp:    &anypoint.Application{ID: 65432},
+				err:       tc.getAppErr,
+				rotateErr: tc.rotateErr,
+			}
+			central := newCredentialCentralClient(t)
+			if tc.shared {
+				addCredential(t, central, "cred2", v1.Active)
+			}
+			prv := NewProvisioner(client, logrus.StandardLogger(), WithCentralClient(central, "env"))
+			req := mock.MockCredentialRequest{
+				Name:    "cred1",
+				AppName: "app1",
+				AppDetails: map[string]string{
+					common.AppID:    tc.appID,
+					common.Imported: tc.imported,
+				},
+			}
+			if tc.state == common.CredentialSuspended {
+				req.AppDetails[common.RevokedContracts] = "111/98765"
+			}
+			status := prv.CredentialDeprovision(req)
+			assert.Equal(t, tc.status.String(), status.GetStatus().String())
+			if tc.reset {
+				assert.Equal(t, []string{"65432"}, client.resetApps)
+			} else {
+				assert.Empty(t, client.resetApps)
+			}
+			assert.Equal(t, tc.restored, client.restoredContracts)
+			if tc.restored != nil {
+				details := central.subResources[defs.XAgentDetails].(map[string]interface{})
+				assert.Equal(t, common.CredentialActive, details[common.CredentialState])
+				assert.Equal(t, "", details[common.RevokedContracts])
+			}
+		})
+	}
+}
+
+func newCredentialCentralClient(t *testing.T) *mockImportCentralClient {
+	managedApp := management.NewManagedApplication("app1", "env")
+	managedAppRI, err := managedApp.AsInstance()
+	assert.Nil(t, err)
+	return &mockImportCentralClient{
+		resources: map[string]*v1.ResourceInstance{managedApp.GetSelfLink(): managedAppRI},
+	}
+}
+
+// addCredential adds a provisioned credential of the managed application app1
+func addCredential(t *testing.T, central *mockImportCentralClient, name, state string) {
+	credential := management.NewCredential(name, "env")
+	credential.Spec.ManagedApplication = "app1"
+	credential.Spec.State.Name = state
+	credential.Status = &v1.ResourceStatus{Level: prov.Success.String()}
+	ri, err := credential.AsInstance()
+	assert.Nil(t, err)
+	central.resources[credential.GetSelfLink()] = ri
+}
+
+func TestCredentialSuspendAndEnable(t *testing.T) {
+	tests := []struct {
+		name             string
+		action           prov.CredentialAction
+		revokedDetail    string
+		revokeErr        error
+		saveErr          error
+		status           prov.Status
+		revoked          []string
+		restored         []string
+		expectedState    string
+		expectedRevoked  string
+		expectedAppState string
+		shared           string
+	}{
+		{
+			name:             "should suspend credentials by revoking the approved and pending contracts",
+			action:           prov.Suspend,
+			status:           prov.Success,
+			revoked:          []string{"1", "3", "4"},
+			expectedState:    common.CredentialSuspended,
+			expectedRevoked:  "111/1,222/3,333/4",
+			expectedAppState: common.CredentialSuspended,
+		},
+		{
+			name:          "should keep the contracts while other credentials of the application are active",
+			action:        prov.Suspend,
+			shared:        v1.Active,
+			status:        prov.Success,
+			expectedState: common.CredentialSuspended,
+		},
+		{
+			name:             "should revoke the contracts when the other credentials are inactive",
+			action:           prov.Suspend,
+			shared:           v1.Inactive,
+			status:           prov.Success,
+			revoked:          []string{"1", "3", "4"},
+			expectedState:    common.CredentialSuspended,
+			expectedRevoked:  "111/1,222/3,333/4",
+			expectedAppState: common.CredentialSuspended,
+		},
+		{
+			name:      "should fail to suspend credentials when a contract cannot be revoked",
+			action:    prov.Suspend,
+			revokeErr: fmt.Errorf("failed to revoke"),
+			status:    prov.Error,
+			revoked:   []string{"1"},
+		},
+		{
+			name:     "should restore the revoked contracts when the managed application cannot be updated",
+			action:   prov.Suspend,
+			saveErr:  fmt.Errorf("failed to update"),
+			status:   prov.Error,
+			revoked:  []string{"1", "3", "4"},
+			restored: []string{"4", "3", "1"},
+		},
+		{
+			name:             "should enable credentials by restoring the revoked contracts",
+			action:           prov.Enable,
+			revokedDetail:    "111/1,222/3",
+			status:           prov.Success,
+			restored:         []string{"1", "3"},
+			expectedState:    common.CredentialActive,
+			expectedAppState: common.CredentialActive,
+		},
+		{
+			name:          "should fail to enable credentials when a contract cannot be restored",
+			action:        prov.Enable,
+			revokedDetail: "111/1,222/3",
+			revokeErr:     fmt.Errorf("failed to restore"),
+			status:        prov.Error,
+			restored:      []string{"1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				app: &anypoint.Application{ID: 65432, ClientID: "12345", ClientSecret: "lajksdf"},
+				appContracts: []anypoint.Contract{
+					{ID: 1, ApiID: "111", Status: common.ContractApproved},
+					{ID: 2, ApiID: "111", Status: common.ContractRejected},
+					{ID: 3, ApiID: "222", Status: common.ContractApproved},
+					{ID: 4, ApiID: "333", Status: common.ContractPending},
+				},
+				revokeErr: tc.revokeErr,
+			}
+			central := newCredentialCentralClient(t)
+			central.createSubResErr = tc.saveErr
+			if tc.shared != "" {
+				addCredential(t, central, "cred2", tc.shared)
+			}
+			prv := NewProvisioner(client, logrus.StandardLogger(), WithCentralClient(central, "env"))
+			req := mock.MockCredentialRequest{
+				Name:    "cred1",
+				AppName: "app1",
+				AppDetails: map[string]string{
+					common.AppID:            "65432",
+					common.RevokedContracts: tc.revokedDetail,
+				},
+				Action: tc.action,
+			}
+
+			status, cr := prv.CredentialUpdate(req)
+			assert.Equal(t, tc.status.String(), status.GetStatus().String())
+			assert.Equal(t, tc.revoked, client.revokedContracts)
+			assert.Equal(t, tc.restored, client.restoredContracts)
+			if tc.status == prov.Error {
+				assert.Nil(t, cr)
+				return
+			}
+
+			assert.Equal(t, tc.expectedState, status.GetProperties()[common.CredentialState])
+			if tc.expectedAppState == "" {
+				assert.Nil(t, central.subResources)
+			} else {
+				details := central.subResources[defs.XAgentDetails].(map[string]interface{})
+				assert.Equal(t, tc.expectedAppState, details[common.CredentialState])
+				assert.Equal(t, tc.expectedRevoked, details[common.RevokedContracts])
+			}
+			if tc.action == prov.Enable {
+				assert.Equal(t, "lajksdf", cr.GetData()[prov.OauthClientSecret])
+			} else {
+				assert.Nil(t, cr)
+			}
+		})
+	}
 }
 
 func TestCredentialProvision(t *testing.T) {
 	tests := []struct {
-		name    string
-		appName string
-		appID   string
-		err     error
-		status  prov.Status
+		name     string
+		appName  string
+		appID    string
+		credType string
+		err      error
+		status   prov.Status
 	}{
 		{
 			name:    "should provision basic auth credentials",
@@ -274,6 +812,27 @@ func TestCredentialProvision(t *testing.T) {
 			appID:   "65432",
 			status:  prov.Success,
 		},
+		{
+			name:     "should provision oauth credentials",
+			appName:  "app1",
+			appID:    "65432",
+			credType: provisioning.OAuthSecretCRD,
+			status:   prov.Success,
+		},
+		{
+			name:     "should provision api key credentials",
+			appName:  "app1",
+			appID:    "65432",
+			credType: provisioning.APIKeyCRD,
+			status:   prov.Success,
+		},
+		{
+			name:     "should return an error for an unsupported credential type",
+			appName:  "app1",
+			appID:    "65432",
+			credType: provisioning.MtlsCRD,
+			status:   prov.Error,
+		},
 		{
 			name:    "should fail to provision credentials",
 			appName: "app1",
@@ -305,19 +864,32 @@ func TestCredentialProvision(t *testing.T) {
 				app: app,
 			}
 			prv := NewProvisioner(client, logrus.StandardLogger())
+			credType := tc.credType
+			if credType == "" {
+				credType = provisioning.BasicAuthCRD
+			}
 			req := mock.MockCredentialRequest{
 				AppName: tc.appName,
 				AppDetails: map[string]string{
 					common.AppID: tc.appID,
 				},
-				CredDefName: provisioning.BasicAuthCRD,
+				CredDefName: credType,
 			}
 			status, cr := prv.CredentialProvision(req)
 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
 			if tc.status.String() == prov.Success.String() {
 				assert.NotNil(t, cr)
-				assert.Contains(t, cr.GetData(), prov.BasicAuthUsername)
-				assert.Contains(t, cr.GetData(), prov.BasicAuthPassword)
+				switch credType {
+				case provisioning.OAuthSecretCRD:
+					assert.Equal(t, app.ClientID, cr.GetData()[prov.OauthClientID])
+					assert.Equal(t, app.ClientSecret, cr.GetData()[prov.OauthClientSecret])
+				case provisioning.APIKeyCRD:
+					assert.Equal(t, app.ClientID, cr.GetData()[prov.APIKey])
+					assert.Equal(t, app.ClientSecret, cr.GetData()[common.ClientSecretName])
+				default:
+					assert.Equal(t, app.ClientID, cr.GetData()[prov.BasicAuthUsername])
+					assert.Equal(t, app.ClientSecret, cr.GetData()[prov.BasicAuthPassword])
+				}
 			} else {
 				assert.Nil(t, cr)
 			}
@@ -334,6 +906,7 @@ func TestCredentialUpdate(t *testing.T) {
 		rotateErr error
 		status    prov.Status
 		action    prov.CredentialAction
+		credType  string
 	}{
 		{
 			name:    "should update credentials",
@@ -343,11 +916,26 @@ func TestCredentialUpdate(t *testing.T) {
 			action:  prov.Rotate,
 		},
 		{
-			name:    "should fail to update credentials when the action is not rotate",
+			name:     "should update basic auth credentials",
+			appName:  "app1",
+			appID:    "65432",
+			status:   prov.Success,
+			action:   prov.Rotate,
+			credType: provisioning.BasicAuthCRD,
+		},
+		{
+			name:     "should fail to update credentials of an unsupported type",
+			appName:  "app1",
+			appID:    "65432",
+			status:   prov.Error,
+			action:   prov.Rotate,
+			credType: provisioning.MtlsCRD,
+		},
+		{
+			name:    "should fail to update credentials when the appID is not found",
 			appName: "app1",
-			appID:   "65432",
 			status:  prov.Error,
-			action:  prov.Suspend,
+			action:  prov.Rotate,
 		},
 		{
 			name:      "should fail to update credentials when making the api call",
@@ -389,12 +977,16 @@ func TestCredentialUpdate(t *testing.T) {
 				AppDetails: map[string]string{
 					common.AppID: tc.appID,
 				},
-				Action: tc.action,
+				Action:      tc.action,
+				CredDefName: tc.credType,
 			}
 
 			status, cr := prv.CredentialUpdate(req)
 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
-			if tc.status.String() == prov.Success.String() {
+			if tc.status.String() == prov.Success.String() && tc.credType == provisioning.BasicAuthCRD {
+				assert.NotNil(t, cr)
+				assert.Equal(t, newApp.ClientSecret, cr.GetData()[prov.BasicAuthPassword])
+			} else if tc.status.String() == prov.Success.String() {
 				assert.NotNil(t, cr)
 				assert.Contains(t, cr.GetData(), prov.OauthClientSecret)
 				assert.Contains(t, cr.GetData(), prov.OauthClientID)
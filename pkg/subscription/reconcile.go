@@ -0,0 +1,276 @@
+package subscription
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileCache is the part of the SDK cache manager with the access requests, managed applications and instances
+type reconcileCache interface {
+	accessRequestCache
+	GetAPIServiceInstanceByName(name string) (*v1.ResourceInstance, error)
+	GetManagedApplicationCacheKeys() []string
+	GetManagedApplication(id string) *v1.ResourceInstance
+	GetManagedApplicationByName(name string) *v1.ResourceInstance
+}
+
+// ReconciliationJob compares the provisioned access requests and managed applications in Central with the contracts
+// and the applications in Mulesoft. Contracts removed in Mulesoft are created again, or their access requests are
+// failed when re-provisioning is disabled. Access requests of revoked or rejected contracts and managed applications
+// of removed Mule apps are failed. Mule apps created by the agent without a managed application are reported.
+type ReconciliationJob struct {
+	client      MuleSubscriptionClient
+	central     centralClient
+	cache       reconcileCache
+	reprovision bool
+	log         logrus.FieldLogger
+}
+
+// NewReconciliationJob creates a ReconciliationJob
+func NewReconciliationJob(
+	client MuleSubscriptionClient,
+	central centralClient,
+	cache reconcileCache,
+	reprovision bool,
+	log logrus.FieldLogger,
+) *ReconciliationJob {
+	return &ReconciliationJob{
+		client:      client,
+		central:     central,
+		cache:       cache,
+		reprovision: reprovision,
+		log:         log.WithField("component", "reconciliation"),
+	}
+}
+
+// Execute reconciles the managed applications and the access requests
+func (j *ReconciliationJob) Execute() error {
+	apps, err := j.client.ListApps()
+	if err != nil {
+		j.log.WithError(err).Warn("failed to list the Mulesoft applications, the reconciliation runs again on the next interval")
+		return nil
+	}
+	muleApps := map[string]anypoint.Application{}
+	for _, app := range apps {
+		muleApps[strconv.Itoa(app.ID)] = app
+	}
+
+	referenced, removed := j.reconcileManagedApps(muleApps)
+	j.reportOrphanedApps(apps, referenced)
+	j.reconcileAccessRequests(removed)
+	return nil
+}
+
+// Status returns nil, a failure to reconcile a resource is retried on the next run
+func (j *ReconciliationJob) Status() error {
+	return nil
+}
+
+// Ready returns true
+func (j *ReconciliationJob) Ready() bool {
+	return true
+}
+
+// reconcileManagedApps fails the provisioned managed applications whose Mule app was removed. It returns the ids of the
+// Mule apps referenced by managed applications, and the ids of the removed Mule apps by managed application name.
+func (j *ReconciliationJob) reconcileManagedApps(muleApps map[string]anypoint.Application) (map[string]bool, map[string]string) {
+	referenced := map[string]bool{}
+	removed := map[string]string{}
+
+	for _, key := range j.cache.GetManagedApplicationCacheKeys() {
+		ri := j.cache.GetManagedApplication(key)
+		if ri == nil {
+			continue
+		}
+		app := &management.ManagedApplication{}
+		if err := app.FromInstance(ri); err != nil {
+			continue
+		}
+		appID := util.GetAgentDetailStrings(ri)[common.AppID]
+		if appID == "" {
+			continue
+		}
+		referenced[appID] = true
+
+		if !isProvisioned(app.Metadata.State, app.Status) {
+			continue
+		}
+		if _, ok := muleApps[appID]; ok {
+			continue
+		}
+		removed[app.Name] = appID
+
+		logger := j.log.WithField("managedApp", app.Name).WithField("appID", appID)
+		message := fmt.Sprintf("Mulesoft application %s was removed", appID)
+		if err := setStatus(j.central, logger, ri, managedAppFinalizer, app.Status, prov.Error, message); err != nil {
+			logger.WithError(err).Error("failed to update the managed application")
+			continue
+		}
+		logger.Warn(message)
+	}
+	return referenced, removed
+}
+
+// reportOrphanedApps logs the Mule apps created by the agent that no managed application references
+func (j *ReconciliationJob) reportOrphanedApps(apps []anypoint.Application, referenced map[string]bool) {
+	for _, app := range apps {
+		if !strings.HasPrefix(app.Description, common.AxwayAgentAppDescription) || referenced[strconv.Itoa(app.ID)] {
+			continue
+		}
+		j.log.
+			WithField("appID", app.ID).
+			WithField("appName", app.Name).
+			Warn("Mulesoft application created by the agent is not used by a managed application")
+	}
+}
+
+// reconcileAccessRequests checks the contracts of the provisioned access requests, API by API
+func (j *ReconciliationJob) reconcileAccessRequests(removedApps map[string]string) {
+	byAPI := map[string][]*v1.ResourceInstance{}
+
+	for _, ri := range j.cache.ListAccessRequests() {
+		ar := &management.AccessRequest{}
+		if err := ar.FromInstance(ri); err != nil || !isProvisioned(ar.Metadata.State, ar.Status) {
+			continue
+		}
+		details := util.GetAgentDetailStrings(ri)
+		if details[common.ContractID] == "" {
+			continue
+		}
+		// the contracts of the managed applications with suspended credentials are revoked
+		if app := j.cache.GetManagedApplicationByName(ar.Spec.ManagedApplication); app != nil &&
+			util.GetAgentDetailStrings(app)[common.CredentialState] == common.CredentialSuspended {
+			continue
+		}
+		// pending contracts are handled by the approval job, the others already failed
+		switch details[common.ContractStatus] {
+		case common.ContractPending, common.ContractRejected, common.ContractRevoked:
+			continue
+		}
+
+		instance, err := j.cache.GetAPIServiceInstanceByName(ar.Spec.ApiServiceInstance)
+		if err != nil || instance == nil {
+			continue
+		}
+		apiID := util.GetAgentDetailStrings(instance)[common.AttrAPIID]
+		if apiID == "" {
+			continue
+		}
+		byAPI[apiID] = append(byAPI[apiID], ri)
+	}
+
+	for apiID, accessRequests := range byAPI {
+		list, err := j.client.ListContracts(apiID)
+		if err != nil {
+			j.log.WithError(err).WithField("api", apiID).Warn("failed to list the contracts of the api")
+			continue
+		}
+		contracts := map[string]anypoint.Contract{}
+		for _, contract := range list {
+			contracts[strconv.Itoa(contract.ID)] = contract
+		}
+
+		for _, ri := range accessRequests {
+			j.reconcileAccessRequest(apiID, ri, contracts, removedApps)
+		}
+	}
+}
+
+func (j *ReconciliationJob) reconcileAccessRequest(
+	apiID string, ri *v1.ResourceInstance, contracts map[string]anypoint.Contract, removedApps map[string]string,
+) {
+	ar := &management.AccessRequest{}
+	if err := ar.FromInstance(ri); err != nil {
+		return
+	}
+	contractID := util.GetAgentDetailStrings(ri)[common.ContractID]
+	logger := j.log.
+		WithField("accessRequest", ar.Name).
+		WithField("api", apiID).
+		WithField("contractID", contractID)
+
+	if appID, ok := removedApps[ar.Spec.ManagedApplication]; ok {
+		util.SetAgentDetailsKey(ri, common.ContractStatus, common.ContractRevoked)
+		j.update(logger, ri, ar, prov.Error, fmt.Sprintf("Mulesoft application %s of the contract was removed", appID))
+		return
+	}
+
+	contract, ok := contracts[contractID]
+	if !ok {
+		j.reprovisionContract(logger, apiID, ri, ar, contractID)
+		return
+	}
+
+	switch contract.Status {
+	case common.ContractRevoked, common.ContractRejected:
+		util.SetAgentDetailsKey(ri, common.ContractStatus, contract.Status)
+		j.update(logger, ri, ar, prov.Error, fmt.Sprintf("contract %s was %s in Mulesoft", contractID, contract.Status))
+	}
+}
+
+// reprovisionContract creates the contract of an access request again, or fails the access request when
+// re-provisioning is disabled or the contract cannot be created.
+func (j *ReconciliationJob) reprovisionContract(
+	logger logrus.FieldLogger, apiID string, ri *v1.ResourceInstance, ar *management.AccessRequest, contractID string,
+) {
+	removed := fmt.Sprintf("contract %s was removed in Mulesoft", contractID)
+	if !j.reprovision {
+		util.SetAgentDetailsKey(ri, common.ContractStatus, common.ContractRevoked)
+		j.update(logger, ri, ar, prov.Error, removed)
+		return
+	}
+
+	appID := ""
+	if app := j.cache.GetManagedApplicationByName(ar.Spec.ManagedApplication); app != nil {
+		appID = util.GetAgentDetailStrings(app)[common.AppID]
+	}
+	if appID == "" {
+		util.SetAgentDetailsKey(ri, common.ContractStatus, common.ContractRevoked)
+		j.update(logger, ri, ar, prov.Error, fmt.Sprintf("%s, %s", removed, notFound(common.AppID)))
+		return
+	}
+
+	tierID := util.GetAgentDetailStrings(ri)[common.SlaTier]
+	contract, err := j.client.CreateContract(apiID, tierID, appID)
+	if err != nil {
+		util.SetAgentDetailsKey(ri, common.ContractStatus, common.ContractRevoked)
+		j.update(logger, ri, ar, prov.Error, fmt.Sprintf("%s, failed to create it again: %s", removed, err))
+		return
+	}
+
+	util.SetAgentDetailsKey(ri, common.ContractID, strconv.Itoa(contract.ID))
+	if contract.Status == common.ContractPending {
+		util.SetAgentDetailsKey(ri, common.ContractStatus, contract.Status)
+		util.SetAgentDetailsKey(ri, common.ContractRequested, time.Now().UTC().Format(time.RFC3339))
+		util.SetAgentDetailsKey(ri, common.APIID, apiID)
+		j.update(logger, ri, ar, prov.Pending, fmt.Sprintf("%s and was created again as contract %d, waiting for approval", removed, contract.ID))
+		return
+	}
+	j.update(logger, ri, ar, prov.Success, fmt.Sprintf("%s and was created again as contract %d", removed, contract.ID))
+}
+
+func (j *ReconciliationJob) update(
+	logger logrus.FieldLogger, ri *v1.ResourceInstance, ar *management.AccessRequest, status prov.Status, message string,
+) {
+	if err := setStatus(j.central, logger, ri, accessRequestFinalizer, ar.Status, status, message); err != nil {
+		logger.WithError(err).Error("failed to update the access request, it is reconciled again on the next run")
+		return
+	}
+	j.cache.AddAccessRequest(ri)
+	logger.Warn(message)
+}
+
+// isProvisioned returns true for a resource that was provisioned and is not being removed
+func isProvisioned(state string, status *v1.ResourceStatus) bool {
+	return state != v1.ResourceDeleting && status != nil && status.Level == prov.Success.String()
+}
@@ -0,0 +1,255 @@
+package subscription
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReconcileCache struct {
+	mockAccessRequestCache
+	instances   map[string]*v1.ResourceInstance
+	managedApps map[string]*v1.ResourceInstance
+}
+
+func (m *mockReconcileCache) GetAPIServiceInstanceByName(name string) (*v1.ResourceInstance, error) {
+	if ri, ok := m.instances[name]; ok {
+		return ri, nil
+	}
+	return nil, fmt.Errorf("instance %s not found", name)
+}
+
+func (m *mockReconcileCache) GetManagedApplicationCacheKeys() []string {
+	keys := []string{}
+	for key := range m.managedApps {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (m *mockReconcileCache) GetManagedApplication(id string) *v1.ResourceInstance {
+	return m.managedApps[id]
+}
+
+func (m *mockReconcileCache) GetManagedApplicationByName(name string) *v1.ResourceInstance {
+	return m.managedApps[name]
+}
+
+func newReconcileCache(t *testing.T, contractStatus string, appDetails ...map[string]interface{}) *mockReconcileCache {
+	instance := management.NewAPIServiceInstance("inst1", "env")
+	util.SetAgentDetails(instance, map[string]interface{}{common.AttrAPIID: "111"})
+	instanceRI, err := instance.AsInstance()
+	assert.Nil(t, err)
+
+	app := management.NewManagedApplication("app1", "env")
+	app.Status = &v1.ResourceStatus{Level: prov.Success.String()}
+	details := map[string]interface{}{common.AppID: "555"}
+	for _, d := range appDetails {
+		for key, value := range d {
+			details[key] = value
+		}
+	}
+	util.SetAgentDetails(app, details)
+	appRI, err := app.AsInstance()
+	assert.Nil(t, err)
+
+	ar := management.NewAccessRequest("ar1", "env")
+	ar.Spec.ManagedApplication = "app1"
+	ar.Spec.ApiServiceInstance = "inst1"
+	ar.Status = &v1.ResourceStatus{Level: prov.Success.String()}
+	details = map[string]interface{}{
+		common.ContractID: "98765",
+		common.SlaTier:    "14214",
+	}
+	if contractStatus != "" {
+		details[common.ContractStatus] = contractStatus
+	}
+	util.SetAgentDetails(ar, details)
+	arRI, err := ar.AsInstance()
+	assert.Nil(t, err)
+
+	return &mockReconcileCache{
+		mockAccessRequestCache: mockAccessRequestCache{accessRequests: []*v1.ResourceInstance{arRI}},
+		instances:              map[string]*v1.ResourceInstance{"inst1": instanceRI},
+		managedApps:            map[string]*v1.ResourceInstance{"app1": appRI},
+	}
+}
+
+func TestReconciliationJob(t *testing.T) {
+	muleApp := anypoint.Application{ID: 555, Name: "app1", Description: common.AxwayAgentAppDescription}
+
+	tests := []struct {
+		name               string
+		contractStatus     string
+		apps               []anypoint.Application
+		contracts          []anypoint.Contract
+		newContract        *anypoint.Contract
+		listErr            error
+		reprovision        bool
+		expectedARLevel    string
+		expectedDetails    map[string]string
+		expectedAppLevel   string
+		expectedFinalizers []string
+		recreated          bool
+	}{
+		{
+			name:      "should keep an access request with an approved contract",
+			apps:      []anypoint.Application{muleApp},
+			contracts: []anypoint.Contract{{ID: 98765, Status: common.ContractApproved}},
+		},
+		{
+			name:               "should fail an access request with a revoked contract",
+			apps:               []anypoint.Application{muleApp},
+			contracts:          []anypoint.Contract{{ID: 98765, Status: common.ContractRevoked}},
+			expectedARLevel:    prov.Error.String(),
+			expectedDetails:    map[string]string{common.ContractID: "98765", common.ContractStatus: common.ContractRevoked},
+			expectedFinalizers: []string{accessRequestFinalizer},
+		},
+		{
+			name:            "should create a removed contract again",
+			apps:            []anypoint.Application{muleApp},
+			contracts:       []anypoint.Contract{},
+			newContract:     &anypoint.Contract{ID: 12345, Status: common.ContractApproved},
+			reprovision:     true,
+			expectedARLevel: prov.Success.String(),
+			expectedDetails: map[string]string{common.ContractID: "12345"},
+			recreated:       true,
+		},
+		{
+			name:            "should wait for the approval of a contract created again",
+			apps:            []anypoint.Application{muleApp},
+			contracts:       []anypoint.Contract{},
+			newContract:     &anypoint.Contract{ID: 12345, Status: common.ContractPending},
+			reprovision:     true,
+			expectedARLevel: prov.Pending.String(),
+			expectedDetails: map[string]string{
+				common.ContractID:     "12345",
+				common.ContractStatus: common.ContractPending,
+				common.APIID:          "111",
+			},
+			recreated: true,
+		},
+		{
+			name:               "should fail an access request with a removed contract when re-provisioning is disabled",
+			apps:               []anypoint.Application{muleApp},
+			contracts:          []anypoint.Contract{},
+			expectedARLevel:    prov.Error.String(),
+			expectedDetails:    map[string]string{common.ContractID: "98765", common.ContractStatus: common.ContractRevoked},
+			expectedFinalizers: []string{accessRequestFinalizer},
+		},
+		{
+			name:               "should fail the managed application and the access requests of a removed Mule app",
+			apps:               []anypoint.Application{},
+			contracts:          []anypoint.Contract{},
+			reprovision:        true,
+			expectedAppLevel:   prov.Error.String(),
+			expectedARLevel:    prov.Error.String(),
+			expectedDetails:    map[string]string{common.ContractStatus: common.ContractRevoked},
+			expectedFinalizers: []string{managedAppFinalizer, accessRequestFinalizer},
+		},
+		{
+			name:           "should skip an access request waiting for approval",
+			contractStatus: common.ContractPending,
+			apps:           []anypoint.Application{muleApp},
+			contracts:      []anypoint.Contract{},
+			reprovision:    true,
+		},
+		{
+			name:        "should not reconcile when the Mule apps cannot be listed",
+			listErr:     fmt.Errorf("failed to list"),
+			reprovision: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockMuleSubscriptionClient{
+				apps:      tc.apps,
+				contracts: map[string][]anypoint.Contract{"111": tc.contracts},
+				contract:  tc.newContract,
+				listErr:   tc.listErr,
+			}
+			central := &mockCentralClient{}
+			cache := newReconcileCache(t, tc.contractStatus)
+
+			job := NewReconciliationJob(client, central, cache, tc.reprovision, logrus.StandardLogger())
+			assert.True(t, job.Ready())
+			assert.Nil(t, job.Status())
+			assert.Nil(t, job.Execute())
+
+			if tc.recreated {
+				assert.Equal(t, []string{"555"}, client.createdContracts)
+			} else {
+				assert.Empty(t, client.createdContracts)
+			}
+			assert.Equal(t, tc.expectedFinalizers, central.removedFinalizers)
+
+			if tc.expectedAppLevel != "" {
+				assert.Equal(t, tc.expectedAppLevel, central.statuses["ManagedApplication/app1"].Level)
+			} else {
+				assert.NotContains(t, central.statuses, "ManagedApplication/app1")
+			}
+
+			if tc.expectedARLevel == "" {
+				assert.NotContains(t, central.statuses, "AccessRequest/ar1")
+				assert.Empty(t, cache.added)
+				return
+			}
+			assert.Equal(t, tc.expectedARLevel, central.statuses["AccessRequest/ar1"].Level)
+			details := util.GetAgentDetailStrings(cache.added[0])
+			for key, value := range tc.expectedDetails {
+				assert.Equal(t, value, details[key], key)
+			}
+		})
+	}
+}
+
+func TestReconciliationJobOrphanedApps(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	client := &MockMuleSubscriptionClient{
+		apps: []anypoint.Application{
+			{ID: 555, Name: "app1", Description: common.AxwayAgentAppDescription},
+			{ID: 556, Name: "orphan", Description: newAppDescription("Orphan", "team1")},
+			{ID: 557, Name: "other", Description: "created in Mulesoft"},
+		},
+		contracts: map[string][]anypoint.Contract{"111": {{ID: 98765, Status: common.ContractApproved}}},
+	}
+	cache := newReconcileCache(t, "")
+
+	job := NewReconciliationJob(client, &mockCentralClient{}, cache, true, logger)
+	assert.Nil(t, job.Execute())
+
+	orphans := []interface{}{}
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			orphans = append(orphans, entry.Data["appName"])
+		}
+	}
+	assert.Equal(t, []interface{}{"orphan"}, orphans)
+}
+
+func TestReconciliationJobSuspendedCredentials(t *testing.T) {
+	// the contracts of an application with suspended credentials are revoked until the credentials are enabled
+	client := &MockMuleSubscriptionClient{
+		apps:      []anypoint.Application{{ID: 555, Name: "app1", Description: common.AxwayAgentAppDescription}},
+		contracts: map[string][]anypoint.Contract{"111": {{ID: 98765, Status: common.ContractRevoked}}},
+	}
+	central := &mockCentralClient{}
+	cache := newReconcileCache(t, "", map[string]interface{}{common.CredentialState: common.CredentialSuspended})
+
+	job := NewReconciliationJob(client, central, cache, true, logrus.StandardLogger())
+	assert.Nil(t, job.Execute())
+
+	assert.Empty(t, central.statuses)
+	assert.Empty(t, cache.added)
+	assert.Empty(t, client.createdContracts)
+}
@@ -0,0 +1,120 @@
+package subscription
+
+import (
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// Finalizers the SDK adds to provisioned resources
+const (
+	accessRequestFinalizer = "agent.accessrequest.provisioned"
+	managedAppFinalizer    = "agent.managedapplication.provisioned"
+)
+
+// pendingStatus is the status of a request that waits for a decision in Mulesoft. The status builder of the SDK
+// only builds Success and Failed statuses.
+type pendingStatus struct {
+	message    string
+	properties map[string]string
+	reasons    []v1.ResourceStatusReason
+}
+
+// newPendingStatus creates the Pending status of a request. The reasons of a request that was pending already are
+// kept without its last reason, so that a request processed again while it waits does not add a reason each time.
+func newPendingStatus(message string, properties map[string]string, current *v1.ResourceStatus) prov.RequestStatus {
+	status := &pendingStatus{message: message, properties: properties}
+	if current != nil {
+		status.reasons = current.Reasons
+		if current.Level == prov.Pending.String() && len(status.reasons) > 0 &&
+			status.reasons[len(status.reasons)-1].Type == prov.Pending.String() {
+			status.reasons = status.reasons[:len(status.reasons)-1]
+		}
+	}
+	return status
+}
+
+// GetReasons returns the reasons of the previous statuses
+func (s *pendingStatus) GetReasons() []v1.ResourceStatusReason {
+	return s.reasons
+}
+
+// GetStatus returns the Pending level
+func (s *pendingStatus) GetStatus() prov.Status {
+	return prov.Pending
+}
+
+// GetMessage returns the status message
+func (s *pendingStatus) GetMessage() string {
+	return s.message
+}
+
+// GetProperties returns the agent details of the request
+func (s *pendingStatus) GetProperties() map[string]string {
+	return s.properties
+}
+
+// centralClient is the part of the Central client that updates marketplace resources
+type centralClient interface {
+	CreateSubResource(rm v1.ResourceMeta, subs map[string]interface{}) error
+	UpdateResourceFinalizer(ri *v1.ResourceInstance, finalizer, description string, addAction bool) (*v1.ResourceInstance, error)
+}
+
+// setStatus writes the agent details and a new status of a marketplace resource that is updated outside of the SDK
+// handlers, the current status reasons are kept. The finalizer of a failed resource is removed since the SDK does not
+// deprovision failed resources, and the finalizer would block their removal. The SDK only adds the finalizer to the
+// resources it provisioned with a Success status, so it is added to a resource moved to Success.
+func setStatus(
+	central centralClient,
+	logger logrus.FieldLogger,
+	ri *v1.ResourceInstance,
+	finalizer string,
+	current *v1.ResourceStatus,
+	status prov.Status,
+	message string,
+) error {
+	rs := prov.NewRequestStatusBuilder().SetMessage(message)
+	if current != nil {
+		rs.SetCurrentStatusReasons(current.Reasons)
+	}
+	var requestStatus prov.RequestStatus
+	switch status {
+	case prov.Error:
+		requestStatus = rs.Failed()
+	case prov.Pending:
+		requestStatus = newPendingStatus(message, nil, current)
+	default:
+		requestStatus = rs.Success()
+	}
+
+	err := central.CreateSubResource(ri.ResourceMeta, map[string]interface{}{
+		defs.XAgentDetails: util.GetAgentDetails(ri),
+		"status":           prov.NewStatusReason(requestStatus),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case status == prov.Error:
+		if _, err := central.UpdateResourceFinalizer(ri, finalizer, "", false); err != nil {
+			logger.WithError(err).Error("failed to remove the finalizer")
+		}
+	case status == prov.Success && !hasFinalizer(ri, finalizer):
+		if _, err := central.UpdateResourceFinalizer(ri, finalizer, "", true); err != nil {
+			logger.WithError(err).Error("failed to add the finalizer")
+		}
+	}
+	return nil
+}
+
+func hasFinalizer(ri *v1.ResourceInstance, finalizer string) bool {
+	for _, f := range ri.Finalizers {
+		if f.Name == finalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package subscription
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/sirupsen/logrus"
+)
+
+// Tags of a Mulesoft API that override the settings of the SLA tier the agent creates for the API,
+// e.g. slaTier.limits:100/1s,10000/1h
+const (
+	tierLimitsTag      = "slaTier.limits:"
+	tierVisibleTag     = "slaTier.visible:"
+	tierAutoApproveTag = "slaTier.autoApprove:"
+)
+
+// TierLimit is a maximum number of requests in a time period
+type TierLimit struct {
+	MaximumRequests int
+	TimePeriod      time.Duration
+}
+
+// TierSettings are the limits and the approval mode of the SLA tier the agent creates for an API
+type TierSettings struct {
+	Limits      []TierLimit
+	Visible     bool
+	AutoApprove bool
+}
+
+// DefaultTierSettings returns the settings of a tier with 10 requests per second, approved automatically
+func DefaultTierSettings() *TierSettings {
+	return &TierSettings{
+		Limits:      []TierLimit{{MaximumRequests: 10, TimePeriod: time.Second}},
+		Visible:     true,
+		AutoApprove: true,
+	}
+}
+
+// NewTierSettings creates the TierSettings from the comma-separated limits, e.g. 100/1s,10000/1h
+func NewTierSettings(limitsCSV string, visible, autoApprove bool) (*TierSettings, error) {
+	limits, err := parseTierLimits(limitsCSV)
+	if err != nil {
+		return nil, err
+	}
+	return &TierSettings{
+		Limits:      limits,
+		Visible:     visible,
+		AutoApprove: autoApprove,
+	}, nil
+}
+
+func parseTierLimits(limitsCSV string) ([]TierLimit, error) {
+	limits := []TierLimit{}
+	for _, limit := range strings.Split(limitsCSV, ",") {
+		limit = strings.TrimSpace(limit)
+		if limit == "" {
+			continue
+		}
+		requests, period, ok := strings.Cut(limit, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid SLA tier limit '%s', expected maximum requests/time period", limit)
+		}
+		maxRequests, err := strconv.Atoi(strings.TrimSpace(requests))
+		if err != nil || maxRequests <= 0 {
+			return nil, fmt.Errorf("invalid maximum requests in the SLA tier limit '%s'", limit)
+		}
+		timePeriod, err := time.ParseDuration(strings.TrimSpace(period))
+		if err != nil || timePeriod < time.Millisecond {
+			return nil, fmt.Errorf("invalid time period in the SLA tier limit '%s'", limit)
+		}
+		limits = append(limits, TierLimit{MaximumRequests: maxRequests, TimePeriod: timePeriod})
+	}
+	if len(limits) == 0 {
+		return nil, fmt.Errorf("the SLA tier requires at least one limit")
+	}
+	return limits, nil
+}
+
+// forAPI returns the settings overridden by the slaTier tags of an API
+func (s *TierSettings) forAPI(tags []string) (*TierSettings, error) {
+	settings := &TierSettings{
+		Limits:      s.Limits,
+		Visible:     s.Visible,
+		AutoApprove: s.AutoApprove,
+	}
+
+	var err error
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case strings.HasPrefix(tag, tierLimitsTag):
+			settings.Limits, err = parseTierLimits(strings.TrimPrefix(tag, tierLimitsTag))
+		case strings.HasPrefix(tag, tierVisibleTag):
+			settings.Visible, err = strconv.ParseBool(strings.TrimSpace(strings.TrimPrefix(tag, tierVisibleTag)))
+		case strings.HasPrefix(tag, tierAutoApproveTag):
+			settings.AutoApprove, err = strconv.ParseBool(strings.TrimSpace(strings.TrimPrefix(tag, tierAutoApproveTag)))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag '%s': %s", tag, err)
+		}
+	}
+	return settings, nil
+}
+
+// slaTier builds the agent SLA tier with the settings
+func (s *TierSettings) slaTier() *anypoint.SLATier {
+	limits := make([]anypoint.Limits, 0, len(s.Limits))
+	for _, limit := range s.Limits {
+		limits = append(limits, anypoint.Limits{
+			MaximumRequests:          limit.MaximumRequests,
+			TimePeriodInMilliseconds: int(limit.TimePeriod.Milliseconds()),
+			Visible:                  s.Visible,
+		})
+	}
+	return &anypoint.SLATier{
+		Name:        common.AxwayAgentSLATierName,
+		AutoApprove: s.AutoApprove,
+		Description: anypoint.ToPointer[string](common.AxwayAgentSLATierDescription),
+		Limits:      limits,
+		Status:      common.SLAActive,
+	}
+}
+
+// matches returns true when an existing tier has the limits and the approval mode of the settings
+func (s *TierSettings) matches(tier anypoint.SLATier) bool {
+	expected := s.slaTier()
+	if tier.AutoApprove != expected.AutoApprove || len(tier.Limits) != len(expected.Limits) {
+		return false
+	}
+	for i, limit := range expected.Limits {
+		existing := tier.Limits[i]
+		if maximumRequests(existing.MaximumRequests) != limit.MaximumRequests ||
+			existing.TimePeriodInMilliseconds != limit.TimePeriodInMilliseconds ||
+			existing.Visible != limit.Visible {
+			return false
+		}
+	}
+	return true
+}
+
+// hash identifies the limits and the approval mode of the settings
+func (s *TierSettings) hash() string {
+	limits := make([]string, 0, len(s.Limits))
+	for _, limit := range s.Limits {
+		limits = append(limits, fmt.Sprintf("%d/%s", limit.MaximumRequests, limit.TimePeriod))
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%t|%t", strings.Join(limits, ","), s.Visible, s.AutoApprove)))
+	return hex.EncodeToString(sum[:])
+}
+
+// maximumRequests reads the maximum requests of a limit, decoded as a float64 from the Mulesoft response
+func maximumRequests(value interface{}) interface{} {
+	if f, ok := value.(float64); ok {
+		return int(f)
+	}
+	return value
+}
+
+// instanceLister is the part of the SDK cache manager with the discovered instances
+type instanceLister interface {
+	ListAPIServiceInstances() []*v1.ResourceInstance
+}
+
+// TierSyncJob updates the agent SLA tiers of the discovered APIs to the configured settings, so that tiers created
+// before a change of the settings are updated without waiting for a new access request. Each API is synced again when
+// its settings change, e.g. when its slaTier tags are changed, the APIs discovered later are synced on the next
+// interval.
+type TierSyncJob struct {
+	client MuleSubscriptionClient
+	cache  instanceLister
+	// synced are the hashes of the settings the tiers were synced with, by api id
+	synced map[string]string
+	log    logrus.FieldLogger
+}
+
+// NewTierSyncJob creates a TierSyncJob
+func NewTierSyncJob(client MuleSubscriptionClient, cache instanceLister, log logrus.FieldLogger) *TierSyncJob {
+	return &TierSyncJob{
+		client: client,
+		cache:  cache,
+		synced: map[string]string{},
+		log:    log.WithField("component", "slaTierSync"),
+	}
+}
+
+// Execute syncs the SLA tiers of the APIs that were not synced yet with their current settings
+func (j *TierSyncJob) Execute() error {
+	for _, ri := range j.cache.ListAPIServiceInstances() {
+		apiID := util.GetAgentDetailStrings(ri)[common.AttrAPIID]
+		if apiID == "" {
+			continue
+		}
+		logger := j.log.WithField("api", apiID)
+		settings, err := j.client.GetSLATierSettings(apiID)
+		if err != nil {
+			logger.WithError(err).Warn("failed to read the SLA tier settings of the api, retrying on the next interval")
+			continue
+		}
+		hash := settings.hash()
+		if j.synced[apiID] == hash {
+			continue
+		}
+		if err := j.client.UpdateSLATier(apiID); err != nil {
+			logger.WithError(err).Warn("failed to sync the SLA tier of the api, retrying on the next interval")
+			continue
+		}
+		j.synced[apiID] = hash
+	}
+	return nil
+}
+
+// Status returns nil, a failure to sync a tier is retried on the next run
+func (j *TierSyncJob) Status() error {
+	return nil
+}
+
+// Ready returns true
+func (j *TierSyncJob) Ready() bool {
+	return true
+}
@@ -0,0 +1,169 @@
+package subscription
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTierSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   string
+		expected []TierLimit
+		hasErr   bool
+	}{
+		{
+			name:     "should parse a single limit",
+			limits:   "10/1s",
+			expected: []TierLimit{{MaximumRequests: 10, TimePeriod: time.Second}},
+		},
+		{
+			name:   "should parse multiple limits",
+			limits: " 100/1s, 10000/1h ,",
+			expected: []TierLimit{
+				{MaximumRequests: 100, TimePeriod: time.Second},
+				{MaximumRequests: 10000, TimePeriod: time.Hour},
+			},
+		},
+		{
+			name:   "should return an error without limits",
+			limits: "",
+			hasErr: true,
+		},
+		{
+			name:   "should return an error for a limit without a time period",
+			limits: "100",
+			hasErr: true,
+		},
+		{
+			name:   "should return an error for invalid maximum requests",
+			limits: "0/1s",
+			hasErr: true,
+		},
+		{
+			name:   "should return an error for an invalid time period",
+			limits: "100/1",
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := NewTierSettings(tc.limits, false, true)
+			if tc.hasErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, settings.Limits)
+			assert.False(t, settings.Visible)
+			assert.True(t, settings.AutoApprove)
+		})
+	}
+}
+
+func TestTierSettingsForAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected *TierSettings
+		hasErr   bool
+	}{
+		{
+			name:     "should keep the settings without slaTier tags",
+			tags:     []string{"tag1", "tag2"},
+			expected: DefaultTierSettings(),
+		},
+		{
+			name: "should override the settings with the slaTier tags",
+			tags: []string{"tag1", "slaTier.limits:50/1s,1000/1m", "slaTier.visible:false", " slaTier.autoApprove: false"},
+			expected: &TierSettings{
+				Limits: []TierLimit{
+					{MaximumRequests: 50, TimePeriod: time.Second},
+					{MaximumRequests: 1000, TimePeriod: time.Minute},
+				},
+				Visible:     false,
+				AutoApprove: false,
+			},
+		},
+		{
+			name:   "should return an error for invalid limits",
+			tags:   []string{"slaTier.limits:fast"},
+			hasErr: true,
+		},
+		{
+			name:   "should return an error for an invalid approval mode",
+			tags:   []string{"slaTier.autoApprove:maybe"},
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := DefaultTierSettings().forAPI(tc.tags)
+			if tc.hasErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, settings)
+		})
+	}
+}
+
+func TestTierSettingsMatches(t *testing.T) {
+	settings, err := NewTierSettings("100/1s,10000/1h", true, false)
+	assert.Nil(t, err)
+
+	// the limits of a tier read from Mulesoft are decoded as float64
+	existing := anypoint.SLATier{
+		AutoApprove: false,
+		Limits: []anypoint.Limits{
+			{MaximumRequests: float64(100), TimePeriodInMilliseconds: 1000, Visible: true},
+			{MaximumRequests: float64(10000), TimePeriodInMilliseconds: 3600000, Visible: true},
+		},
+	}
+	assert.True(t, settings.matches(existing))
+
+	existing.AutoApprove = true
+	assert.False(t, settings.matches(existing))
+	existing.AutoApprove = false
+
+	existing.Limits[1].MaximumRequests = float64(5000)
+	assert.False(t, settings.matches(existing))
+
+	existing.Limits = existing.Limits[:1]
+	assert.False(t, settings.matches(existing))
+}
+
+func TestTierSyncJob(t *testing.T) {
+	cache := newImportCache(t, false, false)
+	client := &MockMuleSubscriptionClient{}
+	job := NewTierSyncJob(client, cache, logrus.StandardLogger())
+
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, []string{"111"}, client.updatedTiers)
+
+	// a synced api is not synced again
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, []string{"111"}, client.updatedTiers)
+
+	// an api is synced again when its settings change
+	client.tierSettings = &TierSettings{Limits: []TierLimit{{MaximumRequests: 100, TimePeriod: time.Hour}}, Visible: true}
+	assert.Nil(t, job.Execute())
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, []string{"111", "111"}, client.updatedTiers)
+
+	// a failure is retried on the next run
+	client.updatedTiers = nil
+	client.tierErr = fmt.Errorf("err")
+	job = NewTierSyncJob(client, cache, logrus.StandardLogger())
+	assert.Nil(t, job.Execute())
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, []string{"111", "111"}, client.updatedTiers)
+}
@@ -0,0 +1,52 @@
+package subscription
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// compensation undoes a provisioning step that completed
+type compensation struct {
+	step string
+	undo func() error
+}
+
+// transaction records the Mulesoft resources created while provisioning a request, so that they are removed again
+// when a later step fails and the request is not left half provisioned.
+type transaction struct {
+	log           logrus.FieldLogger
+	compensations []compensation
+}
+
+func newTransaction(log logrus.FieldLogger) *transaction {
+	return &transaction{log: log}
+}
+
+// record adds the compensation of a completed step
+func (t *transaction) record(step string, undo func() error) {
+	t.compensations = append(t.compensations, compensation{step: step, undo: undo})
+}
+
+// rollback runs the compensations in the reverse order of the steps. It returns an error listing the steps that could
+// not be undone, these resources have to be removed in Mulesoft.
+func (t *transaction) rollback() error {
+	failed := []string{}
+	for i := len(t.compensations) - 1; i >= 0; i-- {
+		c := t.compensations[i]
+		logger := t.log.WithField("step", c.step)
+		if err := c.undo(); err != nil {
+			logger.WithError(err).Error("failed to roll back the provisioning step")
+			failed = append(failed, fmt.Sprintf("%s: %s", c.step, err))
+			continue
+		}
+		logger.Info("rolled back the provisioning step")
+	}
+	t.compensations = nil
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to roll back %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package traceability
 import (
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/Axway/agent-sdk/pkg/agent"
@@ -24,6 +25,23 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 )
 
+const (
+	// policyViolationUnit - the custom unit counting the requests of a client blocked by a policy of an API
+	policyViolationUnit = "policyViolations"
+	// methodUnitSuffix - the suffix of the custom units counting the requests of a client by HTTP method, getRequests
+	methodUnitSuffix = "Requests"
+	// requestBytesUnit - the custom unit counting the bytes of the requests of a client
+	requestBytesUnit = "requestBytes"
+	// responseBytesUnit - the custom unit counting the bytes of the responses to a client
+	responseBytesUnit = "responseBytes"
+)
+
+// metricCollector - collects the transactions and the custom units of the APIs
+type metricCollector interface {
+	AddAPIMetricDetail(detail metric.MetricDetail)
+	AddCustomMetricDetail(detail models.CustomMetricDetail)
+}
+
 // Agent - mulesoft Beater configuration. Implements the beat.Beater interface.
 type Agent struct {
 	logger          log.FieldLogger
@@ -33,7 +51,14 @@ type Agent struct {
 	mule            Emitter
 	credentialCache cache.Cache
 	eventGenerator  transaction.EventGenerator
-	eventReport     transaction.EventReport
+	trafficReader   *TrafficLogReader
+	trafficChannel  chan GwTrafficLogEntry
+	instanceCache   instanceCache
+	instances       *instanceIndex
+	collector       metricCollector
+	clientApps      *clientAppResolver
+	backfill        *BackfillOptions
+	backfiller      backfiller
 }
 
 // NewBeater creates an instance of mulesoft_traceability_agent.
@@ -46,7 +71,7 @@ func NewBeater(_ *beat.Beat, _ *common.Config) (beat.Beater, error) {
 	client := anypoint.NewClient(agentConfig.MulesoftConfig)
 	emitter := NewMuleEventEmitter(agentConfig.MulesoftConfig, eventChannel, client, agent.GetCacheManager())
 
-	emitterJob, err := NewMuleEventEmitterJob(emitter, pollInterval, traceabilityHealthCheck, hc.GetStatus, hc.RegisterHealthcheck)
+	emitterJob, err := NewMuleEventEmitterJob(emitter, pollInterval, emitter.healthCheck, hc.GetStatus, hc.RegisterHealthcheck)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +80,18 @@ func NewBeater(_ *beat.Beat, _ *common.Config) (beat.Beater, error) {
 	credentialHandler := NewCredentialHandler(credentialCache, agent.GetCacheManager())
 	agent.RegisterResourceEventHandler(management.CredentialGVK().Kind, credentialHandler)
 
-	return newAgent(emitterJob, eventChannel, credentialCache)
+	a, err := newAgent(emitterJob, eventChannel, credentialCache)
+	if err != nil {
+		return nil, err
+	}
+	a.withClientApps(client)
+	if backfillOptions != nil {
+		a.withBackfill(emitter, backfillOptions)
+	}
+	if path := agentConfig.MulesoftConfig.TrafficLogPath; path != "" {
+		a.withTrafficLogs(path, agent.GetCacheManager())
+	}
+	return a, nil
 }
 
 func newAgent(
@@ -72,19 +108,15 @@ func newAgent(
 		eventGenerator:  transaction.NewEventGenerator(),
 	}
 
-	eventReport, err := transaction.NewEventReportBuilder().SetOnlyTrackMetrics(true).Build()
-	if err != nil {
-		a.logger.WithError(err).Error("failed to create event report")
-		return nil, err
-	}
-	a.eventReport = eventReport
-
 	return a, nil
 }
 
 // Run starts the Mulesoft traceability agent.
 func (a *Agent) Run(b *beat.Beat) error {
 	coreagent.OnConfigChange(a.onConfigChange)
+	if a.backfill != nil {
+		return a.runBackfill(b)
+	}
 
 	var err error
 	a.client, err = b.Publisher.Connect()
@@ -94,6 +126,9 @@ func (a *Agent) Run(b *beat.Beat) error {
 	}
 
 	go a.mule.Start()
+	if a.trafficReader != nil {
+		go a.readTrafficLogs()
+	}
 
 	gracefulStop := make(chan os.Signal, 1)
 	signal.Notify(gracefulStop, syscall.SIGTERM, os.Interrupt)
@@ -101,15 +136,24 @@ func (a *Agent) Run(b *beat.Beat) error {
 	for {
 		select {
 		case <-a.doneCh:
-			return a.client.Close()
+			return a.close()
 		case <-gracefulStop:
-			return a.client.Close()
+			return a.close()
 		case event := <-a.eventChannel:
 			a.processEvent(event)
+		case entry := <-a.trafficChannel:
+			a.processTrafficLogEntry(entry)
 		}
 	}
 }
 
+func (a *Agent) close() error {
+	if a.trafficReader != nil {
+		a.trafficReader.Stop()
+	}
+	return a.client.Close()
+}
+
 func (a *Agent) processEvent(me cmn.MetricEvent) {
 	switch me.Type {
 	case cmn.Initialize:
@@ -117,66 +161,165 @@ func (a *Agent) processEvent(me cmn.MetricEvent) {
 	case cmn.Metric:
 		a.processMetricEvent(me.Metric)
 	case cmn.Completed:
-		err := a.eventGenerator.AddMetricDetailsFromEventReport(a.eventReport)
-		if err != nil {
-			a.logger.WithError(err).Error("failed to add metrics to collector")
-		}
+		a.logger.Debug("completed metrics processing")
 	}
 }
 
+// processMetricEvent adds the metrics to the collector as they are received. Anypoint Monitoring only gives the
+// average, min and max response times of the requests, they are reported as is with the observation window of the
+// metrics. The sdk does not count the sizes of these transactions, they are reported as custom units.
 func (a *Agent) processMetricEvent(m cmn.Metrics) {
 	if m.Instance == nil {
 		return
 	}
+	collector := a.getCollector()
+	if collector == nil {
+		a.logger.Warn("no metric collector, skipping the metrics")
+		return
+	}
 
-	a.eventReport.AddMetricDetail(metric.MetricDetail{
-		APIDetails: a.getAPIDetails(m),
-		AppDetails: a.getAppDetails(m),
+	apiDetails := a.getAPIDetails(m.Instance)
+	appDetails := a.getAppDetails(m.ClientID)
+	collector.AddAPIMetricDetail(metric.MetricDetail{
+		APIDetails: apiDetails,
+		AppDetails: appDetails,
 		StatusCode: m.StatusCode,
 		Count:      m.Count,
 		Response: metric.ResponseMetrics{
 			Max: m.Max,
 			Min: m.Min,
+			Avg: m.Avg,
 		},
-		Observation: models.ObservationDetails{
-			Start: m.StartTime.UnixMilli(),
-			End:   m.EndTime.UnixMilli(),
-		},
+		Observation: observation(m),
 	})
+	a.addCustomUnits(collector, apiDetails, appDetails, m)
+}
+
+// addCustomUnits adds the custom units of the requests of a client to the collector
+func (a *Agent) addCustomUnits(collector metricCollector, apiDetails models.APIDetails, appDetails models.AppDetails, m cmn.Metrics) {
+	for unit, count := range a.customUnits(apiDetails, appDetails, m) {
+		collector.AddCustomMetricDetail(models.CustomMetricDetail{
+			APIDetails:  apiDetails,
+			AppDetails:  appDetails,
+			UnitDetails: models.Unit{Name: unit},
+			Count:       count,
+			Observation: observation(m),
+		})
+	}
+}
+
+// customUnits counts the requests of a client by HTTP method, the requests blocked by a policy, and the bytes of the
+// requests and responses as custom units. The sdk only reports the custom units of a managed application, the requests
+// of an unknown client or of a Mulesoft application without a managed application are counted as transactions only.
+func (a *Agent) customUnits(apiDetails models.APIDetails, appDetails models.AppDetails, m cmn.Metrics) map[string]int64 {
+	if m.Count == 0 {
+		return nil
+	}
+	if appDetails.ID == "" {
+		a.logger.
+			WithField("apiID", apiDetails.ID).
+			WithField("clientID", m.ClientID).
+			Trace("skipping the custom units of the requests without an application")
+		return nil
+	}
+	if isUnmanagedApp(appDetails) {
+		a.logger.
+			WithField("apiID", apiDetails.ID).
+			WithField("appName", appDetails.Name).
+			Trace("skipping the custom units of the requests of a Mulesoft application without a managed application")
+		return nil
+	}
+
+	units := map[string]int64{}
+	if m.RequestBytes > 0 {
+		units[requestBytesUnit] = m.RequestBytes
+	}
+	if m.ResponseBytes > 0 {
+		units[responseBytesUnit] = m.ResponseBytes
+	}
+	if m.Method != "" {
+		units[methodUnit(m.Method)] = m.Count
+	}
+	if m.RequestDisposition == anypoint.DispositionBlocked {
+		units[policyViolationUnit] = m.Count
+	}
+	return units
 }
 
-func (a *Agent) getAPIDetails(m cmn.Metrics) models.APIDetails {
-	apisRef := m.Instance.GetReferenceByGVK(management.APIServiceGVK())
-	externalAPIID, _ := coreutil.GetAgentDetailsValue(m.Instance, definitions.AttrExternalAPIID)
-	stage, _ := coreutil.GetAgentDetailsValue(m.Instance, definitions.AttrExternalAPIStage)
+func observation(m cmn.Metrics) models.ObservationDetails {
+	return models.ObservationDetails{
+		Start: m.StartTime.UnixMilli(),
+		End:   m.EndTime.UnixMilli(),
+	}
+}
+
+func (a *Agent) getCollector() metricCollector {
+	if a.collector != nil {
+		return a.collector
+	}
+	if collector := metric.GetMetricCollector(); collector != nil {
+		return collector
+	}
+	return nil
+}
+
+// methodUnit returns the custom unit of the requests of an HTTP method
+func methodUnit(method string) string {
+	return strings.ToLower(method) + methodUnitSuffix
+}
+
+func (a *Agent) getAPIDetails(instance *v1.ResourceInstance) models.APIDetails {
+	apisRef := instance.GetReferenceByGVK(management.APIServiceGVK())
+	externalAPIID, _ := coreutil.GetAgentDetailsValue(instance, definitions.AttrExternalAPIID)
+	stage, _ := coreutil.GetAgentDetailsValue(instance, definitions.AttrExternalAPIStage)
 	return models.APIDetails{
 		ID:                 externalAPIID,
 		Name:               apisRef.Name,
 		Revision:           1,
-		APIServiceInstance: m.Instance.Name,
+		APIServiceInstance: instance.Name,
 		Stage:              stage,
 	}
 }
 
-func (a *Agent) getAppDetails(me cmn.Metrics) models.AppDetails {
-	appDetails := models.AppDetails{}
-	if item, err := a.credentialCache.Get(me.ClientID); err == nil && item != nil {
-		ri, ok := item.(*v1.ResourceInstance)
-		if ok && ri != nil {
-			appRef := ri.GetReferenceByGVK(management.ManagedApplicationGVK())
-			app := agent.GetCacheManager().GetManagedApplicationByName(appRef.Name)
-			if app != nil {
-				managedApp := &management.ManagedApplication{}
-				managedApp.FromInstance(app)
-				appDetails = models.AppDetails{
-					ID:            managedApp.Metadata.ID,
-					Name:          managedApp.Name,
-					ConsumerOrgID: managedApp.Marketplace.Resource.Owner.Organization.ID,
-				}
-			}
+// getAppDetails returns the managed application of the credential of a client id in Central, or else the client
+// application created in Mulesoft as an unmanaged application
+func (a *Agent) getAppDetails(clientID string) models.AppDetails {
+	if appDetails, ok := a.getManagedAppDetails(clientID); ok {
+		return appDetails
+	}
+	if a.clientApps != nil && clientID != "" {
+		if appDetails, ok := a.clientApps.getAppDetails(clientID); ok {
+			return appDetails
 		}
 	}
-	return appDetails
+	return models.AppDetails{}
+}
+
+func (a *Agent) getManagedAppDetails(clientID string) (models.AppDetails, bool) {
+	item, err := a.credentialCache.Get(clientID)
+	if err != nil || item == nil {
+		return models.AppDetails{}, false
+	}
+	ri, ok := item.(*v1.ResourceInstance)
+	if !ok || ri == nil {
+		return models.AppDetails{}, false
+	}
+	appRef := ri.GetReferenceByGVK(management.ManagedApplicationGVK())
+	app := agent.GetCacheManager().GetManagedApplicationByName(appRef.Name)
+	if app == nil {
+		return models.AppDetails{}, false
+	}
+
+	managedApp := &management.ManagedApplication{}
+	managedApp.FromInstance(app)
+	appDetails := models.AppDetails{
+		ID:   managedApp.Metadata.ID,
+		Name: managedApp.Name,
+	}
+	if owner := managedApp.Marketplace.Resource.Owner; owner != nil {
+		appDetails.ConsumerOrgID = owner.Organization.ID
+	}
+	return appDetails, true
 }
 
 // onConfigChange apply configuration changes
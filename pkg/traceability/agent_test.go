@@ -7,6 +7,8 @@ import (
 	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
 	cache "github.com/Axway/agent-sdk/pkg/cache"
 	corecfg "github.com/Axway/agent-sdk/pkg/config"
+	"github.com/Axway/agent-sdk/pkg/transaction/metric"
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
 	"github.com/Axway/agent-sdk/pkg/util"
 
 	"github.com/Axway/agents-mulesoft/pkg/anypoint"
@@ -36,6 +38,7 @@ func TestAgent_Run(t *testing.T) {
 	}
 	client := &mockAnalyticsClient{
 		events: []anypoint.APIMonitoringMetric{event},
+		files:  []anypoint.DataFile{{ID: "444-111-222.log"}},
 		err:    nil,
 	}
 	instanceCache := &mockInstaceCache{}
@@ -45,7 +48,7 @@ func TestAgent_Run(t *testing.T) {
 	svcInst.Metadata.ID = "1234"
 	ri, _ := svcInst.AsInstance()
 	instanceCache.AddAPIServiceInstance(ri)
-	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: "/tmp", UseMonitoringAPI: true}, eventChannel, client, instanceCache)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventChannel, client, instanceCache)
 	credCache := cache.New()
 	traceAgent, err := newAgent(emitter, eventChannel, credCache)
 
@@ -75,6 +78,7 @@ func TestAgent_Run(t *testing.T) {
 
 type mockAnalyticsClient struct {
 	events []anypoint.APIMonitoringMetric
+	files  []anypoint.DataFile
 	app    *anypoint.Application
 	err    error
 }
@@ -87,7 +91,11 @@ func (m mockAnalyticsClient) GetMonitoringMetrics(dataSourceName string, dataSou
 	return m.events, m.err
 }
 
-func (m mockAnalyticsClient) GetMonitoringArchive(apiID string, startDate time.Time) ([]anypoint.APIMonitoringMetric, error) {
+func (m mockAnalyticsClient) ListMonitoringArchiveFiles(apiID string, day time.Time) ([]anypoint.DataFile, error) {
+	return m.files, m.err
+}
+
+func (m mockAnalyticsClient) GetMonitoringArchiveFile(apiID string, day time.Time, fileID string) ([]anypoint.APIMonitoringMetric, error) {
 	return m.events, m.err
 }
 
@@ -101,3 +109,123 @@ func (m mockAnalyticsClient) OnConfigChange(_ *config.MulesoftConfig) {
 func (m mockAnalyticsClient) GetAPI(_ string) (*anypoint.API, error) {
 	return nil, nil
 }
+
+func TestProcessMetricEvent(t *testing.T) {
+	svcInst := management.NewAPIServiceInstance("api", "env")
+	ri, _ := svcInst.AsInstance()
+	traceAgent, err := newAgent(nil, nil, cache.New())
+	assert.Nil(t, err)
+	collector := &mockMetricCollector{}
+	traceAgent.collector = collector
+
+	traceAgent.processMetricEvent(common.Metrics{
+		Instance:      ri,
+		StatusCode:    "200",
+		StartTime:     time.UnixMilli(1000),
+		EndTime:       time.UnixMilli(2000),
+		Count:         3,
+		Max:           40,
+		Min:           10,
+		Avg:           20.5,
+		StdDev:        5,
+		RequestBytes:  100,
+		ResponseBytes: 201,
+	})
+
+	// the metrics are added as they are received, with their response times and observation window
+	assert.Len(t, collector.metrics, 1)
+	detail := collector.metrics[0]
+	assert.Equal(t, "200", detail.StatusCode)
+	assert.Equal(t, "api", detail.APIDetails.APIServiceInstance)
+	assert.Equal(t, int64(3), detail.Count)
+	assert.Equal(t, metric.ResponseMetrics{Max: 40, Min: 10, Avg: 20.5}, detail.Response)
+	assert.Equal(t, models.ObservationDetails{Start: 1000, End: 2000}, detail.Observation)
+
+	// metrics without an instance are not reported
+	traceAgent.processMetricEvent(common.Metrics{Count: 1})
+	assert.Len(t, collector.metrics, 1)
+}
+
+type mockMetricCollector struct {
+	metrics []metric.MetricDetail
+	details []models.CustomMetricDetail
+}
+
+func (m *mockMetricCollector) AddAPIMetricDetail(detail metric.MetricDetail) {
+	m.metrics = append(m.metrics, detail)
+}
+
+func (m *mockMetricCollector) AddCustomMetricDetail(detail models.CustomMetricDetail) {
+	m.details = append(m.details, detail)
+}
+
+func TestAddCustomUnits(t *testing.T) {
+	apiDetails := models.APIDetails{ID: "api"}
+	appDetails := models.AppDetails{ID: "app", Name: "app"}
+	startTime := time.UnixMilli(1000)
+	endTime := time.UnixMilli(2000)
+
+	tests := []struct {
+		name       string
+		appDetails models.AppDetails
+		metrics    common.Metrics
+		expected   map[string]int64
+	}{
+		{
+			name:       "should count the requests by method",
+			appDetails: appDetails,
+			metrics:    common.Metrics{Method: "GET", RequestDisposition: "processed", Count: 3},
+			expected:   map[string]int64{"getRequests": 3},
+		},
+		{
+			name:       "should count the requests blocked by a policy",
+			appDetails: appDetails,
+			metrics:    common.Metrics{Method: "POST", RequestDisposition: anypoint.DispositionBlocked, Count: 2},
+			expected:   map[string]int64{"postRequests": 2, policyViolationUnit: 2},
+		},
+		{
+			name:       "should count the bytes of the requests and responses",
+			appDetails: appDetails,
+			metrics:    common.Metrics{Count: 2, RequestBytes: 100, ResponseBytes: 250},
+			expected:   map[string]int64{requestBytesUnit: 100, responseBytesUnit: 250},
+		},
+		{
+			name:       "should not count the requests of a Mulesoft application without a managed application",
+			appDetails: models.AppDetails{ID: unmanagedAppIDPrefix + "10", Name: "mule-app"},
+			metrics:    common.Metrics{Method: "GET", RequestDisposition: anypoint.DispositionBlocked, Count: 2},
+			expected:   map[string]int64{},
+		},
+		{
+			name:       "should not count the requests without an application",
+			appDetails: models.AppDetails{},
+			metrics:    common.Metrics{Method: "GET", RequestDisposition: anypoint.DispositionBlocked, Count: 2},
+			expected:   map[string]int64{},
+		},
+		{
+			name:       "should not count metrics without requests",
+			appDetails: appDetails,
+			metrics:    common.Metrics{Method: "GET", RequestDisposition: anypoint.DispositionBlocked},
+			expected:   map[string]int64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := &mockMetricCollector{}
+			traceAgent, err := newAgent(nil, nil, cache.New())
+			assert.Nil(t, err)
+			tc.metrics.StartTime = startTime
+			tc.metrics.EndTime = endTime
+			traceAgent.addCustomUnits(collector, apiDetails, tc.appDetails, tc.metrics)
+
+			units := map[string]int64{}
+			for _, detail := range collector.details {
+				assert.Equal(t, apiDetails, detail.APIDetails)
+				assert.Equal(t, tc.appDetails, detail.AppDetails)
+				assert.Equal(t, models.ObservationDetails{Start: 1000, End: 2000}, detail.Observation)
+				units[detail.UnitDetails.Name] += detail.Count
+			}
+			assert.Equal(t, tc.expected, units)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package traceability
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/agent"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	catalog "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/catalog/v1alpha1"
+	corecmd "github.com/Axway/agent-sdk/pkg/cmd"
+	"github.com/Axway/agent-sdk/pkg/transaction/metric"
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
+	transutil "github.com/Axway/agent-sdk/pkg/transaction/util"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	cmn "github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+const (
+	// metricEventName is the name of the metric events of the sdk metric collector, which does not export it
+	metricEventName = "api.transaction.status.metric"
+	// backfillWaitClose is the time the backfill waits for the metric events to be published before it stops
+	backfillWaitClose = 5 * time.Minute
+)
+
+// BackfillOptions - the time range of the metrics reported by the backfill command, and the asset or API id of the
+// API to report, all the APIs when empty. The range is clamped to the metrics not reported yet by the agent, unless
+// Force is set.
+type BackfillOptions struct {
+	From  time.Time
+	To    time.Time
+	APIID string
+	Force bool
+}
+
+var backfillOptions *BackfillOptions
+
+// SetBackfill makes the agent report the metrics of the monitoring archive for a time range and stop, instead of
+// collecting the new metrics on each interval
+func SetBackfill(opts *BackfillOptions) {
+	backfillOptions = opts
+}
+
+// IsBackfill returns true when the agent runs the backfill command
+func IsBackfill() bool {
+	return backfillOptions != nil
+}
+
+type backfiller interface {
+	Backfill(opts BackfillOptions) error
+}
+
+// withBackfill reports the metrics of a time range with the emitter
+func (a *Agent) withBackfill(emitter backfiller, opts *BackfillOptions) {
+	a.backfiller = emitter
+	a.backfill = opts
+}
+
+// runBackfill reports the metrics of the backfill time range and returns once they are published. The sdk metric
+// collector reports the transactions for its own collection interval, and drops the metrics added with their own
+// observation window before its first run, so the metric events of the archive metrics are built with the sdk central
+// metric builder and published directly.
+func (a *Agent) runBackfill(b *beat.Beat) error {
+	var err error
+	a.client, err = b.Publisher.ConnectWith(beat.ClientConfig{
+		PublishMode: beat.GuaranteedSend,
+		WaitClose:   backfillWaitClose,
+		ACKHandler: acker.Counting(func(n int) {
+			a.logger.WithField("count", n).Debug("published backfill metrics")
+		}),
+	})
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	a.logger.
+		WithField("from", a.backfill.From).
+		WithField("to", a.backfill.To).
+		WithField("apiID", a.backfill.APIID).
+		WithField("force", a.backfill.Force).
+		Info("starting the backfill of the metrics")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- a.backfiller.Backfill(*a.backfill)
+	}()
+
+	orgGUID := getOrgGUID()
+	for {
+		select {
+		case event := <-a.eventChannel:
+			if event.Type == cmn.Metric {
+				a.publishBackfillMetric(event.Metric, orgGUID)
+			}
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+func (a *Agent) publishBackfillMetric(m cmn.Metrics, orgGUID string) {
+	if m.Instance == nil || m.Count == 0 {
+		return
+	}
+	events, err := a.createBackfillMetricEvents(m, orgGUID)
+	if err != nil {
+		a.logger.WithError(err).WithField("apiID", m.APIID).Error("failed to create the backfill metric events")
+		return
+	}
+	a.client.PublishAll(events)
+}
+
+// createBackfillMetricEvents builds the metric events of the transactions of an API and client for the observation
+// window of the metrics, and of their custom units. As the sdk metric collector does, the events of a managed
+// application reference its application and the subscription of its access request to the API.
+func (a *Agent) createBackfillMetricEvents(m cmn.Metrics, orgGUID string) ([]beat.Event, error) {
+	apiDetails := a.getAPIDetails(m.Instance)
+	appDetails := a.getAppDetails(m.ClientID)
+	app, subscription := backfillAppReferences(apiDetails, appDetails)
+
+	units := []*metric.Units{{
+		Transactions: &metric.Transactions{
+			UnitCount: metric.UnitCount{Count: m.Count},
+			Response: &metric.ResponseMetrics{
+				Max: m.Max,
+				Min: m.Min,
+				Avg: m.Avg,
+			},
+			Status: metric.GetStatusText(m.StatusCode),
+		},
+	}}
+	for unit, count := range a.customUnits(apiDetails, appDetails, m) {
+		units = append(units, &metric.Units{
+			CustomUnits: map[string]*metric.UnitCount{unit: {Count: count}},
+		})
+	}
+
+	events := make([]beat.Event, 0, len(units))
+	for _, u := range units {
+		builder := metric.NewCentralMetricBuilder().
+			SetObservation(&models.ObservationDetails{Start: m.StartTime.UnixMilli(), End: m.EndTime.UnixMilli()}).
+			SetAPI(backfillAPIReference(apiDetails)).
+			SetApp(app).
+			SetSubscription(subscription).
+			SetUnits(u)
+		event, err := createBackfillMetricEvent(builder, orgGUID)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// createBackfillMetricEvent wraps a central metric in the metric event the sdk metric collector publishes. The sdk does
+// not export a way to publish a metric with its own observation window, so only the v4 event envelope is set here, the
+// metric and the beat event are built by the sdk.
+func createBackfillMetricEvent(builder *metric.CentralMetricBuilder, orgGUID string) (beat.Event, error) {
+	builder.SetEventID(uuid.NewString())
+	builder.Reporter = &metric.Reporter{
+		AgentVersion:     corecmd.BuildVersion,
+		AgentType:        corecmd.BuildAgentName,
+		AgentSDKVersion:  corecmd.SDKBuildVersion,
+		AgentName:        agent.GetCentralConfig().GetAgentName(),
+		ObservationDelta: builder.Observation.End - builder.Observation.Start,
+	}
+	data := builder.Build()
+
+	message, err := json.Marshal(metric.V4Event{
+		ID:        data.GetEventID(),
+		Timestamp: builder.Observation.Start,
+		Event:     metricEventName,
+		App:       orgGUID,
+		Version:   "4",
+		Distribution: &metric.V4EventDistribution{
+			Environment: agent.GetCentralConfig().GetEnvironmentID(),
+			Version:     "1",
+		},
+		Data: data,
+	})
+	if err != nil {
+		return beat.Event{}, err
+	}
+
+	condorEvent := &metric.CondorMetricEvent{
+		Message:   string(message),
+		Fields:    map[string]interface{}{},
+		Timestamp: data.GetStartTime(),
+		ID:        data.GetEventID(),
+	}
+	event, err := condorEvent.CreateEvent()
+	if err != nil {
+		return beat.Event{}, err
+	}
+	return event.Content, nil
+}
+
+func backfillAPIReference(apiDetails models.APIDetails) *models.APIResourceReference {
+	ref := &models.APIResourceReference{
+		ResourceReference: models.ResourceReference{ID: apiDetails.ID},
+		Name:              apiDetails.Name,
+	}
+	svc := agent.GetCacheManager().GetAPIServiceWithAPIID(strings.TrimPrefix(apiDetails.ID, transutil.SummaryEventProxyIDPrefix))
+	if svc != nil {
+		ref.APIServiceID = svc.Metadata.ID
+	}
+	return ref
+}
+
+// backfillAppReferences returns the catalog application of a managed application and the subscription of its access
+// request to an API, nil for the clients without a managed application
+func backfillAppReferences(apiDetails models.APIDetails, appDetails models.AppDetails) (*models.ApplicationResourceReference, *models.ResourceReference) {
+	if appDetails.ID == "" || isUnmanagedApp(appDetails) {
+		return nil, nil
+	}
+	cacheManager := agent.GetCacheManager()
+	appRI := cacheManager.GetManagedApplication(appDetails.ID)
+	if appRI == nil {
+		return nil, nil
+	}
+
+	var app *models.ApplicationResourceReference
+	if appRef := appRI.GetReferenceByGVK(catalog.ApplicationGVK()); appRef.ID != "" {
+		app = &models.ApplicationResourceReference{
+			ResourceReference: models.ResourceReference{ID: appRef.ID},
+			ConsumerOrgID:     appDetails.ConsumerOrgID,
+		}
+	}
+
+	var subscription *models.ResourceReference
+	accessRequest := transutil.GetAccessRequest(cacheManager, appRI, apiDetails.ID, apiDetails.Stage, apiDetails.Version)
+	if accessRequest != nil {
+		if subRef := accessRequest.GetReferenceByGVK(catalog.SubscriptionGVK()); subRef.ID != "" {
+			subscription = &models.ResourceReference{ID: subRef.ID}
+		}
+	}
+	return app, subscription
+}
+
+// getOrgGUID returns the organization of the Central token, from the org_guid claim the sdk metric collector reads,
+// which it does not export. The token was issued to the agent, its claims are not verified again.
+func getOrgGUID() string {
+	token, err := agent.GetCentralAuthToken()
+	if err != nil {
+		return ""
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser(jwt.WithoutClaimsValidation()).ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+	orgGUID, _ := claims["org_guid"].(string)
+	return orgGUID
+}
+
+// Backfill reports the metrics of the monitoring archive of a time range, for the APIs of an asset or API id, or for
+// all the APIs when it is empty. The metrics of an API are sent in time order, each for the window since the previous
+// metrics. Unless forced, the range of an API ends where the metrics reported by the agent start, so that they are not
+// reported twice, and the APIs whose reported metrics have an unknown start are skipped. The cursors are not saved.
+func (me *MuleEventEmitter) Backfill(opts BackfillOptions) error {
+	me.eventChannel <- cmn.MetricEvent{Type: cmn.Initialize}
+	defer func() {
+		me.eventChannel <- cmn.MetricEvent{Type: cmn.Completed}
+	}()
+
+	if opts.From.Before(time.Now().Add(-archiveRetention)) {
+		logrus.WithField("from", opts.From).Warn("the monitoring archive only keeps the metrics of the last 30 days")
+	}
+
+	errs := []error{}
+	for _, instanceID := range me.instanceCache.GetAPIServiceInstanceKeys() {
+		instance, _ := me.instanceCache.GetAPIServiceInstanceByID(instanceID)
+		if instance == nil {
+			continue
+		}
+		apiID, _ := util.GetAgentDetailsValue(instance, cmn.AttrAssetID)
+		apiVersionID, _ := util.GetAgentDetailsValue(instance, cmn.AttrAPIID)
+		if apiID == "" || (opts.APIID != "" && opts.APIID != apiID && opts.APIID != apiVersionID) {
+			continue
+		}
+
+		logger := logrus.WithField("apiID", apiID).WithField("apiVersionID", apiVersionID)
+		to := opts.To
+		if !opts.Force {
+			cursor := me.cursors.get(apiID, apiVersionID)
+			reported, known := cursor.reported()
+			if !known {
+				logger.Warn("skipped the backfill, the start of the metrics reported by the agent is unknown, use --force to report the time range")
+				continue
+			}
+			if !reported.IsZero() && reported.Before(to) {
+				logger.WithField("to", reported).Info("the backfill ends at the metrics reported by the agent")
+				to = reported
+			}
+		}
+		if !opts.From.Before(to) {
+			logger.Warn("skipped the backfill, the agent already reported the metrics of the time range, use --force to report them again")
+			continue
+		}
+
+		if err := me.backfillAPI(instance, apiID, apiVersionID, opts.From, to); err != nil {
+			logger.WithError(err).Error("failed to backfill the metrics")
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backfillAPI reports the metrics of an API from a time, included, until another
+func (me *MuleEventEmitter) backfillAPI(instance *v1.ResourceInstance, apiID, apiVersionID string, from, to time.Time) error {
+	metrics := []anypoint.APIMonitoringMetric{}
+	for day := utcDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		files, err := me.client.ListMonitoringArchiveFiles(apiVersionID, day)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fileMetrics, err := me.client.GetMonitoringArchiveFile(apiVersionID, day, file.ID)
+			if err != nil {
+				return err
+			}
+			for _, m := range fileMetrics {
+				if !m.Time.Before(from) && m.Time.Before(to) {
+					metrics = append(metrics, m)
+				}
+			}
+		}
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Time.Before(metrics[j].Time)
+	})
+
+	// the metrics of the same time are sent for the same window
+	startTime := from
+	for i := 0; i < len(metrics); {
+		j := i + 1
+		for j < len(metrics) && metrics[j].Time.Equal(metrics[i].Time) {
+			j++
+		}
+		startTime = me.sendMetrics(instance, apiID, apiVersionID, startTime, metrics[i:j])
+		i = j
+	}
+	logrus.WithField("apiID", apiID).
+		WithField("apiVersionID", apiVersionID).
+		WithField("count", len(metrics)).
+		Info("backfilled the API metrics")
+	return nil
+}
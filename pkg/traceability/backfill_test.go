@@ -0,0 +1,194 @@
+package traceability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/agent"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	catalog "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/catalog/v1alpha1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	"github.com/Axway/agent-sdk/pkg/apic/definitions"
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+	"github.com/Axway/agents-mulesoft/pkg/common"
+	"github.com/Axway/agents-mulesoft/pkg/config"
+)
+
+func TestBackfill(t *testing.T) {
+	from := time.Date(2024, 10, 20, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 10, 22, 0, 0, 0, 0, time.UTC)
+	metric := func(tm time.Time, status string) anypoint.APIMonitoringMetric {
+		return anypoint.APIMonitoringMetric{
+			Time:   tm,
+			Events: []anypoint.APISummaryMetricEvent{{StatusCode: status, ResponseTimeCount: 1}},
+		}
+	}
+	client := &mockArchiveClient{
+		files: map[string][]anypoint.DataFile{
+			"2024-10-20": {{ID: "20b"}, {ID: "20a"}},
+			"2024-10-21": {{ID: "21a"}},
+			"2024-10-22": {{ID: "22a"}},
+		},
+		metrics: map[string][]anypoint.APIMonitoringMetric{
+			"20a": {metric(from.Add(-time.Hour), "200"), metric(from.Add(time.Hour), "200")},
+			"20b": {metric(from.Add(2*time.Hour), "200"), metric(from.Add(time.Hour), "500")},
+			"21a": {metric(from.Add(24*time.Hour), "200")},
+			"22a": {metric(to, "200")},
+		},
+	}
+
+	instances := &mockInstaceCache{}
+	for _, ids := range [][]string{{"1", "asset-1", "api-1"}, {"2", "asset-2", "api-2"}} {
+		svcInst := management.NewAPIServiceInstance("api"+ids[0], "env")
+		svcInst.Metadata.ID = ids[0]
+		util.SetAgentDetailsKey(svcInst, common.AttrAssetID, ids[1])
+		util.SetAgentDetailsKey(svcInst, common.AttrAPIID, ids[2])
+		ri, _ := svcInst.AsInstance()
+		instances.AddAPIServiceInstance(ri)
+	}
+
+	eventCh := make(chan common.MetricEvent, 20)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, instances)
+
+	err := emitter.Backfill(BackfillOptions{From: from, To: to, APIID: "api-1"})
+	assert.Nil(t, err)
+	close(eventCh)
+
+	events := []common.MetricEvent{}
+	for event := range eventCh {
+		events = append(events, event)
+	}
+	assert.Equal(t, common.Initialize, events[0].Type)
+	assert.Equal(t, common.Completed, events[len(events)-1].Type)
+
+	// the metrics of the range are sent in time order, for the window since the previous metrics
+	type window struct {
+		status     string
+		start, end time.Time
+	}
+	windows := []window{}
+	for _, event := range events[1 : len(events)-1] {
+		assert.Equal(t, "asset-1", event.Metric.APIID)
+		windows = append(windows, window{event.Metric.StatusCode, event.Metric.StartTime, event.Metric.EndTime})
+	}
+	assert.Equal(t, []window{
+		{"500", from, from.Add(time.Hour)},
+		{"200", from, from.Add(time.Hour)},
+		{"200", from.Add(time.Hour), from.Add(2 * time.Hour)},
+		{"200", from.Add(2 * time.Hour), from.Add(24 * time.Hour)},
+	}, windows)
+	assert.Equal(t, []string{"2024-10-20", "2024-10-21"}, client.listedDay)
+
+	// the last run cursors are untouched
+	assert.Empty(t, emitter.cursors.cursors)
+}
+
+func TestBackfillReportedMetrics(t *testing.T) {
+	from := time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 10, 22, 0, 0, 0, 0, time.UTC)
+	reported := time.Date(2024, 10, 21, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cursor   *apiCursor
+		force    bool
+		expected []string
+	}{
+		{
+			name:     "should backfill the range of an API without reported metrics",
+			expected: []string{"2024-10-20", "2024-10-21"},
+		},
+		{
+			name:     "should end the range where the reported metrics start",
+			cursor:   &apiCursor{FirstRun: reported.UnixMilli(), LastRun: to.UnixMilli()},
+			expected: []string{"2024-10-20"},
+		},
+		{
+			name:   "should skip the range of the reported metrics",
+			cursor: &apiCursor{FirstRun: from.UnixMilli(), LastRun: to.UnixMilli()},
+		},
+		{
+			name:   "should skip an API whose reported metrics have an unknown start",
+			cursor: &apiCursor{LastRun: to.UnixMilli()},
+		},
+		{
+			name:     "should backfill the reported metrics when forced",
+			cursor:   &apiCursor{FirstRun: from.UnixMilli(), LastRun: to.UnixMilli()},
+			force:    true,
+			expected: []string{"2024-10-20", "2024-10-21"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockArchiveClient{}
+			instances := &mockInstaceCache{}
+			svcInst := management.NewAPIServiceInstance("api1", "env")
+			svcInst.Metadata.ID = "1"
+			util.SetAgentDetailsKey(svcInst, common.AttrAssetID, "asset-1")
+			util.SetAgentDetailsKey(svcInst, common.AttrAPIID, "api-1")
+			ri, _ := svcInst.AsInstance()
+			instances.AddAPIServiceInstance(ri)
+
+			eventCh := make(chan common.MetricEvent, 10)
+			emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, instances)
+			if tc.cursor != nil {
+				emitter.cursors.cursors["asset-1"] = map[string]*apiCursor{"api-1": tc.cursor}
+			}
+
+			err := emitter.Backfill(BackfillOptions{From: from, To: to, Force: tc.force})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, client.listedDay)
+		})
+	}
+}
+
+func TestBackfillAppReferences(t *testing.T) {
+	agent.InitializeForTest(nil)
+	cacheManager := agent.GetCacheManager()
+
+	svcInst := management.NewAPIServiceInstance("instance", "env")
+	svcInst.Metadata.ID = "instance-id"
+	util.SetAgentDetailsKey(svcInst, definitions.AttrExternalAPIID, "asset-id")
+	instRI, _ := svcInst.AsInstance()
+	cacheManager.AddAPIServiceInstance(instRI)
+
+	app := management.NewManagedApplication("app", "env")
+	app.Metadata.ID = "app-id"
+	app.Metadata.References = []v1.Reference{{Group: catalog.ApplicationGVK().Group, Kind: catalog.ApplicationGVK().Kind, ID: "catalog-app-id"}}
+	appRI, _ := app.AsInstance()
+	cacheManager.AddManagedApplication(appRI)
+
+	ar := management.NewAccessRequest("ar", "env")
+	ar.Spec.ManagedApplication = "app"
+	ar.Metadata.References = []v1.Reference{
+		{Group: management.APIServiceInstanceGVK().Group, Kind: management.APIServiceInstanceGVK().Kind, ID: "instance-id"},
+		{Group: catalog.SubscriptionGVK().Group, Kind: catalog.SubscriptionGVK().Kind, ID: "subscription-id"},
+	}
+	arRI, _ := ar.AsInstance()
+	cacheManager.AddAccessRequest(arRI)
+
+	apiDetails := models.APIDetails{ID: "asset-id"}
+	appRef, subscription := backfillAppReferences(apiDetails, models.AppDetails{ID: "app-id", ConsumerOrgID: "org-id"})
+	assert.Equal(t, &models.ApplicationResourceReference{
+		ResourceReference: models.ResourceReference{ID: "catalog-app-id"},
+		ConsumerOrgID:     "org-id",
+	}, appRef)
+	assert.Equal(t, &models.ResourceReference{ID: "subscription-id"}, subscription)
+
+	// the application is referenced without a subscription to another API
+	appRef, subscription = backfillAppReferences(models.APIDetails{ID: "other-asset-id"}, models.AppDetails{ID: "app-id"})
+	assert.NotNil(t, appRef)
+	assert.Nil(t, subscription)
+
+	// the unmanaged applications and the clients without an application are not referenced
+	for _, appDetails := range []models.AppDetails{{ID: unmanagedAppIDPrefix + "10"}, {}, {ID: "unknown"}} {
+		appRef, subscription = backfillAppReferences(apiDetails, appDetails)
+		assert.Nil(t, appRef)
+		assert.Nil(t, subscription)
+	}
+}
@@ -0,0 +1,102 @@
+package traceability
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
+
+const (
+	// clientAppTTL is the time the client applications listed from Mulesoft are used before they are listed again
+	clientAppTTL = 10 * time.Minute
+	// unmanagedAppIDPrefix prefixes the ids of the Mulesoft client applications, so that they are not taken for the ids
+	// of managed applications in Central
+	unmanagedAppIDPrefix = "mulesoft-app-"
+)
+
+type clientAppLister interface {
+	ListClientApplications() ([]anypoint.Application, error)
+}
+
+// clientAppResolver resolves the client applications created in Mulesoft, without a credential in Central, by their
+// client id. The applications of the organization are cached, and listed again in the background once they are older
+// than the TTL so that the event loop does not wait for the listing.
+type clientAppResolver struct {
+	logger     log.FieldLogger
+	client     clientAppLister
+	ttl        time.Duration
+	lock       sync.Mutex
+	apps       map[string]anypoint.Application
+	refreshed  time.Time
+	refreshing bool
+}
+
+func newClientAppResolver(client clientAppLister, ttl time.Duration) *clientAppResolver {
+	return &clientAppResolver{
+		logger: log.NewFieldLogger().WithPackage("traceability.clientapp").WithComponent("clientAppResolver"),
+		client: client,
+		ttl:    ttl,
+		apps:   map[string]anypoint.Application{},
+	}
+}
+
+// withClientApps reports the transactions of the client ids without a credential in Central for the Mulesoft client
+// applications. The applications are listed before the emitter starts, so that the first transactions are resolved.
+func (a *Agent) withClientApps(client clientAppLister) {
+	a.clientApps = newClientAppResolver(client, clientAppTTL)
+	a.clientApps.refresh()
+}
+
+// getAppDetails returns the Mulesoft name and id of the client application of a client id, as an unmanaged application.
+// The applications are listed in the background when they are expired, the cached applications are used meanwhile.
+func (r *clientAppResolver) getAppDetails(clientID string) (models.AppDetails, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.refreshing && time.Since(r.refreshed) >= r.ttl {
+		r.refreshing = true
+		go r.refresh()
+	}
+	app, ok := r.apps[clientID]
+	if !ok {
+		return models.AppDetails{}, false
+	}
+	return models.AppDetails{
+		ID:   unmanagedAppIDPrefix + strconv.Itoa(app.ID),
+		Name: app.Name,
+	}, true
+}
+
+// refresh lists the client applications, the cached applications are kept when they can not be listed and are listed
+// again after the TTL
+func (r *clientAppResolver) refresh() {
+	apps, err := r.client.ListClientApplications()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.refreshed = time.Now()
+	r.refreshing = false
+	if err != nil {
+		r.logger.WithError(err).Warn("failed to list the client applications")
+		return
+	}
+
+	r.apps = make(map[string]anypoint.Application, len(apps))
+	for _, app := range apps {
+		if app.ClientID != "" {
+			r.apps[app.ClientID] = app
+		}
+	}
+	r.logger.WithField("count", len(r.apps)).Debug("listed the client applications")
+}
+
+// isUnmanagedApp returns true for the details of a Mulesoft client application without a managed application
+func isUnmanagedApp(appDetails models.AppDetails) bool {
+	return strings.HasPrefix(appDetails.ID, unmanagedAppIDPrefix)
+}
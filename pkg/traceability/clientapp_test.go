@@ -0,0 +1,113 @@
+package traceability
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/anypoint"
+)
+
+type mockClientAppLister struct {
+	lock  sync.Mutex
+	apps  []anypoint.Application
+	err   error
+	calls int
+}
+
+func (m *mockClientAppLister) ListClientApplications() ([]anypoint.Application, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls++
+	return m.apps, m.err
+}
+
+func (m *mockClientAppLister) set(apps []anypoint.Application, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.apps, m.err = apps, err
+}
+
+func (m *mockClientAppLister) getCalls() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.calls
+}
+
+// waitRefresh waits for the background listing of the client applications
+func waitRefresh(t *testing.T, resolver *clientAppResolver) {
+	assert.Eventually(t, func() bool {
+		resolver.lock.Lock()
+		defer resolver.lock.Unlock()
+		return !resolver.refreshing
+	}, time.Second, time.Millisecond)
+}
+
+func TestClientAppResolver(t *testing.T) {
+	lister := &mockClientAppLister{
+		apps: []anypoint.Application{
+			{ID: 10, Name: "mule-app", ClientID: "mule-client"},
+			{ID: 11, Name: "no-client"},
+		},
+	}
+	resolver := newClientAppResolver(lister, time.Hour)
+
+	// the applications are listed in the background
+	_, ok := resolver.getAppDetails("mule-client")
+	assert.False(t, ok)
+	waitRefresh(t, resolver)
+
+	app, ok := resolver.getAppDetails("mule-client")
+	assert.True(t, ok)
+	assert.Equal(t, models.AppDetails{ID: "mulesoft-app-10", Name: "mule-app"}, app)
+	assert.True(t, isUnmanagedApp(app))
+	_, ok = resolver.getAppDetails("unknown")
+	assert.False(t, ok)
+	// the applications are cached
+	assert.Equal(t, 1, lister.getCalls())
+
+	// the applications are listed again once expired, and are kept when they can not be listed
+	resolver.lock.Lock()
+	resolver.refreshed = time.Now().Add(-2 * time.Hour)
+	resolver.lock.Unlock()
+	lister.set(lister.apps, fmt.Errorf("error"))
+	_, ok = resolver.getAppDetails("mule-client")
+	assert.True(t, ok)
+	waitRefresh(t, resolver)
+	assert.Equal(t, 2, lister.getCalls())
+	_, ok = resolver.getAppDetails("mule-client")
+	assert.True(t, ok)
+	assert.Equal(t, 2, lister.getCalls())
+
+	resolver.lock.Lock()
+	resolver.refreshed = time.Time{}
+	resolver.lock.Unlock()
+	lister.set([]anypoint.Application{{ID: 12, Name: "new-app", ClientID: "new-client"}}, nil)
+	resolver.getAppDetails("new-client")
+	waitRefresh(t, resolver)
+	app, ok = resolver.getAppDetails("new-client")
+	assert.True(t, ok)
+	assert.Equal(t, "new-app", app.Name)
+	_, ok = resolver.getAppDetails("mule-client")
+	assert.False(t, ok)
+}
+
+func TestAppDetailsClientApps(t *testing.T) {
+	a := newTrafficAgent(t)
+	lister := &mockClientAppLister{
+		apps: []anypoint.Application{{ID: 10, Name: "mule-app", ClientID: "mule-client"}},
+	}
+	a.withClientApps(lister)
+	// the applications are listed before the first transaction
+	assert.Equal(t, 1, lister.getCalls())
+
+	// the managed application of a credential takes precedence
+	assert.Equal(t, "app-id", a.getAppDetails("client").ID)
+	assert.Equal(t, models.AppDetails{ID: "mulesoft-app-10", Name: "mule-app"}, a.getAppDetails("mule-client"))
+	assert.Equal(t, models.AppDetails{}, a.getAppDetails("unknown"))
+	assert.Equal(t, models.AppDetails{}, a.getAppDetails(""))
+}
@@ -0,0 +1,285 @@
+package traceability
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cursorStoreVersion is the version of the format of the cursor store file
+	cursorStoreVersion = 1
+	cursorStoreFile    = "cursors.json"
+)
+
+// apiCursor - the progress of the metrics of an API version: the start and the end of the reported metrics in
+// milliseconds, the next day of the monitoring archive to read, and the data files already reported by day. The start
+// is unknown for the cursors saved by the previous versions of the agent.
+type apiCursor struct {
+	FirstRun     int64               `json:"firstRun,omitempty"`
+	LastRun      int64               `json:"lastRun,omitempty"`
+	ArchiveDay   string              `json:"archiveDay,omitempty"`
+	ArchiveFiles map[string][]string `json:"archiveFiles,omitempty"`
+}
+
+func (c *apiCursor) copy() *apiCursor {
+	cp := &apiCursor{FirstRun: c.FirstRun, LastRun: c.LastRun, ArchiveDay: c.ArchiveDay}
+	if len(c.ArchiveFiles) > 0 {
+		cp.ArchiveFiles = make(map[string][]string, len(c.ArchiveFiles))
+		for day, files := range c.ArchiveFiles {
+			cp.ArchiveFiles[day] = append([]string{}, files...)
+		}
+	}
+	return cp
+}
+
+// reported returns the start of the metrics reported with the cursor, false when they were reported from an unknown
+// time. The zero time is returned when no metrics were reported.
+func (c *apiCursor) reported() (time.Time, bool) {
+	if c.FirstRun > 0 {
+		return time.UnixMilli(c.FirstRun), true
+	}
+	if c.LastRun > 0 || c.ArchiveDay != "" || len(c.ArchiveFiles) > 0 {
+		return time.Time{}, false
+	}
+	return time.Time{}, true
+}
+
+// cursorFile - the cursors of the API versions by asset id and API version id
+type cursorFile struct {
+	Version int                              `json:"version"`
+	Cursors map[string]map[string]*apiCursor `json:"cursors"`
+}
+
+// cursorStore keeps the cursors of the API versions in a file replaced when the changes are saved, a crash while it is
+// written leaves the previous file. The changes are only kept in memory until they are saved, once an API or a day of
+// the monitoring archive is collected. The cursors migrated from the cache of the previous versions of the agent were
+// only keyed by asset id, they are pending until they are copied to the versions of their asset.
+type cursorStore struct {
+	lock     sync.Mutex
+	saveLock sync.Mutex
+	path     string
+	cursors  map[string]map[string]*apiCursor
+	legacy   map[string]*apiCursor
+	changed  bool
+}
+
+// loadCursorStore loads the cursor store of a directory, or migrates the cursors of the cache of the directory when
+// there is no store yet
+func loadCursorStore(dir string) *cursorStore {
+	s := &cursorStore{
+		path:    filepath.Join(dir, cursorStoreFile),
+		cursors: map[string]map[string]*apiCursor{},
+		legacy:  map[string]*apiCursor{},
+	}
+	logger := logrus.WithField("path", s.path)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.migrate(cache.Load(formatCachePath(dir)))
+		return s
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to read the cursors, the metrics are reported from now")
+		return s
+	}
+
+	file := cursorFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		logger.WithError(err).Error("failed to parse the cursors, the metrics are reported from now")
+		return s
+	}
+	if file.Version > cursorStoreVersion {
+		logger.WithField("version", file.Version).Error("unsupported version of the cursors, the metrics are reported from now")
+		return s
+	}
+	if file.Cursors != nil {
+		s.cursors = file.Cursors
+	}
+	// the previous versions of the store kept the migrated cursors under an empty API version id
+	for assetID, versions := range s.cursors {
+		if c, ok := versions[""]; ok {
+			s.legacy[assetID] = c
+			delete(versions, "")
+		}
+	}
+	return s
+}
+
+// migrate reads the cursors of the cache keyed by asset id. The cache is migrated again until the migrated cursors are
+// saved.
+func (s *cursorStore) migrate(legacy cache.Cache) {
+	legacyCursor := func(assetID string) *apiCursor {
+		c, ok := s.legacy[assetID]
+		if !ok {
+			c = &apiCursor{}
+			s.legacy[assetID] = c
+		}
+		return c
+	}
+
+	for _, key := range legacy.GetKeys() {
+		item, _ := legacy.Get(key)
+		value, ok := item.(string)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(key, CacheKeyTimeStamp+"-"):
+			lastRun, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			legacyCursor(strings.TrimPrefix(key, CacheKeyTimeStamp+"-")).LastRun = lastRun
+		case strings.HasPrefix(key, CacheKeyArchiveDay+"-"):
+			legacyCursor(strings.TrimPrefix(key, CacheKeyArchiveDay+"-")).ArchiveDay = value
+		case strings.HasPrefix(key, CacheKeyArchiveFiles+"-"):
+			// ARCHIVE_FILES-<asset id>-<day>
+			assetDay := strings.TrimPrefix(key, CacheKeyArchiveFiles+"-")
+			if len(assetDay) <= len(archiveDayFormat)+1 {
+				continue
+			}
+			split := len(assetDay) - len(archiveDayFormat)
+			cursor := legacyCursor(assetDay[:split-1])
+			if cursor.ArchiveFiles == nil {
+				cursor.ArchiveFiles = map[string][]string{}
+			}
+			cursor.ArchiveFiles[assetDay[split:]] = strings.Split(value, ",")
+		}
+	}
+	if len(s.legacy) > 0 {
+		logrus.WithField("path", s.path).WithField("count", len(s.legacy)).Info("migrated the cursors of the APIs from the cache")
+	}
+}
+
+// materialize copies the migrated cursor of each asset to the versions of the asset without a cursor, by asset id, and
+// saves the store. The migrated cursors are then deleted, the ones of the assets without a version are dropped.
+func (s *cursorStore) materialize(versions map[string][]string) error {
+	s.lock.Lock()
+	if len(s.legacy) == 0 {
+		s.lock.Unlock()
+		return nil
+	}
+	for assetID, legacy := range s.legacy {
+		if len(versions[assetID]) == 0 {
+			logrus.WithField("apiID", assetID).Info("dropped the migrated cursor of an asset without API versions")
+		}
+		for _, apiVersionID := range versions[assetID] {
+			if _, ok := s.cursors[assetID][apiVersionID]; !ok {
+				*s.cursor(assetID, apiVersionID) = *legacy.copy()
+			}
+		}
+	}
+	s.legacy = map[string]*apiCursor{}
+	s.changed = true
+	s.lock.Unlock()
+	return s.save()
+}
+
+// cursor returns the cursor of an API version, creating it
+func (s *cursorStore) cursor(assetID, apiVersionID string) *apiCursor {
+	versions, ok := s.cursors[assetID]
+	if !ok {
+		versions = map[string]*apiCursor{}
+		s.cursors[assetID] = versions
+	}
+	c, ok := versions[apiVersionID]
+	if !ok {
+		c = &apiCursor{}
+		versions[apiVersionID] = c
+	}
+	return c
+}
+
+// get returns a copy of the cursor of an API version, or of the migrated cursor of its asset until it is materialized
+func (s *cursorStore) get(assetID, apiVersionID string) apiCursor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c, ok := s.cursors[assetID][apiVersionID]; ok {
+		return *c.copy()
+	}
+	if c, ok := s.legacy[assetID]; ok {
+		return *c.copy()
+	}
+	return apiCursor{}
+}
+
+// update changes the cursor of an API version, the change is kept until the store is saved
+func (s *cursorStore) update(assetID, apiVersionID string, change func(c *apiCursor)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	change(s.cursor(assetID, apiVersionID))
+	s.changed = true
+}
+
+// save writes the changed cursors to a temporary file renamed to the store file. The cursors are serialized while the
+// store is locked and written once it is unlocked, the saves are written in order.
+func (s *cursorStore) save() error {
+	s.saveLock.Lock()
+	defer s.saveLock.Unlock()
+
+	s.lock.Lock()
+	if !s.changed {
+		s.lock.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(cursorFile{Version: cursorStoreVersion, Cursors: s.cursors})
+	s.changed = false
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(data); err != nil {
+		s.lock.Lock()
+		s.changed = true
+		s.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *cursorStore) write(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), cursorStoreFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace the cursors: %w", err)
+	}
+	return nil
+}
+
+// sortedFiles returns the ids of a set of data files in order
+func sortedFiles(files map[string]bool) []string {
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
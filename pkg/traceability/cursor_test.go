@@ -0,0 +1,137 @@
+package traceability
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorStore(t *testing.T) {
+	dir := t.TempDir()
+	store := loadCursorStore(dir)
+	assert.Equal(t, apiCursor{}, store.get("asset", "v1"))
+
+	// the versions of an asset have their own cursor, the changes are written once saved
+	store.update("asset", "v1", func(c *apiCursor) { c.LastRun = 1000 })
+	store.update("asset", "v2", func(c *apiCursor) {
+		c.LastRun = 2000
+		c.ArchiveDay = "2024-10-21"
+		c.ArchiveFiles = map[string][]string{"2024-10-20": {"a", "b"}}
+	})
+	assert.Equal(t, apiCursor{}, loadCursorStore(dir).get("asset", "v1"))
+	assert.Nil(t, store.save())
+
+	reloaded := loadCursorStore(dir)
+	assert.Equal(t, apiCursor{LastRun: 1000}, reloaded.get("asset", "v1"))
+	assert.Equal(t, apiCursor{
+		LastRun:      2000,
+		ArchiveDay:   "2024-10-21",
+		ArchiveFiles: map[string][]string{"2024-10-20": {"a", "b"}},
+	}, reloaded.get("asset", "v2"))
+
+	// the file is versioned and no temporary file is left
+	data, err := os.ReadFile(filepath.Join(dir, cursorStoreFile))
+	assert.Nil(t, err)
+	file := cursorFile{}
+	assert.Nil(t, json.Unmarshal(data, &file))
+	assert.Equal(t, cursorStoreVersion, file.Version)
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+
+	// a copy of the cursor is returned
+	cursor := reloaded.get("asset", "v2")
+	cursor.ArchiveFiles["2024-10-20"][0] = "changed"
+	assert.Equal(t, "a", reloaded.get("asset", "v2").ArchiveFiles["2024-10-20"][0])
+}
+
+func TestCursorStoreMigration(t *testing.T) {
+	dir := t.TempDir()
+	legacy := cache.New()
+	legacy.Set(CacheKeyTimeStamp+"-my-asset", "1000")
+	legacy.Set(CacheKeyArchiveDay+"-my-asset", "2024-10-21")
+	legacy.Set(CacheKeyArchiveFiles+"-my-asset-2024-10-20", "a,b")
+	legacy.Set(CacheKeyTimeStamp+"-invalid", "yesterday")
+	legacy.Set("OTHER-my-asset", "other")
+	assert.Nil(t, legacy.Save(formatCachePath(dir)))
+
+	store := loadCursorStore(dir)
+	migrated := apiCursor{
+		LastRun:      1000,
+		ArchiveDay:   "2024-10-21",
+		ArchiveFiles: map[string][]string{"2024-10-20": {"a", "b"}},
+	}
+	// the versions of an asset start from the cursor of the asset
+	assert.Equal(t, migrated, store.get("my-asset", "v1"))
+	assert.Equal(t, apiCursor{}, store.get("invalid", "v1"))
+
+	// the migration is not saved until the cursors are copied to the versions of the assets
+	assert.NoFileExists(t, filepath.Join(dir, cursorStoreFile))
+	assert.Nil(t, store.materialize(map[string][]string{"my-asset": {"v1", "v2"}, "other-asset": {"v1"}}))
+	assert.Empty(t, store.legacy)
+	assert.Equal(t, map[string]map[string]*apiCursor{
+		"my-asset": {"v1": &migrated, "v2": &migrated},
+	}, store.cursors)
+
+	store.update("my-asset", "v1", func(c *apiCursor) {
+		c.LastRun = 3000
+		delete(c.ArchiveFiles, "2024-10-20")
+	})
+	assert.Nil(t, store.save())
+	assert.Equal(t, apiCursor{LastRun: 3000, ArchiveDay: "2024-10-21"}, store.get("my-asset", "v1"))
+	assert.Equal(t, migrated, store.get("my-asset", "v2"))
+
+	// the migration is saved, a changed cache is not migrated again, and the new versions start from now
+	legacy.Set(CacheKeyTimeStamp+"-my-asset", "5000")
+	assert.Nil(t, legacy.Save(formatCachePath(dir)))
+	reloaded := loadCursorStore(dir)
+	assert.Equal(t, int64(3000), reloaded.get("my-asset", "v1").LastRun)
+	assert.Equal(t, int64(1000), reloaded.get("my-asset", "v2").LastRun)
+	assert.Equal(t, apiCursor{}, reloaded.get("my-asset", "v3"))
+}
+
+func TestCursorStoreLegacyVersion(t *testing.T) {
+	// the previous versions of the store kept the migrated cursors under an empty API version id
+	dir := t.TempDir()
+	data := `{"version":1,"cursors":{"asset":{"":{"lastRun":1000},"v1":{"lastRun":2000}}}}`
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, cursorStoreFile), []byte(data), 0600))
+
+	store := loadCursorStore(dir)
+	assert.Equal(t, int64(1000), store.get("asset", "v2").LastRun)
+	assert.Nil(t, store.materialize(map[string][]string{"asset": {"v1", "v2"}}))
+
+	reloaded := loadCursorStore(dir)
+	assert.Empty(t, reloaded.legacy)
+	assert.Equal(t, map[string]map[string]*apiCursor{
+		"asset": {"v1": {LastRun: 2000}, "v2": {LastRun: 1000}},
+	}, reloaded.cursors)
+}
+
+func TestCursorStoreInvalidFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "should start from now when the file is corrupted",
+			data: `{"version":1,"cursors":{"asset":`,
+		},
+		{
+			name: "should start from now when the version is not supported",
+			data: `{"version":2,"cursors":{"asset":{"v1":{"lastRun":1000}}}}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, cursorStoreFile), []byte(tc.data), 0600))
+
+			store := loadCursorStore(dir)
+			assert.Equal(t, apiCursor{}, store.get("asset", "v1"))
+		})
+	}
+}
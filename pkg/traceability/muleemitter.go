@@ -1,12 +1,14 @@
 package traceability
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
-	"github.com/Axway/agent-sdk/pkg/cache"
 	"github.com/Axway/agent-sdk/pkg/util"
 
 	"github.com/Axway/agent-sdk/pkg/jobs"
@@ -22,7 +24,22 @@ import (
 
 const (
 	healthCheckEndpoint = "ingestion"
-	CacheKeyTimeStamp   = "LAST_RUN"
+	// CacheKeyTimeStamp is the cache key of the last run of an asset, migrated to the cursor store
+	CacheKeyTimeStamp = "LAST_RUN"
+	// CacheKeyArchiveDay is the cache key of the next day of the monitoring archive to read, migrated to the cursor store
+	CacheKeyArchiveDay = "ARCHIVE_DAY"
+	// CacheKeyArchiveFiles is the cache key of the data files of a day of the monitoring archive already reported,
+	// migrated to the cursor store
+	CacheKeyArchiveFiles = "ARCHIVE_FILES"
+	archiveDayFormat     = "2006-01-02"
+	// archiveRetention is the time the Anypoint Monitoring archive keeps the data files
+	archiveRetention = 30 * 24 * time.Hour
+	// defaultMetricWorkers is the number of APIs collected at the same time when it is not configured
+	defaultMetricWorkers = 5
+	// archiveLateFileTime is the time after the end of a day during which data files may still be added for that day
+	archiveLateFileTime = 24 * time.Hour
+	// maxArchiveFileAttempts is the number of runs a data file of the monitoring archive is read before it is skipped
+	maxArchiveFileAttempts = 3
 )
 
 type instanceCache interface {
@@ -41,10 +58,15 @@ type healthChecker func(name, endpoint string, check hc.CheckStatus) (string, er
 type MuleEventEmitter struct {
 	client           anypoint.AnalyticsClient
 	eventChannel     chan common.MetricEvent
-	cache            cache.Cache
-	cachePath        string
+	cursors          *cursorStore
 	instanceCache    instanceCache
 	useMonitoringAPI bool
+	workers          int
+	failuresLock     sync.RWMutex
+	// failures are the errors of the APIs that failed in the last run, by asset and api version id
+	failures     map[string]string
+	attemptsLock sync.Mutex
+	fileAttempts map[string]int
 }
 
 // MuleEventEmitterJob wraps an Emitter and implements the Job interface so that it can be executed by the sdk.
@@ -63,13 +85,20 @@ func NewMuleEventEmitter(config *config.MulesoftConfig, eventChannel chan common
 		client:           client,
 		instanceCache:    instanceCache,
 		useMonitoringAPI: config.UseMonitoringAPI,
+		workers:          config.MetricWorkers,
+		failures:         map[string]string{},
+		fileAttempts:     map[string]int{},
 	}
-	me.cachePath = formatCachePath(config.CachePath)
-	me.cache = cache.Load(me.cachePath)
+	if me.workers < 1 {
+		me.workers = defaultMetricWorkers
+	}
+	me.cursors = loadCursorStore(config.CachePath)
 	return me
 }
 
-// Start retrieves analytics data from anypoint and sends them on the event channel for processing.
+// Start retrieves analytics data from anypoint and sends them on the event channel for processing. The APIs whose
+// metrics cannot be collected are listed in the health check and collected again on the next run, they do not fail the
+// job. Only the failures of every API, such as Mulesoft being unreachable, and of the monitoring bootstrap are returned.
 func (me *MuleEventEmitter) Start() error {
 	var bootInfo *anypoint.MonitoringBootInfo
 	if !me.useMonitoringAPI {
@@ -80,6 +109,8 @@ func (me *MuleEventEmitter) Start() error {
 		bootInfo = bi
 	}
 
+	me.materializeCursors()
+
 	// Initialize Metric Batch
 	me.eventChannel <- common.MetricEvent{Type: common.Initialize}
 
@@ -88,88 +119,354 @@ func (me *MuleEventEmitter) Start() error {
 		me.eventChannel <- common.MetricEvent{Type: common.Completed}
 	}()
 
-	// change the cache to store startTime per API
-	instanceKeys := me.instanceCache.GetAPIServiceInstanceKeys()
+	// the APIs are collected by a pool of workers, the metrics are all sent before the batch is completed
+	instances := make(chan *v1.ResourceInstance)
 	reportEndTime := time.Now()
-	for _, instanceID := range instanceKeys {
-		instance, _ := me.instanceCache.GetAPIServiceInstanceByID(instanceID)
-		apiID, _ := util.GetAgentDetailsValue(instance, common.AttrAssetID)
-		apiVersionID, _ := util.GetAgentDetailsValue(instance, common.AttrAPIID)
-		if apiID == "" {
-			continue
-		}
-		lastAPIReportTime := me.getLastRun(apiID)
-		metrics, err := me.getMetrics(bootInfo, apiID, apiVersionID, lastAPIReportTime, reportEndTime)
-		endTime := lastAPIReportTime
-		for _, metric := range metrics {
-			// Report only latest entries, ignore old entries
-			if metric.Time.UnixMilli() > lastAPIReportTime.UnixMilli() {
-				for _, event := range metric.Events {
-					m := common.MetricEvent{
-						Type: common.Metric,
-						Metric: common.Metrics{
-							StartTime:  lastAPIReportTime,
-							EndTime:    metric.Time,
-							APIID:      apiID,
-							Instance:   instance,
-							StatusCode: event.StatusCode,
-							Count:      int64(event.RequestSizeCount),
-							Max:        int64(event.ResponseTimeMax),
-							Min:        int64(event.ResponseTimeMin),
-						},
-					}
-					me.eventChannel <- m
-					logrus.WithField("apiID", apiID).
-						WithField("apiVersionID", apiVersionID).
-						WithField("statusCode", event.StatusCode).
-						WithField("count", event.RequestSizeCount).
-						WithField("metricTime", metric.Time).
-						Info("storing API metrics")
+	failures := map[string]string{}
+	errs := []error{}
+	collected := 0
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < me.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for instance := range instances {
+				apiID, apiVersionID, err := me.collectInstanceMetrics(bootInfo, instance, reportEndTime)
+				lock.Lock()
+				if apiID != "" {
+					collected++
 				}
+				if err != nil {
+					logrus.WithField("apiID", apiID).WithField("apiVersionID", apiVersionID).WithError(err).Error("failed to get analytics data")
+					failures[apiID+"/"+apiVersionID] = err.Error()
+					errs = append(errs, err)
+				}
+				lock.Unlock()
 			}
-			// Results are not sorted. We want the most recent time to bubble up for next run cycle
-			if metric.Time.UnixMilli() > endTime.UnixMilli() {
-				endTime = metric.Time
-			}
+		}()
+	}
+
+	for _, instanceID := range me.instanceCache.GetAPIServiceInstanceKeys() {
+		instance, _ := me.instanceCache.GetAPIServiceInstanceByID(instanceID)
+		if instance != nil {
+			instances <- instance
 		}
-		logrus.WithField("apiID", apiID).
-			WithField("apiVersionID", apiVersionID).
-			WithField("lastReportTime", endTime).
-			Info("updating next query time")
-		me.saveLastRun(apiID, endTime)
+	}
+	close(instances)
+	wg.Wait()
+
+	me.failuresLock.Lock()
+	me.failures = failures
+	me.failuresLock.Unlock()
+
+	if len(errs) > 0 && len(errs) == collected {
+		return fmt.Errorf("failed to collect the metrics of every API: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// collectInstanceMetrics reports the metrics of the API of an instance and returns its asset and api version ids, an
+// error only fails the API
+func (me *MuleEventEmitter) collectInstanceMetrics(bootInfo *anypoint.MonitoringBootInfo, instance *v1.ResourceInstance, reportEndTime time.Time) (string, string, error) {
+	apiID, _ := util.GetAgentDetailsValue(instance, common.AttrAssetID)
+	apiVersionID, _ := util.GetAgentDetailsValue(instance, common.AttrAPIID)
+	if apiID == "" {
+		return apiID, apiVersionID, nil
+	}
+
+	if me.useMonitoringAPI {
+		return apiID, apiVersionID, me.collectArchiveMetrics(instance, apiID, apiVersionID, reportEndTime)
+	}
+	return apiID, apiVersionID, me.collectMetrics(bootInfo, instance, apiID, apiVersionID, reportEndTime)
+}
+
+// healthCheck fails when Mulesoft cannot be reached, and lists the APIs whose metrics could not be collected in the
+// last run. The other APIs are still collected, so these failures do not fail the check.
+func (me *MuleEventEmitter) healthCheck(name string) *hc.Status {
+	status := traceabilityHealthCheck(name)
+	if status.Result == hc.OK {
+		status.Details = me.failureDetails(name)
+	}
+	return status
+}
+
+func (me *MuleEventEmitter) failureDetails(name string) string {
+	me.failuresLock.RLock()
+	defer me.failuresLock.RUnlock()
+	if len(me.failures) == 0 {
+		return ""
+	}
+
+	apis := make([]string, 0, len(me.failures))
+	for api, err := range me.failures {
+		apis = append(apis, fmt.Sprintf("%s: %s", api, err))
+	}
+	sort.Strings(apis)
+	return fmt.Sprintf("%s failed to collect the metrics of %d APIs. %s", name, len(apis), strings.Join(apis, "; "))
+}
+
+// collectMetrics reports the metrics of an API from the last run until the end time
+func (me *MuleEventEmitter) collectMetrics(bootInfo *anypoint.MonitoringBootInfo, instance *v1.ResourceInstance, apiID, apiVersionID string, reportEndTime time.Time) error {
+	lastAPIReportTime := me.getLastRun(apiID, apiVersionID)
+	metrics, err := me.client.GetMonitoringMetrics(bootInfo.Settings.DataSource.InfluxDB.Database, bootInfo.Settings.DataSource.InfluxDB.ID, apiID, apiVersionID, lastAPIReportTime, reportEndTime)
+	endTime := me.sendMetrics(instance, apiID, apiVersionID, lastAPIReportTime, newMetrics(metrics, lastAPIReportTime))
+	logrus.WithField("apiID", apiID).
+		WithField("apiVersionID", apiVersionID).
+		WithField("lastReportTime", endTime).
+		Info("updating next query time")
+	me.saveLastRun(apiID, apiVersionID, lastAPIReportTime, endTime)
+	me.saveCursors(apiID, apiVersionID)
+	return err
+}
+
+// collectArchiveMetrics reports the monitoring archive of an API for every day from the last run until the end time,
+// in order. The data files already processed are skipped, the new ones are counted whatever the time of their metrics.
+// The progress is saved after each day and when the API fails, and a day is only marked as collected once the files
+// arriving late for it are in the archive and all of its files were read, so a day that failed is read again on the
+// next run. A data file that cannot be read in maxArchiveFileAttempts runs is skipped as failed, so that it does not
+// block the API.
+func (me *MuleEventEmitter) collectArchiveMetrics(instance *v1.ResourceInstance, apiID, apiVersionID string, reportEndTime time.Time) error {
+	lastAPIReportTime := me.getLastRun(apiID, apiVersionID)
+	endTime := lastAPIReportTime
+	logger := logrus.WithField("apiID", apiID).WithField("apiVersionID", apiVersionID)
+	failed := []string{}
+	defer me.saveCursors(apiID, apiVersionID)
+
+	for day := me.getArchiveStartDay(apiID, apiVersionID, lastAPIReportTime, reportEndTime); !day.After(reportEndTime); day = day.AddDate(0, 0, 1) {
+		files, err := me.client.ListMonitoringArchiveFiles(apiVersionID, day)
 		if err != nil {
-			logrus.WithError(err).Error("failed to get analytics data")
 			return err
 		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Time.Before(files[j].Time)
+		})
+
+		processed := me.getProcessedFiles(apiID, apiVersionID, day)
+		for _, file := range files {
+			if processed[file.ID] {
+				continue
+			}
+			fileLogger := logger.WithField("day", day.Format(archiveDayFormat)).WithField("fileName", file.ID)
+			metrics, err := me.client.GetMonitoringArchiveFile(apiVersionID, day, file.ID)
+			if err != nil {
+				attempts := me.archiveFileFailed(apiVersionID, day, file.ID)
+				if attempts < maxArchiveFileAttempts {
+					return err
+				}
+				fileLogger.WithField("attempts", attempts).WithError(err).Error("skipping a data file of the monitoring archive that cannot be read")
+				failed = append(failed, file.ID)
+			} else {
+				me.clearArchiveFileAttempts(apiVersionID, day, file.ID)
+				if fileEndTime := me.sendMetrics(instance, apiID, apiVersionID, lastAPIReportTime, metrics); fileEndTime.After(endTime) {
+					endTime = fileEndTime
+				}
+				fileLogger.WithField("lastReportTime", endTime).Info("updating next query time")
+			}
+			processed[file.ID] = true
+			me.saveProcessedFiles(apiID, apiVersionID, day, processed, endTime)
+		}
+
+		if nextDay := day.AddDate(0, 0, 1); !nextDay.Add(archiveLateFileTime).After(reportEndTime) {
+			me.saveArchiveDay(apiID, apiVersionID, day)
+		}
+		me.saveCursors(apiID, apiVersionID)
 	}
 
+	if len(failed) > 0 {
+		return fmt.Errorf("skipped the data files %s that could not be read in %d attempts", strings.Join(failed, ", "), maxArchiveFileAttempts)
+	}
 	return nil
+}
 
+// archiveFileFailed counts a failed read of a data file of the monitoring archive, and returns the number of failed
+// reads. The count is cleared once the file is read or skipped.
+func (me *MuleEventEmitter) archiveFileFailed(apiVersionID string, day time.Time, fileID string) int {
+	me.attemptsLock.Lock()
+	defer me.attemptsLock.Unlock()
+	key := archiveFileKey(apiVersionID, day, fileID)
+	me.fileAttempts[key]++
+	attempts := me.fileAttempts[key]
+	if attempts >= maxArchiveFileAttempts {
+		delete(me.fileAttempts, key)
+	}
+	return attempts
 }
 
-func (me *MuleEventEmitter) getMetrics(bootInfo *anypoint.MonitoringBootInfo, apiID, apiVersionID string, startTime, endTime time.Time) ([]anypoint.APIMonitoringMetric, error) {
-	if me.useMonitoringAPI {
-		return me.client.GetMonitoringArchive(apiVersionID, startTime)
+func (me *MuleEventEmitter) clearArchiveFileAttempts(apiVersionID string, day time.Time, fileID string) {
+	me.attemptsLock.Lock()
+	defer me.attemptsLock.Unlock()
+	delete(me.fileAttempts, archiveFileKey(apiVersionID, day, fileID))
+}
+
+func archiveFileKey(apiVersionID string, day time.Time, fileID string) string {
+	return fmt.Sprintf("%s-%s-%s", apiVersionID, day.Format(archiveDayFormat), fileID)
+}
+
+// newMetrics returns the metrics reported after the last report time
+func newMetrics(metrics []anypoint.APIMonitoringMetric, lastAPIReportTime time.Time) []anypoint.APIMonitoringMetric {
+	latest := make([]anypoint.APIMonitoringMetric, 0, len(metrics))
+	for _, metric := range metrics {
+		// Report only latest entries, ignore old entries
+		if metric.Time.UnixMilli() > lastAPIReportTime.UnixMilli() {
+			latest = append(latest, metric)
+		}
 	}
+	return latest
+}
 
-	return me.client.GetMonitoringMetrics(bootInfo.Settings.DataSource.InfluxDB.Database, bootInfo.Settings.DataSource.InfluxDB.ID, apiID, apiVersionID, startTime, endTime)
+// sendMetrics sends the metrics on the event channel, and returns the time of the most recent metric
+func (me *MuleEventEmitter) sendMetrics(instance *v1.ResourceInstance, apiID, apiVersionID string, lastAPIReportTime time.Time, metrics []anypoint.APIMonitoringMetric) time.Time {
+	endTime := lastAPIReportTime
+	for _, metric := range metrics {
+		// the metrics of a data file arriving late are older than the last report time
+		startTime := lastAPIReportTime
+		if metric.Time.Before(startTime) {
+			startTime = metric.Time
+		}
+		for _, event := range metric.Events {
+			summary := event.Summary()
+			m := common.MetricEvent{
+				Type: common.Metric,
+				Metric: common.Metrics{
+					StartTime:          startTime,
+					EndTime:            metric.Time,
+					APIID:              apiID,
+					Instance:           instance,
+					ClientID:           summary.ClientID,
+					StatusCode:         summary.StatusCode,
+					Method:             summary.Method,
+					RequestDisposition: summary.RequestDisposition,
+					Count:              summary.RequestCount,
+					Max:                summary.ResponseTimeMax,
+					Min:                summary.ResponseTimeMin,
+					Avg:                summary.ResponseTimeAvg,
+					StdDev:             summary.ResponseTimeStdDev,
+					RequestBytes:       summary.RequestBytes,
+					ResponseBytes:      summary.ResponseBytes,
+				},
+			}
+			me.eventChannel <- m
+			logrus.WithField("apiID", apiID).
+				WithField("apiVersionID", apiVersionID).
+				WithField("statusCode", summary.StatusCode).
+				WithField("method", summary.Method).
+				WithField("disposition", summary.RequestDisposition).
+				WithField("count", summary.RequestCount).
+				WithField("avg", summary.ResponseTimeAvg).
+				WithField("metricTime", metric.Time).
+				Info("storing API metrics")
+		}
+		// Results are not sorted. We want the most recent time to bubble up for next run cycle
+		if metric.Time.UnixMilli() > endTime.UnixMilli() {
+			endTime = metric.Time
+		}
+	}
+	return endTime
+}
+
+// getLastRun returns the time of the last reported metrics of an API version, or now when none were reported
+func (me *MuleEventEmitter) getLastRun(apiID, apiVersionID string) time.Time {
+	if cursor := me.cursors.get(apiID, apiVersionID); cursor.LastRun > 0 {
+		return time.UnixMilli(cursor.LastRun)
+	}
+	return time.Now()
 }
 
-func (me *MuleEventEmitter) getLastRun(apiID string) time.Time {
-	tStamp, _ := me.cache.Get(CacheKeyTimeStamp + "-" + apiID)
-	tStart := time.Now()
-	if tStamp != nil {
-		tm, err := strconv.ParseInt(tStamp.(string), 10, 64)
-		if err == nil {
-			tStart = time.UnixMilli(tm)
+// saveLastRun saves the time of the last reported metrics of an API version, and the start of the first reported window
+func (me *MuleEventEmitter) saveLastRun(apiID, apiVersionID string, startTime, lastTime time.Time) {
+	me.cursors.update(apiID, apiVersionID, func(c *apiCursor) {
+		if c.FirstRun == 0 {
+			c.FirstRun = startTime.UnixMilli()
 		}
+		c.LastRun = lastTime.UnixMilli()
+	})
+}
+
+// getArchiveStartDay returns the first UTC day of the archive to read: the day after the last collected day, or the day
+// of the last run when no day was collected. Days older than the archive retention are skipped.
+func (me *MuleEventEmitter) getArchiveStartDay(apiID, apiVersionID string, lastRun, reportEndTime time.Time) time.Time {
+	startDay := utcDay(lastRun)
+	if cursor := me.cursors.get(apiID, apiVersionID); cursor.ArchiveDay != "" {
+		if day, err := time.Parse(archiveDayFormat, cursor.ArchiveDay); err == nil {
+			startDay = day
+		}
+	}
+
+	oldestDay := utcDay(reportEndTime.Add(-archiveRetention))
+	if startDay.Before(oldestDay) {
+		logrus.WithField("apiID", apiID).
+			WithField("day", startDay.Format(archiveDayFormat)).
+			WithField("oldestDay", oldestDay.Format(archiveDayFormat)).
+			Warn("the monitoring archive is not retained for the days since the last run, skipping to the oldest day")
+		startDay = oldestDay
+	}
+	return startDay
+}
+
+// saveArchiveDay marks a day as collected, the files processed that day are not needed anymore
+func (me *MuleEventEmitter) saveArchiveDay(apiID, apiVersionID string, day time.Time) {
+	me.cursors.update(apiID, apiVersionID, func(c *apiCursor) {
+		c.ArchiveDay = day.AddDate(0, 0, 1).Format(archiveDayFormat)
+		delete(c.ArchiveFiles, day.Format(archiveDayFormat))
+	})
+}
+
+// getProcessedFiles returns the ids of the data files of a day already reported
+func (me *MuleEventEmitter) getProcessedFiles(apiID, apiVersionID string, day time.Time) map[string]bool {
+	processed := map[string]bool{}
+	for _, id := range me.cursors.get(apiID, apiVersionID).ArchiveFiles[day.Format(archiveDayFormat)] {
+		if id != "" {
+			processed[id] = true
+		}
+	}
+	return processed
+}
+
+// saveProcessedFiles saves the ids of the data files of a day already reported with the time of the last reported
+// metrics, in the same change. All the metrics of the first day read were reported.
+func (me *MuleEventEmitter) saveProcessedFiles(apiID, apiVersionID string, day time.Time, processed map[string]bool, lastTime time.Time) {
+	me.cursors.update(apiID, apiVersionID, func(c *apiCursor) {
+		if c.ArchiveFiles == nil {
+			c.ArchiveFiles = map[string][]string{}
+		}
+		c.ArchiveFiles[day.Format(archiveDayFormat)] = sortedFiles(processed)
+		if c.FirstRun == 0 {
+			c.FirstRun = day.UnixMilli()
+		}
+		c.LastRun = lastTime.UnixMilli()
+	})
+}
+
+// saveCursors writes the changed cursors to the cursor store
+func (me *MuleEventEmitter) saveCursors(apiID, apiVersionID string) {
+	if err := me.cursors.save(); err != nil {
+		logrus.WithField("apiID", apiID).WithField("apiVersionID", apiVersionID).WithError(err).Error("failed to save the cursors")
+	}
+}
+
+// materializeCursors copies the cursors migrated from the cache to the API versions of the instances
+func (me *MuleEventEmitter) materializeCursors() {
+	versions := map[string][]string{}
+	for _, instanceID := range me.instanceCache.GetAPIServiceInstanceKeys() {
+		instance, _ := me.instanceCache.GetAPIServiceInstanceByID(instanceID)
+		if instance == nil {
+			continue
+		}
+		apiID, _ := util.GetAgentDetailsValue(instance, common.AttrAssetID)
+		apiVersionID, _ := util.GetAgentDetailsValue(instance, common.AttrAPIID)
+		if apiID != "" && apiVersionID != "" {
+			versions[apiID] = append(versions[apiID], apiVersionID)
+		}
+	}
+	if err := me.cursors.materialize(versions); err != nil {
+		logrus.WithError(err).Error("failed to save the migrated cursors")
 	}
-	return tStart
 }
 
-func (me *MuleEventEmitter) saveLastRun(apiID string, lastTime time.Time) {
-	me.cache.Set(CacheKeyTimeStamp+"-"+apiID, fmt.Sprintf("%d", lastTime.UnixMilli()))
-	me.cache.Save(me.cachePath)
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
 
 // OnConfigChange passes the new config to the client to handle config changes
@@ -102,6 +102,7 @@ func Test_MuleEventEmitter(t *testing.T) {
 	}
 	client := &mockAnalyticsClient{
 		events: []anypoint.APIMonitoringMetric{event},
+		files:  []anypoint.DataFile{{ID: "444-111-222.log"}},
 		err:    nil,
 	}
 	instanceCache := &mockInstaceCache{}
@@ -112,7 +113,7 @@ func Test_MuleEventEmitter(t *testing.T) {
 	ri, _ := svcInst.AsInstance()
 	instanceCache.AddAPIServiceInstance(ri)
 
-	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: "/tmp", UseMonitoringAPI: true}, eventCh, client, instanceCache)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, instanceCache)
 
 	assert.NotNil(t, emitter)
 
@@ -126,18 +127,18 @@ func Test_MuleEventEmitter(t *testing.T) {
 	assert.True(t, eventReceiver.metricReceived)
 	assert.True(t, eventReceiver.metricBatchPublish)
 
-	// Should throw an error when the client returns an error
+	// Should throw an error when the metrics of every API cannot be collected
 	eventCh = make(chan common.MetricEvent)
 	client = &mockAnalyticsClient{
 		events: []anypoint.APIMonitoringMetric{},
 		err:    fmt.Errorf("failed"),
 	}
-	emitter = NewMuleEventEmitter(&config.MulesoftConfig{CachePath: "/tmp", UseMonitoringAPI: true}, eventCh, client, instanceCache)
+	emitter = NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, instanceCache)
 	eventReceiver = &mockEventReceiver{}
 	eventReceiver.init()
 	go func() {
 		err := emitter.Start()
-		assert.Equal(t, client.err, err)
+		assert.ErrorIs(t, err, client.err)
 	}()
 	go eventReceiver.receiveEvents(eventCh)
 
@@ -146,6 +147,373 @@ func Test_MuleEventEmitter(t *testing.T) {
 	assert.True(t, eventReceiver.metricBatchPublish)
 }
 
+// mockArchiveClient returns the data files of the monitoring archive by day, and records the days that were listed
+type mockArchiveClient struct {
+	mockAnalyticsClient
+	files     map[string][]anypoint.DataFile
+	metrics   map[string][]anypoint.APIMonitoringMetric
+	listErr   map[string]error
+	fileErr   map[string]error
+	listedDay []string
+}
+
+func (m *mockArchiveClient) ListMonitoringArchiveFiles(_ string, day time.Time) ([]anypoint.DataFile, error) {
+	d := day.Format(archiveDayFormat)
+	m.listedDay = append(m.listedDay, d)
+	return m.files[d], m.listErr[d]
+}
+
+func (m *mockArchiveClient) GetMonitoringArchiveFile(_ string, _ time.Time, fileID string) ([]anypoint.APIMonitoringMetric, error) {
+	if err := m.fileErr[fileID]; err != nil {
+		return nil, err
+	}
+	return m.metrics[fileID], nil
+}
+
+func TestCollectArchiveMetrics(t *testing.T) {
+	now := time.Now()
+	today := utcDay(now)
+	day := func(offset int) time.Time {
+		return today.AddDate(0, 0, offset)
+	}
+	metric := func(tm time.Time) []anypoint.APIMonitoringMetric {
+		return []anypoint.APIMonitoringMetric{{
+			Time:   tm,
+			Events: []anypoint.APISummaryMetricEvent{{StatusCode: "200", RequestSizeCount: 1}},
+		}}
+	}
+	files := map[string][]anypoint.DataFile{
+		day(-2).Format(archiveDayFormat): {{ID: "2b", Time: day(-2).Add(2 * time.Hour)}, {ID: "2a", Time: day(-2).Add(time.Hour)}},
+		day(-1).Format(archiveDayFormat): {{ID: "1a", Time: day(-1).Add(time.Hour)}},
+		day(0).Format(archiveDayFormat):  {{ID: "0a", Time: day(0)}},
+	}
+	metrics := map[string][]anypoint.APIMonitoringMetric{
+		"2a": metric(day(-2).Add(time.Hour)),
+		"2b": metric(day(-2).Add(2 * time.Hour)),
+		"1a": metric(day(-1).Add(time.Hour)),
+		"0a": metric(day(0)),
+	}
+	instance := &v1.ResourceInstance{}
+
+	tests := []struct {
+		name         string
+		lastRun      time.Time
+		listErr      map[string]error
+		expectedDays []string
+		expectedTime []time.Time
+		lastRunAfter time.Time
+		archiveDay   time.Time
+		wantErr      bool
+	}{
+		{
+			name:         "should read every day since the last run in order",
+			lastRun:      day(-3).Add(time.Hour),
+			expectedDays: []string{day(-3).Format(archiveDayFormat), day(-2).Format(archiveDayFormat), day(-1).Format(archiveDayFormat), day(0).Format(archiveDayFormat)},
+			expectedTime: []time.Time{day(-2).Add(time.Hour), day(-2).Add(2 * time.Hour), day(-1).Add(time.Hour), day(0)},
+			lastRunAfter: day(0),
+			archiveDay:   day(-1),
+		},
+		{
+			name:         "should stop at the day that failed and read it again on the next run",
+			lastRun:      day(-3).Add(time.Hour),
+			listErr:      map[string]error{day(-1).Format(archiveDayFormat): fmt.Errorf("failed")},
+			expectedDays: []string{day(-3).Format(archiveDayFormat), day(-2).Format(archiveDayFormat), day(-1).Format(archiveDayFormat)},
+			expectedTime: []time.Time{day(-2).Add(time.Hour), day(-2).Add(2 * time.Hour)},
+			lastRunAfter: day(-2).Add(2 * time.Hour),
+			archiveDay:   day(-1),
+			wantErr:      true,
+		},
+		{
+			name:         "should skip the days older than the archive retention",
+			lastRun:      day(-40),
+			expectedDays: nil,
+			lastRunAfter: day(0),
+			archiveDay:   day(-1),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockArchiveClient{files: files, metrics: metrics, listErr: tc.listErr}
+			eventCh := make(chan common.MetricEvent, 10)
+			cfg := &config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}
+			emitter := NewMuleEventEmitter(cfg, eventCh, client, &mockInstaceCache{})
+			emitter.saveLastRun("1234", "5678", tc.lastRun, tc.lastRun)
+
+			err := emitter.collectArchiveMetrics(instance, "1234", "5678", now)
+			assert.Equal(t, tc.wantErr, err != nil)
+			close(eventCh)
+
+			if tc.expectedDays != nil {
+				assert.Equal(t, tc.expectedDays, client.listedDay)
+			} else {
+				assert.Len(t, client.listedDay, 31)
+				assert.Equal(t, utcDay(now.Add(-archiveRetention)).Format(archiveDayFormat), client.listedDay[0])
+			}
+			times := []time.Time{}
+			for event := range eventCh {
+				assert.Equal(t, tc.lastRun.UnixMilli(), event.Metric.StartTime.UnixMilli())
+				times = append(times, event.Metric.EndTime)
+			}
+			if tc.expectedTime != nil {
+				assert.Equal(t, tc.expectedTime, times)
+			}
+			assert.Equal(t, tc.lastRunAfter.UnixMilli(), emitter.getLastRun("1234", "5678").UnixMilli())
+			assert.Equal(t, tc.archiveDay, emitter.getArchiveStartDay("1234", "5678", tc.lastRun, now))
+
+			// the progress is persisted
+			reloaded := NewMuleEventEmitter(cfg, eventCh, client, &mockInstaceCache{})
+			assert.Equal(t, tc.lastRunAfter.UnixMilli(), reloaded.getLastRun("1234", "5678").UnixMilli())
+		})
+	}
+}
+
+func TestSaveFirstRun(t *testing.T) {
+	start := time.Date(2024, 10, 20, 10, 0, 0, 0, time.UTC)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir()}, nil, &mockArchiveClient{}, &mockInstaceCache{})
+
+	// the start of the first reported window is kept
+	emitter.saveLastRun("1234", "5678", start, start.Add(time.Hour))
+	emitter.saveLastRun("1234", "5678", start.Add(time.Hour), start.Add(2*time.Hour))
+	cursor := emitter.cursors.get("1234", "5678")
+	assert.Equal(t, start.UnixMilli(), cursor.FirstRun)
+	assert.Equal(t, start.Add(2*time.Hour).UnixMilli(), cursor.LastRun)
+
+	// all the metrics of the first day of the archive are reported
+	emitter.saveProcessedFiles("1234", "9999", utcDay(start), map[string]bool{"a": true}, start)
+	cursor = emitter.cursors.get("1234", "9999")
+	assert.Equal(t, utcDay(start).UnixMilli(), cursor.FirstRun)
+	reported, known := cursor.reported()
+	assert.True(t, known)
+	assert.Equal(t, utcDay(start), reported.UTC())
+}
+
+func TestMaterializeCursors(t *testing.T) {
+	dir := t.TempDir()
+	legacy := cache.New()
+	legacy.Set(CacheKeyTimeStamp+"-asset-1", "1000")
+	legacy.Set(CacheKeyTimeStamp+"-asset-2", "2000")
+	assert.Nil(t, legacy.Save(formatCachePath(dir)))
+
+	instances := &mockInstaceCache{}
+	for _, ids := range [][]string{{"1", "asset-1", "api-1"}, {"2", "asset-1", "api-2"}} {
+		svcInst := management.NewAPIServiceInstance("api"+ids[0], "env")
+		svcInst.Metadata.ID = ids[0]
+		util.SetAgentDetailsKey(svcInst, common.AttrAssetID, ids[1])
+		util.SetAgentDetailsKey(svcInst, common.AttrAPIID, ids[2])
+		ri, _ := svcInst.AsInstance()
+		instances.AddAPIServiceInstance(ri)
+	}
+
+	// the migrated cursors are copied to the versions of the instances, the ones of the removed assets are dropped
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: dir}, nil, &mockArchiveClient{}, instances)
+	emitter.materializeCursors()
+	reloaded := loadCursorStore(dir)
+	assert.Equal(t, map[string]map[string]*apiCursor{
+		"asset-1": {"api-1": {LastRun: 1000}, "api-2": {LastRun: 1000}},
+	}, reloaded.cursors)
+	assert.Empty(t, reloaded.legacy)
+}
+
+func TestCollectArchiveMetricsLateFiles(t *testing.T) {
+	now := time.Now()
+	yesterday := utcDay(now).AddDate(0, 0, -1)
+	day := yesterday.Format(archiveDayFormat)
+	client := &mockArchiveClient{
+		files: map[string][]anypoint.DataFile{day: {{ID: "a", Time: yesterday.Add(2 * time.Hour)}}},
+		metrics: map[string][]anypoint.APIMonitoringMetric{
+			"a": {{Time: yesterday.Add(2 * time.Hour), Events: []anypoint.APISummaryMetricEvent{{StatusCode: "200"}}}},
+			"b": {{Time: yesterday.Add(time.Hour), Events: []anypoint.APISummaryMetricEvent{{StatusCode: "500"}}}},
+		},
+	}
+	eventCh := make(chan common.MetricEvent, 10)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, &mockInstaceCache{})
+	emitter.saveLastRun("1234", "5678", yesterday, yesterday)
+
+	err := emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now)
+	assert.Nil(t, err)
+	assert.Len(t, eventCh, 1)
+	<-eventCh
+
+	// a file arriving late with older metrics is counted, the processed file is not counted again
+	client.files[day] = append(client.files[day], anypoint.DataFile{ID: "b", Time: yesterday.Add(time.Hour)})
+	err = emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now)
+	assert.Nil(t, err)
+	assert.Len(t, eventCh, 1)
+	event := <-eventCh
+	assert.Equal(t, "500", event.Metric.StatusCode)
+	assert.Equal(t, yesterday.Add(time.Hour), event.Metric.StartTime)
+	assert.Equal(t, yesterday.Add(2*time.Hour).UnixMilli(), emitter.getLastRun("1234", "5678").UnixMilli())
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, emitter.getProcessedFiles("1234", "5678", yesterday))
+
+	// once the day is collected the processed files are removed
+	err = emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now.Add(48*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, eventCh, 0)
+	assert.Empty(t, emitter.getProcessedFiles("1234", "5678", yesterday))
+}
+
+func TestCollectArchiveMetricsFailedFile(t *testing.T) {
+	now := time.Now()
+	yesterday := utcDay(now).AddDate(0, 0, -1)
+	day := yesterday.Format(archiveDayFormat)
+	client := &mockArchiveClient{
+		files: map[string][]anypoint.DataFile{day: {
+			{ID: "a", Time: yesterday.Add(time.Hour)},
+			{ID: "b", Time: yesterday.Add(2 * time.Hour)},
+		}},
+		metrics: map[string][]anypoint.APIMonitoringMetric{
+			"b": {{Time: yesterday.Add(2 * time.Hour), Events: []anypoint.APISummaryMetricEvent{{StatusCode: "200"}}}},
+		},
+		fileErr: map[string]error{"a": fmt.Errorf("failed to decode")},
+	}
+	eventCh := make(chan common.MetricEvent, 10)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, &mockInstaceCache{})
+	emitter.saveLastRun("1234", "5678", yesterday, yesterday)
+
+	// the file is read again on the next runs
+	for i := 1; i < maxArchiveFileAttempts; i++ {
+		err := emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now)
+		assert.NotNil(t, err)
+		assert.Len(t, eventCh, 0)
+		assert.Empty(t, emitter.getProcessedFiles("1234", "5678", yesterday))
+	}
+
+	// the file is skipped after the last attempt, the next files are reported
+	err := emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the data files a that")
+	assert.Len(t, eventCh, 1)
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, emitter.getProcessedFiles("1234", "5678", yesterday))
+	assert.Empty(t, emitter.fileAttempts)
+
+	err = emitter.collectArchiveMetrics(&v1.ResourceInstance{}, "1234", "5678", now)
+	assert.Nil(t, err)
+	assert.Len(t, eventCh, 1)
+}
+
+func TestSendMetricsBreakdown(t *testing.T) {
+	now := time.Now()
+	lastRun := now.Add(-time.Minute)
+	metrics := []anypoint.APIMonitoringMetric{{
+		Time: now,
+		Events: []anypoint.APISummaryMetricEvent{
+			{ClientID: "c1", StatusCode: "200", Method: "GET", RequestDisposition: "processed", ResponseTimeCount: 3},
+			{ClientID: "c1", StatusCode: "200", Method: "POST", RequestDisposition: "processed", ResponseTimeCount: 2},
+			{ClientID: "c1", StatusCode: "429", Method: "GET", RequestDisposition: anypoint.DispositionBlocked, ResponseTimeCount: 1},
+		},
+	}}
+	eventCh := make(chan common.MetricEvent, 10)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, &mockAnalyticsClient{}, &mockInstaceCache{})
+
+	endTime := emitter.sendMetrics(&v1.ResourceInstance{}, "1234", "5678", lastRun, metrics)
+	close(eventCh)
+	assert.Equal(t, now, endTime)
+
+	// the requests of a client and status code are reported by method and disposition
+	type breakdown struct {
+		statusCode, method, disposition string
+		count                           int64
+	}
+	received := []breakdown{}
+	for event := range eventCh {
+		assert.Equal(t, common.Metric, event.Type)
+		assert.Equal(t, "c1", event.Metric.ClientID)
+		assert.Equal(t, lastRun, event.Metric.StartTime)
+		assert.Equal(t, now, event.Metric.EndTime)
+		received = append(received, breakdown{event.Metric.StatusCode, event.Metric.Method, event.Metric.RequestDisposition, event.Metric.Count})
+	}
+	assert.Equal(t, []breakdown{
+		{"200", "GET", "processed", 3},
+		{"200", "POST", "processed", 2},
+		{"429", "GET", anypoint.DispositionBlocked, 1},
+	}, received)
+}
+
+// mockAPIArchiveClient returns a data file for each API, and fails for the APIs in listErr
+type mockAPIArchiveClient struct {
+	mockAnalyticsClient
+	listErr map[string]error
+}
+
+func (m *mockAPIArchiveClient) ListMonitoringArchiveFiles(apiID string, _ time.Time) ([]anypoint.DataFile, error) {
+	return []anypoint.DataFile{{ID: apiID + ".log"}}, m.listErr[apiID]
+}
+
+func (m *mockAPIArchiveClient) GetMonitoringArchiveFile(apiID string, _ time.Time, _ string) ([]anypoint.APIMonitoringMetric, error) {
+	return []anypoint.APIMonitoringMetric{{
+		Time:   time.Now(),
+		Events: []anypoint.APISummaryMetricEvent{{APIVersionID: apiID, StatusCode: "200"}},
+	}}, nil
+}
+
+func TestMuleEventEmitterWorkers(t *testing.T) {
+	failure := fmt.Errorf("failed")
+	// the failing api versions 2 and 3 are versions of the same asset
+	client := &mockAPIArchiveClient{listErr: map[string]error{"api-2": failure, "api-3": failure}}
+	instanceCache := &mockInstaceCache{}
+	for i := 0; i < 20; i++ {
+		svcInst := management.NewAPIServiceInstance(fmt.Sprintf("api-%d", i), "env")
+		util.SetAgentDetailsKey(svcInst, common.AttrAPIID, fmt.Sprintf("api-%d", i))
+		util.SetAgentDetailsKey(svcInst, common.AttrAssetID, fmt.Sprintf("asset-%d", i/2))
+		svcInst.Metadata.ID = fmt.Sprintf("instance-%d", i)
+		ri, _ := svcInst.AsInstance()
+		instanceCache.AddAPIServiceInstance(ri)
+	}
+
+	eventCh := make(chan common.MetricEvent)
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true, MetricWorkers: 4}, eventCh, client, instanceCache)
+	for i := 0; i < 20; i++ {
+		emitter.saveLastRun(fmt.Sprintf("asset-%d", i/2), fmt.Sprintf("api-%d", i), time.Now().Add(-time.Minute), time.Now().Add(-time.Minute))
+	}
+	assert.Equal(t, 4, emitter.workers)
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- emitter.Start()
+	}()
+
+	// the batch is initialized first and completed once the metrics of all the APIs are sent
+	events := []common.MetricEvent{}
+	for event := range eventCh {
+		events = append(events, event)
+		if event.Type == common.Completed {
+			break
+		}
+	}
+	// the failures of some APIs do not fail the job
+	assert.Nil(t, <-errCh)
+	assert.Equal(t, common.Initialize, events[0].Type)
+	assert.Len(t, events, 20)
+	apis := map[string]bool{}
+	for _, event := range events[1 : len(events)-1] {
+		assert.Equal(t, common.Metric, event.Type)
+		apis[event.Metric.Instance.Name] = true
+	}
+	assert.Len(t, apis, 18)
+	assert.NotContains(t, apis, "api-2")
+	assert.NotContains(t, apis, "api-3")
+
+	// the failed API versions are each reported in the health check
+	details := emitter.failureDetails("mulesoft")
+	assert.Contains(t, details, "2 APIs")
+	assert.Contains(t, details, "asset-1/api-2: failed")
+	assert.Contains(t, details, "asset-1/api-3: failed")
+
+	// the failures are cleared when the APIs are collected again
+	client.listErr = nil
+	go func() {
+		errCh <- emitter.Start()
+	}()
+	for event := range eventCh {
+		if event.Type == common.Completed {
+			break
+		}
+	}
+	assert.Nil(t, <-errCh)
+	assert.Empty(t, emitter.failureDetails("mulesoft"))
+}
+
 func TestMuleEventEmitterJob(t *testing.T) {
 	pollInterval := 1 * time.Second
 	ac := &config.AgentConfig{
@@ -160,7 +528,7 @@ func TestMuleEventEmitterJob(t *testing.T) {
 		events: []anypoint.APIMonitoringMetric{},
 		err:    nil,
 	}
-	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: "/tmp", UseMonitoringAPI: true}, eventCh, client, &mockInstaceCache{})
+	emitter := NewMuleEventEmitter(&config.MulesoftConfig{CachePath: t.TempDir(), UseMonitoringAPI: true}, eventCh, client, &mockInstaceCache{})
 
 	job, err := NewMuleEventEmitterJob(emitter, pollInterval, mockHealthCheck, getStatusSuccess, mockRegisterHC)
 	assert.Nil(t, err)
@@ -188,6 +556,9 @@ func TestMuleEventEmitterJob(t *testing.T) {
 	hc.RegisterHealthcheck("mulesoft", anypoint.HealthCheckEndpoint, mockHealthCheck)
 	status = traceabilityHealthCheck("mulesoft")
 	assert.Equal(t, hc.OK, status.Result)
+	status = emitter.healthCheck("mulesoft")
+	assert.Equal(t, hc.OK, status.Result)
+	assert.Empty(t, status.Details)
 }
 
 func mockHealthCheck(string) *hc.Status {
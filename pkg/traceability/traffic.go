@@ -0,0 +1,245 @@
+package traceability
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/agent"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	"github.com/Axway/agent-sdk/pkg/transaction"
+	"github.com/Axway/agent-sdk/pkg/transaction/models"
+	transutil "github.com/Axway/agent-sdk/pkg/transaction/util"
+	coreutil "github.com/Axway/agent-sdk/pkg/util"
+	"github.com/elastic/beats/v7/libbeat/beat"
+
+	"github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+const (
+	// clientIDHeader is the request header of the client id of the client-id-enforcement policy
+	clientIDHeader = "client_id"
+	inbound        = "Inbound"
+	outbound       = "Outbound"
+	// instanceIndexInterval is the minimum time between two rebuilds of the index of the instances by API id
+	instanceIndexInterval = time.Minute
+)
+
+// instanceIndex maps the API ids to the keys of their instances in the cache, so that the traffic log entries do not
+// scan the instances. The index is rebuilt when an API id is missing, at most once per instanceIndexInterval so that
+// the entries of the APIs that are not discovered do not scan the instances each time. It is only used by the event
+// loop of the agent.
+type instanceIndex struct {
+	keys  map[string]string
+	built time.Time
+}
+
+// withTrafficLogs reports the transactions of the traffic logs read from a file or the standard input
+func (a *Agent) withTrafficLogs(path string, instances instanceCache) {
+	a.trafficChannel = make(chan GwTrafficLogEntry)
+	a.trafficReader = NewTrafficLogReader(path, a.trafficChannel)
+	a.instanceCache = instances
+	a.instances = &instanceIndex{keys: map[string]string{}}
+}
+
+func (a *Agent) readTrafficLogs() {
+	if err := a.trafficReader.Start(); err != nil {
+		a.logger.WithError(err).Error("failed to read the traffic logs")
+	}
+}
+
+func (a *Agent) processTrafficLogEntry(entry GwTrafficLogEntry) {
+	logger := a.logger.WithField("apiID", entry.APIID).WithField("traceID", entry.TraceID)
+
+	events, err := a.createTrafficEvents(entry)
+	if err != nil {
+		logger.WithError(err).Warn("skipping a traffic log entry")
+		return
+	}
+	if len(events) > 0 {
+		a.client.PublishAll(events)
+	}
+}
+
+// createTrafficEvents builds the transaction summary of a traffic log entry, and a leg event for the inbound and the
+// outbound transactions. The transaction is reported for the instance of the API id, and for the managed application
+// of the client id.
+func (a *Agent) createTrafficEvents(entry GwTrafficLogEntry) ([]beat.Event, error) {
+	instance := a.getInstance(entry.APIID)
+	if instance == nil {
+		return nil, fmt.Errorf("no api service instance found for the api %s", entry.APIID)
+	}
+
+	eventTime := time.Now()
+	if entry.Timestamp > 0 {
+		eventTime = time.UnixMilli(entry.Timestamp)
+	}
+	txID := entry.TraceID
+	if txID == "" {
+		txID = entry.InboundTransaction.ID
+	}
+	if txID == "" {
+		return nil, fmt.Errorf("traffic log entry without a trace id")
+	}
+
+	clientID := entry.ClientID
+	if clientID == "" {
+		clientID = entry.InboundTransaction.RequestHeaders[clientIDHeader]
+	}
+
+	summary, err := a.buildTrafficSummary(entry, txID, eventTime, a.getAPIDetails(instance), a.getAppDetails(clientID))
+	if err != nil {
+		return nil, err
+	}
+
+	inboundLeg, err := buildTrafficLeg(entry.InboundTransaction, txID, 0, inbound, eventTime)
+	if err != nil {
+		return nil, err
+	}
+	legs := []transaction.LogEvent{*inboundLeg}
+	if entry.OutboundTransaction.URI != "" {
+		outboundLeg, err := buildTrafficLeg(entry.OutboundTransaction, txID, 1, outbound, eventTime)
+		if err != nil {
+			return nil, err
+		}
+		legs = append(legs, *outboundLeg)
+	}
+
+	// the metrics are reported from the Anypoint Monitoring data, the transactions are not counted again
+	report, err := transaction.NewEventReportBuilder().
+		SetSummaryEvent(*summary).
+		SetDetailEvents(legs).
+		SetEventTime(eventTime).
+		SetSkipMetricTracking().
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	return a.eventGenerator.CreateFromEventReport(report)
+}
+
+// getInstance returns the instance discovered for an API in API Manager
+func (a *Agent) getInstance(apiID string) *v1.ResourceInstance {
+	if apiID == "" || a.instanceCache == nil {
+		return nil
+	}
+	if instance := a.getIndexedInstance(apiID); instance != nil {
+		return instance
+	}
+	if time.Since(a.instances.built) < instanceIndexInterval {
+		return nil
+	}
+
+	a.instances.keys = map[string]string{}
+	a.instances.built = time.Now()
+	for _, key := range a.instanceCache.GetAPIServiceInstanceKeys() {
+		instance, err := a.instanceCache.GetAPIServiceInstanceByID(key)
+		if err != nil || instance == nil {
+			continue
+		}
+		if id, _ := coreutil.GetAgentDetailsValue(instance, common.AttrAPIID); id != "" {
+			a.instances.keys[id] = key
+		}
+	}
+	return a.getIndexedInstance(apiID)
+}
+
+// getIndexedInstance returns the instance of an API id in the index, when it is still in the cache
+func (a *Agent) getIndexedInstance(apiID string) *v1.ResourceInstance {
+	key, ok := a.instances.keys[apiID]
+	if !ok {
+		return nil
+	}
+	instance, err := a.instanceCache.GetAPIServiceInstanceByID(key)
+	if err != nil || instance == nil {
+		return nil
+	}
+	// the instance may have been replaced since the index was built
+	if id, _ := coreutil.GetAgentDetailsValue(instance, common.AttrAPIID); id != apiID {
+		return nil
+	}
+	return instance
+}
+
+func (a *Agent) buildTrafficSummary(
+	entry GwTrafficLogEntry, txID string, eventTime time.Time, api models.APIDetails, app models.AppDetails,
+) (*transaction.LogEvent, error) {
+	in := entry.InboundTransaction
+	builder := transaction.NewTransactionSummaryBuilder().
+		SetTimestamp(eventTime.UnixMilli()).
+		SetTransactionID(txID).
+		SetStatus(summaryStatus(in.StatusCode), fmt.Sprint(in.StatusCode)).
+		SetDuration(entry.Duration).
+		SetProxyWithStage(transutil.SummaryEventProxyIDPrefix+api.ID, api.Name, api.Stage, api.Revision).
+		SetEntryPoint("http", in.Method, in.URI, in.DesHost)
+	if app.Name != "" {
+		builder.SetApplication(transutil.SummaryEventApplicationIDPrefix+app.ID, app.Name)
+	}
+	if cfg := agent.GetCentralConfig(); cfg != nil {
+		builder.SetTenantID(cfg.GetTenantID()).
+			SetEnvironmentName(cfg.GetEnvironmentName()).
+			SetEnvironmentID(cfg.GetEnvironmentID()).
+			SetAPICDeployment(cfg.GetAPICDeployment())
+	}
+	return builder.Build()
+}
+
+func buildTrafficLeg(leg GwTransaction, txID string, legID int, direction string, eventTime time.Time) (*transaction.LogEvent, error) {
+	protocol, err := transaction.NewHTTPProtocolBuilder().
+		SetURI(leg.URI).
+		SetMethod(leg.Method).
+		SetStatus(leg.StatusCode, http.StatusText(leg.StatusCode)).
+		SetHost(leg.DesHost).
+		SetByteLength(leg.RequestBytes, leg.ResponseBytes).
+		SetRemoteAddress("", leg.SourceHost, leg.SourcePort).
+		SetLocalAddress(leg.DesHost, leg.DestPort).
+		SetRequestHeaders(leg.RequestHeaders).
+		SetResponseHeaders(leg.ResponseHeaders).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	builder := transaction.NewTransactionEventBuilder().
+		SetTimestamp(eventTime.UnixMilli()).
+		SetTransactionID(txID).
+		SetID(legEventID(txID, legID)).
+		SetSource(leg.SourceHost).
+		SetDestination(leg.DesHost).
+		SetDirection(direction).
+		SetStatus(eventStatus(leg.StatusCode)).
+		SetProtocolDetail(protocol)
+	if legID > 0 {
+		builder.SetParentID(legEventID(txID, legID-1))
+	}
+	if cfg := agent.GetCentralConfig(); cfg != nil {
+		builder.SetTenantID(cfg.GetTenantID()).
+			SetEnvironmentName(cfg.GetEnvironmentName()).
+			SetEnvironmentID(cfg.GetEnvironmentID()).
+			SetAPICDeployment(cfg.GetAPICDeployment())
+	}
+	return builder.Build()
+}
+
+func legEventID(txID string, legID int) string {
+	return fmt.Sprintf("%s-leg%d", txID, legID)
+}
+
+func summaryStatus(statusCode int) transaction.TxSummaryStatus {
+	switch {
+	case transutil.IsHTTPSuccessStatus(statusCode):
+		return transaction.TxSummaryStatusSuccess
+	case transutil.IsHTTPFailureStatus(statusCode):
+		return transaction.TxSummaryStatusFailure
+	case transutil.IsHTTPExceptionStatus(statusCode):
+		return transaction.TxSummaryStatusException
+	}
+	return transaction.TxSummaryStatusUnknown
+}
+
+func eventStatus(statusCode int) transaction.TxEventStatus {
+	if transutil.IsHTTPSuccessStatus(statusCode) {
+		return transaction.TxEventStatusPass
+	}
+	return transaction.TxEventStatusFail
+}
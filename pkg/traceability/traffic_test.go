@@ -0,0 +1,211 @@
+package traceability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/agent"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	"github.com/Axway/agent-sdk/pkg/apic/definitions"
+	"github.com/Axway/agent-sdk/pkg/cache"
+	"github.com/Axway/agent-sdk/pkg/traceability/redaction"
+	"github.com/Axway/agent-sdk/pkg/traceability/sampling"
+	"github.com/Axway/agent-sdk/pkg/transaction"
+	transutil "github.com/Axway/agent-sdk/pkg/transaction/util"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-mulesoft/pkg/common"
+)
+
+func newTrafficAgent(t *testing.T) *Agent {
+	agent.InitializeForTest(nil)
+	redaction.SetupGlobalRedaction(redaction.Config{})
+	sampling.SetupSampling(sampling.DefaultConfig(), true, "")
+
+	instanceCache := &mockInstaceCache{}
+	svcInst := management.NewAPIServiceInstance("petstore-instance", "env")
+	svcInst.Metadata.ID = "instance-id"
+	svcInst.Metadata.References = []v1.Reference{{Group: management.APIServiceGVK().Group, Kind: management.APIServiceGVK().Kind, Name: "petstore"}}
+	util.SetAgentDetailsKey(svcInst, common.AttrAPIID, "1234")
+	util.SetAgentDetailsKey(svcInst, definitions.AttrExternalAPIID, "asset-id")
+	ri, _ := svcInst.AsInstance()
+	instanceCache.AddAPIServiceInstance(ri)
+
+	app := management.NewManagedApplication("app", "env")
+	app.Metadata.ID = "app-id"
+	appInst, _ := app.AsInstance()
+	agent.GetCacheManager().AddManagedApplication(appInst)
+
+	cred := management.NewCredential("cred", "env")
+	cred.Metadata.References = []v1.Reference{{Group: management.ManagedApplicationGVK().Group, Kind: management.ManagedApplicationGVK().Kind, Name: "app"}}
+	credInst, _ := cred.AsInstance()
+	credCache := cache.New()
+	credCache.Set("client", credInst)
+
+	a, err := newAgent(nil, nil, credCache)
+	assert.Nil(t, err)
+	a.withTrafficLogs("-", instanceCache)
+	return a
+}
+
+func newTrafficLogEntry() GwTrafficLogEntry {
+	return GwTrafficLogEntry{
+		APIID:     "1234",
+		ClientID:  "client",
+		Duration:  15,
+		Timestamp: time.Now().UnixMilli(),
+		TraceID:   "trace-id",
+		InboundTransaction: GwTransaction{
+			DesHost:         "mule.example.com",
+			Method:          "GET",
+			URI:             "/pets",
+			StatusCode:      200,
+			RequestHeaders:  Headers{"client_id": "client"},
+			ResponseHeaders: Headers{"content-type": "application/json"},
+		},
+		OutboundTransaction: GwTransaction{
+			DesHost:         "backend.example.com",
+			Method:          "GET",
+			URI:             "/v1/pets",
+			StatusCode:      200,
+			RequestHeaders:  Headers{"accept": "application/json"},
+			ResponseHeaders: Headers{"content-type": "application/json"},
+		},
+	}
+}
+
+func TestCreateTrafficEvents(t *testing.T) {
+	a := newTrafficAgent(t)
+
+	tests := []struct {
+		name    string
+		entry   func(GwTrafficLogEntry) GwTrafficLogEntry
+		wantErr bool
+	}{
+		{
+			name:  "should create the summary and the inbound and outbound legs",
+			entry: func(e GwTrafficLogEntry) GwTrafficLogEntry { return e },
+		},
+		{
+			name: "should create the summary and the inbound leg when there is no outbound transaction",
+			entry: func(e GwTrafficLogEntry) GwTrafficLogEntry {
+				e.OutboundTransaction = GwTransaction{}
+				return e
+			},
+		},
+		{
+			name: "should resolve the client id from the client_id request header",
+			entry: func(e GwTrafficLogEntry) GwTrafficLogEntry {
+				e.ClientID = ""
+				e.InboundTransaction.StatusCode = 500
+				return e
+			},
+		},
+		{
+			name: "should return an error when the api is not discovered",
+			entry: func(e GwTrafficLogEntry) GwTrafficLogEntry {
+				e.APIID = "5678"
+				return e
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when the entry has no trace id",
+			entry: func(e GwTrafficLogEntry) GwTrafficLogEntry {
+				e.TraceID = ""
+				return e
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the events are only created for the sampled transactions
+			_, err := a.createTrafficEvents(tc.entry(newTrafficLogEntry()))
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestBuildTrafficSummary(t *testing.T) {
+	a := newTrafficAgent(t)
+	entry := newTrafficLogEntry()
+	api := a.getAPIDetails(a.getInstance(entry.APIID))
+	now := time.Now()
+
+	summary, err := a.buildTrafficSummary(entry, entry.TraceID, now, api, a.getAppDetails(entry.ClientID))
+	assert.Nil(t, err)
+	assert.Equal(t, "trace-id", summary.TransactionID)
+	assert.Equal(t, now.UnixMilli(), summary.Stamp)
+	assert.Equal(t, string(transaction.TxSummaryStatusSuccess), summary.TransactionSummary.Status)
+	assert.Equal(t, 15, summary.TransactionSummary.Duration)
+	assert.Equal(t, transutil.SummaryEventProxyIDPrefix+"asset-id", summary.TransactionSummary.Proxy.ID)
+	assert.Equal(t, "petstore", summary.TransactionSummary.Proxy.Name)
+	assert.Equal(t, transutil.SummaryEventApplicationIDPrefix+"app-id", summary.TransactionSummary.Application.ID)
+	assert.Equal(t, "GET", summary.TransactionSummary.EntryPoint.Method)
+
+	entry.InboundTransaction.StatusCode = 500
+	summary, err = a.buildTrafficSummary(entry, entry.TraceID, now, api, a.getAppDetails("unknown"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(transaction.TxSummaryStatusException), summary.TransactionSummary.Status)
+	assert.Nil(t, summary.TransactionSummary.Application)
+}
+
+func TestBuildTrafficLeg(t *testing.T) {
+	newTrafficAgent(t)
+	entry := newTrafficLogEntry()
+
+	leg, err := buildTrafficLeg(entry.InboundTransaction, entry.TraceID, 0, inbound, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "trace-id-leg0", leg.TransactionEvent.ID)
+	assert.Empty(t, leg.TransactionEvent.ParentID)
+	assert.Equal(t, inbound, leg.TransactionEvent.Direction)
+	assert.Equal(t, string(transaction.TxEventStatusPass), leg.TransactionEvent.Status)
+
+	entry.OutboundTransaction.StatusCode = 404
+	leg, err = buildTrafficLeg(entry.OutboundTransaction, entry.TraceID, 1, outbound, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "trace-id-leg1", leg.TransactionEvent.ID)
+	assert.Equal(t, "trace-id-leg0", leg.TransactionEvent.ParentID)
+	assert.Equal(t, string(transaction.TxEventStatusFail), leg.TransactionEvent.Status)
+
+	// the http protocol requires an uri
+	_, err = buildTrafficLeg(GwTransaction{}, entry.TraceID, 1, outbound, time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestGetInstance(t *testing.T) {
+	a := newTrafficAgent(t)
+
+	assert.NotNil(t, a.getInstance("1234"))
+	assert.Nil(t, a.getInstance("5678"))
+	assert.Nil(t, a.getInstance(""))
+
+	// the index is not rebuilt on each missing API id
+	svcInst := management.NewAPIServiceInstance("orders-instance", "env")
+	svcInst.Metadata.ID = "orders-instance-id"
+	util.SetAgentDetailsKey(svcInst, common.AttrAPIID, "5678")
+	ri, _ := svcInst.AsInstance()
+	a.instanceCache.(*mockInstaceCache).AddAPIServiceInstance(ri)
+	assert.Nil(t, a.getInstance("5678"))
+
+	a.instances.built = time.Now().Add(-instanceIndexInterval)
+	assert.NotNil(t, a.getInstance("5678"))
+	assert.NotNil(t, a.getInstance("1234"))
+}
+
+func TestTrafficAppDetails(t *testing.T) {
+	a := newTrafficAgent(t)
+
+	app := a.getAppDetails("client")
+	assert.Equal(t, "app-id", app.ID)
+	assert.Equal(t, "app", app.Name)
+	assert.Empty(t, a.getAppDetails("unknown").Name)
+}
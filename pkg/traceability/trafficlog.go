@@ -0,0 +1,193 @@
+package traceability
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/util/log"
+)
+
+const (
+	// stdinTrafficLog is the traffic log path to read the standard input
+	stdinTrafficLog = "-"
+	// trafficLogPollInterval is the time the reader waits for new entries at the end of a traffic log file
+	trafficLogPollInterval = time.Second
+)
+
+// TrafficLogReader reads the JSON traffic logs of the Mule runtime or API gateway, one GwTrafficLogEntry per line, and
+// sends the entries on a channel. A file is followed for new entries like tail -F: it is reopened when it is rotated
+// and read again from its start when it is truncated. The standard input is read until it is closed.
+type TrafficLogReader struct {
+	logger  log.FieldLogger
+	path    string
+	entries chan<- GwTrafficLogEntry
+	doneCh  chan struct{}
+}
+
+// NewTrafficLogReader creates a TrafficLogReader for a file, or for the standard input when the path is -
+func NewTrafficLogReader(path string, entries chan<- GwTrafficLogEntry) *TrafficLogReader {
+	return &TrafficLogReader{
+		logger:  log.NewFieldLogger().WithPackage("traceability.trafficlog").WithComponent("trafficLogReader"),
+		path:    path,
+		entries: entries,
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// Start reads the traffic logs until the reader is stopped
+func (r *TrafficLogReader) Start() error {
+	if r.path == stdinTrafficLog {
+		r.read(os.Stdin)
+		return nil
+	}
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+
+	// only the traffic logged after the agent started is reported
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.follow(file, offset)
+	return nil
+}
+
+// Stop stops reading the traffic logs
+func (r *TrafficLogReader) Stop() {
+	close(r.doneCh)
+}
+
+// read decodes the entries of a stream line by line until its end
+func (r *TrafficLogReader) read(in io.Reader) {
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			r.logger.WithError(err).Error("failed to read the traffic logs")
+			return
+		}
+		if !r.send(line) || err == io.EOF {
+			return
+		}
+	}
+}
+
+// follow decodes the entries of a file line by line from an offset, and waits for new entries at the end of the file.
+// When the path is rotated to a new file, the rest of the current file is read before the new file is read from its
+// start. When the file is truncated below the read offset, it is read again from its start.
+func (r *TrafficLogReader) follow(file *os.File, offset int64) {
+	defer func() {
+		file.Close()
+	}()
+
+	reader := bufio.NewReader(file)
+	line := ""
+	rotated := false
+	for {
+		part, err := reader.ReadString('\n')
+		offset += int64(len(part))
+		line += part
+		if err == nil {
+			if !r.send(line) {
+				return
+			}
+			line = ""
+			continue
+		}
+		if err != io.EOF {
+			r.logger.WithError(err).Error("failed to read the traffic logs")
+			return
+		}
+
+		if rotated {
+			next, err := os.Open(r.path)
+			if err == nil {
+				r.logger.Info("reading the rotated traffic log file")
+				if !r.send(line) {
+					next.Close()
+					return
+				}
+				file.Close()
+				file, offset, line, rotated = next, 0, "", false
+				reader.Reset(file)
+				continue
+			}
+		}
+
+		// wait for the rest of the line
+		select {
+		case <-r.doneCh:
+			return
+		case <-time.After(trafficLogPollInterval):
+		}
+
+		switch r.changed(file, offset) {
+		case fileRotated:
+			// the entries written to the current file before the rotation are read first
+			rotated = true
+		case fileTruncated:
+			r.logger.Info("reading the truncated traffic log file from its start")
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				r.logger.WithError(err).Error("failed to read the truncated traffic log file")
+				return
+			}
+			offset, line = 0, ""
+			reader.Reset(file)
+		}
+	}
+}
+
+// fileChange is the change of a followed traffic log file
+type fileChange int
+
+const (
+	fileUnchanged fileChange = iota
+	fileRotated
+	fileTruncated
+)
+
+// changed returns whether the path of the traffic logs is now another file, or whether the followed file is now
+// smaller than the read offset
+func (r *TrafficLogReader) changed(file *os.File, offset int64) fileChange {
+	current, err := file.Stat()
+	if err != nil {
+		return fileUnchanged
+	}
+	// the path may be missing between the rotation of the file and the creation of the new one
+	if info, err := os.Stat(r.path); err == nil && !os.SameFile(current, info) {
+		return fileRotated
+	}
+	if current.Size() < offset {
+		return fileTruncated
+	}
+	return fileUnchanged
+}
+
+// send decodes a line and sends its entry, it returns false when the reader is stopped
+func (r *TrafficLogReader) send(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+
+	entry := GwTrafficLogEntry{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		r.logger.WithError(err).Warn("skipping a traffic log entry that is not valid JSON")
+		return true
+	}
+
+	select {
+	case <-r.doneCh:
+		return false
+	case r.entries <- entry:
+		return true
+	}
+}
@@ -0,0 +1,89 @@
+package traceability
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrafficLogReader(t *testing.T) {
+	logs := `{"apiId":"1234","clientId":"client","traceId":"abc","inbound":{"method":"GET","statusCode":200}}
+
+not json
+{"apiId":"5678","traceId":"def","inbound":{"method":"POST","statusCode":500}}`
+
+	entries := make(chan GwTrafficLogEntry, 5)
+	reader := NewTrafficLogReader("-", entries)
+	reader.read(strings.NewReader(logs))
+	close(entries)
+
+	received := []GwTrafficLogEntry{}
+	for entry := range entries {
+		received = append(received, entry)
+	}
+	assert.Len(t, received, 2)
+	assert.Equal(t, "1234", received[0].APIID)
+	assert.Equal(t, "client", received[0].ClientID)
+	assert.Equal(t, "GET", received[0].InboundTransaction.Method)
+	assert.Equal(t, "5678", received[1].APIID)
+	assert.Equal(t, 500, received[1].InboundTransaction.StatusCode)
+}
+
+func TestTrafficLogReaderStop(t *testing.T) {
+	entries := make(chan GwTrafficLogEntry)
+	reader := NewTrafficLogReader("-", entries)
+	reader.Stop()
+
+	// nothing receives the entries, the reader returns as it is stopped
+	reader.read(strings.NewReader(`{"apiId":"1234"}` + "\n" + `{"apiId":"5678"}`))
+}
+
+func TestTrafficLogReaderMissingFile(t *testing.T) {
+	reader := NewTrafficLogReader("/tmp/mulesoft-missing-traffic.log", make(chan GwTrafficLogEntry))
+	assert.NotNil(t, reader.Start())
+}
+
+func TestTrafficLogReaderRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.log")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"apiId":"1"}`+"\n"), 0600))
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+
+	entries := make(chan GwTrafficLogEntry, 5)
+	reader := NewTrafficLogReader(path, entries)
+	defer reader.Stop()
+	go reader.follow(file, 0)
+
+	receive := func() string {
+		select {
+		case entry := <-entries:
+			return entry.APIID
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+	assert.Equal(t, "1", receive())
+
+	// the rest of the rotated file is read before the new file
+	appendLog(t, path, `{"apiId":"2"}`)
+	assert.Nil(t, os.Rename(path, path+".1"))
+	appendLog(t, path, `{"apiId":"3","traceId":"a-long-trace-id"}`)
+	assert.Equal(t, "2", receive())
+	assert.Equal(t, "3", receive())
+
+	// the truncated file is read from its start
+	assert.Nil(t, os.WriteFile(path, []byte(`{"apiId":"4"}`+"\n"), 0600))
+	assert.Equal(t, "4", receive())
+}
+
+func appendLog(t *testing.T, path, line string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	assert.Nil(t, err)
+	_, err = file.WriteString(line + "\n")
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+}
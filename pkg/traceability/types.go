@@ -19,10 +19,16 @@ type GwTransaction struct {
 	URI             string  `json:"uri"`
 }
 
-// GwTrafficLogEntry - Represents the structure of log entry the agent will receive
+// GwTrafficLogEntry - Represents the structure of log entry the agent will receive. The api id is the id of the API
+// in API Manager, the client id the one of the client application, and the timestamp and the duration are in
+// milliseconds.
 type GwTrafficLogEntry struct {
+	APIID               string        `json:"apiId"`
 	APIName             string        `json:"apiName"`
+	ClientID            string        `json:"clientId"`
+	Duration            int           `json:"duration"`
 	InboundTransaction  GwTransaction `json:"inbound"`
 	OutboundTransaction GwTransaction `json:"outbound"`
+	Timestamp           int64         `json:"timestamp"`
 	TraceID             string        `json:"traceId"`
 }